@@ -0,0 +1,175 @@
+// Command bundle exports, verifies and imports signed policy bundles.
+//
+//	bundle keygen
+//	bundle export -key KEY -version V -o policies.bundle
+//	bundle verify -trusted KEYS policies.bundle
+//	bundle import -trusted KEYS [-conflicts MODE] policies.bundle
+//
+// Keys are base64; -key also reads BUNDLE_SIGNING_KEY and -trusted
+// BUNDLE_TRUSTED_KEYS, a comma-separated list. export and import use
+// DATABASE_URL. import is gated like the server's policy writes: it writes
+// nothing if the stored test cases break, or on conflicts with -conflicts
+// strict (default POLICY_CONFLICT_MODE, else warn).
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/joho/godotenv"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+
+	"example.com/jit-engine/internal/bundle"
+	"example.com/jit-engine/internal/eval"
+	"example.com/jit-engine/internal/gate"
+	"example.com/jit-engine/internal/store"
+)
+
+func main() {
+	log.SetFlags(0)
+	godotenv.Load()
+	if len(os.Args) < 2 {
+		log.Fatal("usage: bundle keygen|export|verify|import")
+	}
+	cmd, args := os.Args[1], os.Args[2:]
+	switch cmd {
+	case "keygen":
+		keygen()
+	case "export":
+		export(args)
+	case "verify":
+		verify(args)
+	case "import":
+		importBundle(args)
+	default:
+		log.Fatal("usage: bundle keygen|export|verify|import")
+	}
+}
+
+func keygen() {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("public: ", base64.StdEncoding.EncodeToString(pub))
+	fmt.Println("private:", base64.StdEncoding.EncodeToString(priv.Seed()))
+	fmt.Println("key id: ", bundle.KeyID(pub))
+}
+
+func export(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	key := fs.String("key", os.Getenv("BUNDLE_SIGNING_KEY"), "base64 ed25519 signing key")
+	version := fs.String("version", "", "bundle version")
+	out := fs.String("o", "", "output file")
+	_ = fs.Parse(args)
+	if *version == "" || *out == "" {
+		log.Fatal("-version and -o are required")
+	}
+	priv, err := bundle.ParsePrivateKey(*key)
+	if err != nil {
+		log.Fatal(err)
+	}
+	c, err := bundle.Export(openStore())
+	if err != nil {
+		log.Fatal(err)
+	}
+	f, err := os.Create(*out)
+	if err != nil {
+		log.Fatal(err)
+	}
+	m, err := bundle.Write(f, c, *version, priv)
+	if err == nil {
+		err = f.Close()
+	}
+	if err != nil {
+		os.Remove(*out)
+		log.Fatal(err)
+	}
+	printManifest(m)
+}
+
+func verify(args []string) {
+	b := open(flag.NewFlagSet("verify", flag.ExitOnError), args)
+	printManifest(b.Manifest)
+}
+
+func importBundle(args []string) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	mode := os.Getenv("POLICY_CONFLICT_MODE")
+	if mode == "" {
+		mode = gate.ConflictsWarn
+	}
+	conflictMode := fs.String("conflicts", mode, "conflicts with existing policies: warn, strict or off")
+	b := open(fs, args)
+	if !gate.ValidMode(*conflictMode) {
+		log.Fatalf("invalid -conflicts %q (want warn, strict or off)", *conflictMode)
+	}
+	s := openStore()
+	// The stored test cases are decided by the current policies first.
+	eng, err := eval.NewEngine(s, s, true)
+	if err != nil {
+		log.Fatal(err)
+	}
+	conflicts, err := bundle.Import(s, b, gate.Gate{Engine: eng, ConflictMode: *conflictMode})
+	var (
+		regression *gate.RegressionError
+		conflict   *gate.ConflictError
+	)
+	switch {
+	case errors.As(err, &regression):
+		for _, r := range regression.Broken {
+			log.Printf("test case %s: %v", r.Name, r.Failures)
+		}
+	case errors.As(err, &conflict):
+		for _, c := range conflict.Conflicts {
+			log.Printf("conflict: %s", c.Message)
+		}
+	}
+	if err != nil {
+		log.Fatalf("nothing imported: %v", err)
+	}
+	for _, c := range conflicts {
+		log.Printf("warning: %s", c.Message)
+	}
+	printManifest(b.Manifest)
+}
+
+// open parses the trusted keys and the bundle path with fs and reads the
+// bundle.
+func open(fs *flag.FlagSet, args []string) *bundle.Bundle {
+	keys := fs.String("trusted", os.Getenv("BUNDLE_TRUSTED_KEYS"), "comma-separated base64 ed25519 public keys")
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		log.Fatalf("usage: bundle %s -trusted KEYS FILE", fs.Name())
+	}
+	trusted, err := bundle.ParsePublicKeys(*keys)
+	if err != nil {
+		log.Fatal(err)
+	}
+	b, err := bundle.Open(fs.Arg(0), trusted)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return b
+}
+
+func openStore() *store.SQL {
+	db, err := gorm.Open(postgres.Open(os.Getenv("DATABASE_URL")), &gorm.Config{})
+	if err != nil {
+		log.Fatal(err)
+	}
+	return store.NewPostgres(db)
+}
+
+func printManifest(m bundle.Manifest) {
+	out, _ := json.MarshalIndent(m, "", "  ")
+	fmt.Println(string(out))
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"example.com/jit-engine/internal/eval"
+	"example.com/jit-engine/internal/model"
+)
+
+func auditsCmd(c *client, out *printer, args []string) error {
+	fs := flag.NewFlagSet("audits", flag.ExitOnError)
+	params := map[string]*string{
+		"subject":  fs.String("subject", "", "subject id"),
+		"action":   fs.String("action", "", "action"),
+		"decision": fs.String("decision", "", "allow or deny"),
+		"provider": fs.String("provider", "", "provider the request resolved to"),
+		"since":    fs.String("since", "", "RFC3339 time or a duration such as 24h"),
+		"until":    fs.String("until", "", "RFC3339 time or a duration such as 1h"),
+		"limit":    fs.String("limit", "", "maximum entries (server default 100)"),
+	}
+	_ = fs.Parse(args)
+	q := url.Values{}
+	for k, v := range params {
+		if *v == "" {
+			continue
+		}
+		if k == "since" || k == "until" {
+			t, err := parseTime(*v)
+			if err != nil {
+				return fmt.Errorf("-%s: %w", k, err)
+			}
+			q.Set(k, t)
+			continue
+		}
+		q.Set(k, *v)
+	}
+	var audits []model.PolicyAudit
+	if err := c.do("GET", "/audits", q, nil, &audits); err != nil {
+		return err
+	}
+	rows := make([][]string, 0, len(audits))
+	for _, a := range audits {
+		var req eval.Request
+		_ = json.Unmarshal(a.Request, &req)
+		matched := ""
+		if a.MatchedID != nil {
+			matched = a.MatchedID.String()
+		}
+		decision := a.Decision
+		if a.BreakGlass {
+			decision += " (break-glass)"
+		}
+		rows = append(rows, []string{
+			a.CreatedAt.Local().Format(time.DateTime), fmt.Sprint(req.Subject["id"]), req.Action,
+			truncate(req.Resource, 40), decision, strings.Join(a.Providers, ","), matched,
+		})
+	}
+	return out.print(audits, []string{"TIME", "SUBJECT", "ACTION", "RESOURCE", "DECISION", "PROVIDERS", "MATCHED"}, rows)
+}
+
+// parseTime accepts RFC3339 or a duration back from now.
+func parseTime(v string) (string, error) {
+	if d, err := time.ParseDuration(v); err == nil {
+		return time.Now().Add(-d).UTC().Format(time.RFC3339), nil
+	}
+	if _, err := time.Parse(time.RFC3339, v); err != nil {
+		return "", fmt.Errorf("want RFC3339 or a duration, got %q", v)
+	}
+	return v, nil
+}
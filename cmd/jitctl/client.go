@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// client calls the server's HTTP API.
+type client struct {
+	base  string
+	token string
+	// actor and reason are sent with policy writes and recorded in the
+	// versions they create.
+	actor  string
+	reason string
+	// ifVersion, when set, is sent as If-Match so the write fails if the
+	// policy changed since.
+	ifVersion int
+	// ifPlan, when set, is sent as If-Match so a policy file is applied
+	// only if its plan still has this tag.
+	ifPlan string
+	http   http.Client
+}
+
+// apiError is a non-2xx response.
+type apiError struct {
+	Status int
+	Body   []byte
+}
+
+func (e *apiError) Error() string {
+	msg := strings.TrimSpace(string(e.Body))
+	if msg == "" {
+		msg = http.StatusText(e.Status)
+	}
+	return fmt.Sprintf("server returned %d: %s", e.Status, msg)
+}
+
+// do sends body (JSON-encoded unless it is a []byte) and decodes the response
+// into out when out is not nil.
+func (c *client) do(method, path string, query url.Values, body, out any) error {
+	var rd io.Reader
+	contentType := "application/json"
+	switch b := body.(type) {
+	case nil:
+	case []byte:
+		rd = bytes.NewReader(b)
+		contentType = "application/yaml"
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			return err
+		}
+		rd = bytes.NewReader(data)
+	}
+	u := strings.TrimSuffix(c.base, "/") + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(method, u, rd)
+	if err != nil {
+		return err
+	}
+	if rd != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if c.actor != "" {
+		req.Header.Set("X-Actor", c.actor)
+	}
+	if c.reason != "" {
+		req.Header.Set("X-Change-Reason", c.reason)
+	}
+	if c.ifVersion > 0 {
+		req.Header.Set("If-Match", fmt.Sprintf("%q", strconv.Itoa(c.ifVersion)))
+	}
+	if c.ifPlan != "" {
+		req.Header.Set("If-Match", fmt.Sprintf("%q", c.ifPlan))
+	}
+	if c.http.Timeout == 0 {
+		c.http.Timeout = 30 * time.Second
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode/100 != 2 {
+		return &apiError{Status: resp.StatusCode, Body: data}
+	}
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
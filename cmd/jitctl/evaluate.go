@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"example.com/jit-engine/internal/eval"
+	"example.com/jit-engine/internal/model"
+)
+
+func evaluateCmd(c *client, out *printer, args []string) error {
+	if len(args) > 0 && args[0] == "batch" {
+		return evaluateBatch(c, out, args[1:])
+	}
+	fs := flag.NewFlagSet("evaluate", flag.ExitOnError)
+	file := fs.String("f", "", "request JSON file, - for stdin")
+	trace := fs.Bool("trace", false, "print the evaluation trace")
+	_ = fs.Parse(args)
+	if *file == "" {
+		return errors.New("-f is required")
+	}
+	data, err := readInput(*file)
+	if err != nil {
+		return err
+	}
+	var req eval.Request
+	if err := json.Unmarshal(data, &req); err != nil {
+		return fmt.Errorf("%s: %w", *file, err)
+	}
+	var res eval.Result
+	if err := c.do("POST", "/evaluate", nil, req, &res); err != nil {
+		return err
+	}
+	if out.format != "table" {
+		return out.print(res, nil, nil)
+	}
+	var names map[string]string
+	if *trace {
+		names = policyNames(c)
+	}
+	printResult(out.w, res, *trace, names)
+	return nil
+}
+
+// batchResult is one line of a batch evaluation.
+type batchResult struct {
+	Request eval.Request `json:"request"`
+	Result  *eval.Result `json:"result,omitempty"`
+	Error   string       `json:"error,omitempty"`
+}
+
+func evaluateBatch(c *client, out *printer, args []string) error {
+	fs := flag.NewFlagSet("evaluate batch", flag.ExitOnError)
+	file := fs.String("f", "", "JSON array of requests or one request per line, - for stdin")
+	_ = fs.Parse(args)
+	if *file == "" {
+		return errors.New("-f is required")
+	}
+	data, err := readInput(*file)
+	if err != nil {
+		return err
+	}
+	reqs, err := parseRequests(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", *file, err)
+	}
+	results := make([]batchResult, 0, len(reqs))
+	rows := make([][]string, 0, len(reqs))
+	for i, req := range reqs {
+		br := batchResult{Request: req}
+		var res eval.Result
+		decision, reason := "error", ""
+		if err := c.do("POST", "/evaluate", nil, req, &res); err != nil {
+			br.Error = err.Error()
+			reason = err.Error()
+		} else {
+			br.Result = &res
+			decision, reason = res.Decision, res.Reason
+		}
+		results = append(results, br)
+		rows = append(rows, []string{
+			strconv.Itoa(i + 1), fmt.Sprint(req.Subject["id"]), req.Action, truncate(req.Resource, 40), decision, truncate(reason, 60),
+		})
+	}
+	return out.print(results, []string{"#", "SUBJECT", "ACTION", "RESOURCE", "DECISION", "REASON"}, rows)
+}
+
+// parseRequests reads a JSON array of requests or JSON lines.
+func parseRequests(data []byte) ([]eval.Request, error) {
+	data = bytes.TrimSpace(data)
+	var reqs []eval.Request
+	if bytes.HasPrefix(data, []byte("[")) {
+		err := json.Unmarshal(data, &reqs)
+		return reqs, err
+	}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 0, 64*1024), 16<<20)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		var r eval.Request
+		if err := json.Unmarshal([]byte(text), &r); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		reqs = append(reqs, r)
+	}
+	return reqs, sc.Err()
+}
+
+// policyNames maps policy IDs to "provider/name" for traces. Failures leave
+// the trace showing IDs.
+func policyNames(c *client) map[string]string {
+	var ps []model.Policy
+	if err := c.do("GET", "/policies", nil, nil, &ps); err != nil {
+		return nil
+	}
+	names := make(map[string]string, len(ps))
+	for _, p := range ps {
+		names[p.ID.String()] = p.Provider + "/" + p.Name
+	}
+	return names
+}
+
+// printResult renders a decision and, with trace, each policy consulted.
+func printResult(w io.Writer, res eval.Result, trace bool, names map[string]string) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "decision:\t%s\n", strings.ToUpper(res.Decision))
+	if res.Reason != "" {
+		fmt.Fprintf(tw, "reason:\t%s\n", res.Reason)
+	}
+	if res.Matched != nil {
+		fmt.Fprintf(tw, "matched:\t%s\n", policyLabel(res.Matched.String(), names))
+	}
+	if len(res.Providers) > 0 {
+		fmt.Fprintf(tw, "providers:\t%s\n", strings.Join(res.Providers, ", "))
+	}
+	if o := res.Obligations; o != nil {
+		fmt.Fprintf(tw, "obligations:\tmax session %ds, recording %t\n", o.MaxSessionTTLSeconds, o.SessionRecording)
+	}
+	tw.Flush()
+	if !trace {
+		return
+	}
+	fmt.Fprintln(w, "trace:")
+	tw = tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, t := range res.Trace {
+		mark := "-"
+		if t.Result != nil {
+			mark = "✗"
+			if *t.Result {
+				mark = "✓"
+			}
+		}
+		if t.Error != "" {
+			mark = "!"
+		}
+		detail := t.Reason
+		if t.Error != "" {
+			detail = "error: " + t.Error
+		}
+		if t.MatchedAction != "" {
+			detail += " (action via " + t.MatchedAction + ")"
+		}
+		if t.Delegation != nil {
+			detail += fmt.Sprintf(" (delegation from %s, depth %d)", t.Delegation.Delegator, t.Delegation.Depth)
+		}
+		fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\n", mark, t.Effect, policyLabel(t.PolicyID.String(), names), strings.TrimSpace(detail))
+	}
+	tw.Flush()
+}
+
+func policyLabel(id string, names map[string]string) string {
+	if n, ok := names[id]; ok {
+		return n
+	}
+	return id
+}
+
+func readAllStdin() ([]byte, error) {
+	return io.ReadAll(os.Stdin)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"example.com/jit-engine/internal/impact"
+	"example.com/jit-engine/internal/policyfile"
+)
+
+// impactCmd starts an impact analysis of a policy file, or shows a job.
+func impactCmd(c *client, out *printer, args []string) error {
+	if len(args) > 0 && args[0] != "" && args[0][0] != '-' {
+		var job impact.Job
+		if err := c.do("GET", "/impact/"+url.PathEscape(args[0]), nil, nil, &job); err != nil {
+			return err
+		}
+		return printImpact(out, &job)
+	}
+	fs := flag.NewFlagSet("impact", flag.ExitOnError)
+	file := fs.String("f", "", "policy file (YAML or JSON), - for stdin")
+	days := fs.Int("days", 0, "replay requests from this many days back (server default 7)")
+	limit := fs.Int("limit", 0, "replay at most this many requests (server default 10000)")
+	samples := fs.Int("samples", 0, "examples kept per kind of change (server default 20)")
+	wait := fs.Bool("wait", true, "wait for the job to finish")
+	_ = fs.Parse(args)
+	if *file == "" {
+		return errors.New("-f is required")
+	}
+	data, err := readInput(*file)
+	if err != nil {
+		return err
+	}
+	q := url.Values{}
+	for k, v := range map[string]int{"days": *days, "limit": *limit, "samples": *samples} {
+		if v > 0 {
+			q.Set(k, strconv.Itoa(v))
+		}
+	}
+	var job impact.Job
+	err = c.do("POST", "/impact", q, data, &job)
+	var apiErr *apiError
+	if errors.As(err, &apiErr) && apiErr.Status == http.StatusUnprocessableEntity {
+		var plan policyfile.Plan
+		if json.Unmarshal(apiErr.Body, &plan) == nil {
+			fmt.Print(plan.String())
+			return errors.New("policy file is invalid")
+		}
+	}
+	if err != nil {
+		return err
+	}
+	for *wait && job.Status == impact.StatusRunning {
+		time.Sleep(time.Second)
+		fmt.Fprintf(os.Stderr, "\r%d/%d requests replayed", job.Processed, job.Total)
+		if err := c.do("GET", "/impact/"+job.ID.String(), nil, nil, &job); err != nil {
+			return err
+		}
+	}
+	if *wait {
+		fmt.Fprintln(os.Stderr)
+	}
+	return printImpact(out, &job)
+}
+
+func printImpact(out *printer, job *impact.Job) error {
+	if out.format != "table" {
+		return out.print(job, nil, nil)
+	}
+	fmt.Fprintf(out.w, "job %s: %s, %d/%d requests\n", job.ID, job.Status, job.Processed, job.Total)
+	if job.Error != "" {
+		fmt.Fprintln(out.w, "error:", job.Error)
+	}
+	res := job.Result
+	if res == nil {
+		return nil
+	}
+	fmt.Fprintf(out.w, "replayed %d, skipped %d, unchanged %d\n\n", res.Replayed, res.Skipped, res.Unchanged)
+	var rows [][]string
+	for _, kind := range []struct {
+		name string
+		c    impact.Change
+	}{{"newly denied", res.NewlyDenied}, {"newly allowed", res.NewlyAllowed}, {"matched changed", res.MatchedChanged}} {
+		for _, s := range kind.c.Samples {
+			rows = append(rows, []string{
+				fmt.Sprintf("%s (%d)", kind.name, kind.c.Count), fmt.Sprint(s.Request.Subject["id"]), s.Request.Action,
+				truncate(s.Request.Resource, 40), outcomeLabel(s.Before), outcomeLabel(s.After),
+			})
+		}
+	}
+	return out.print(nil, []string{"CHANGE", "SUBJECT", "ACTION", "RESOURCE", "BEFORE", "AFTER"}, rows)
+}
+
+func outcomeLabel(o impact.Outcome) string {
+	if o.Policy != "" {
+		return o.Decision + " by " + o.Policy
+	}
+	return o.Decision
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+
+	"example.com/jit-engine/internal/lint"
+)
+
+// lintCmd lists lint findings for the stored policies, or for a policy file
+// as it would stand once imported. It fails when a finding is at least as
+// serious as -fail-on.
+func lintCmd(c *client, out *printer, args []string) error {
+	fs := flag.NewFlagSet("lint", flag.ExitOnError)
+	file := fs.String("f", "", "lint this policy file (YAML or JSON, - for stdin) instead of the stored policies")
+	provider := fs.String("provider", "", "only stored policies of this provider")
+	severity := fs.String("severity", "", "lowest severity to show: error, warning or info (default info)")
+	failOn := fs.String("fail-on", lint.SeverityError, "exit 1 on findings of this severity or worse; none never fails")
+	_ = fs.Parse(args)
+	if *failOn != "none" && !lint.ValidSeverity(*failOn) {
+		return fmt.Errorf("-fail-on must be error, warning, info or none")
+	}
+	q := url.Values{}
+	if *severity != "" {
+		q.Set("severity", *severity)
+	}
+	var findings []lint.Finding
+	if *file != "" {
+		data, err := readInput(*file)
+		if err != nil {
+			return err
+		}
+		if err := c.do("POST", "/policies/lint", q, data, &findings); err != nil {
+			return err
+		}
+	} else {
+		if *provider != "" {
+			q.Set("provider", *provider)
+		}
+		if err := c.do("GET", "/policies/lint", q, nil, &findings); err != nil {
+			return err
+		}
+	}
+	rows := make([][]string, 0, len(findings))
+	for _, f := range findings {
+		rows = append(rows, []string{f.Severity, f.Rule, f.Policy, f.Message})
+	}
+	if err := out.print(findings, []string{"SEVERITY", "RULE", "POLICY", "MESSAGE"}, rows); err != nil {
+		return err
+	}
+	if *failOn != "none" {
+		if n := len(lint.AtLeast(findings, *failOn)); n > 0 {
+			return fmt.Errorf("%d finding(s) at %s or above", n, *failOn)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+// Command jitctl administers a policy engine server and evaluates requests
+// against it.
+//
+//	jitctl [global flags] policies list|get|create|update|delete|conflicts ...
+//	jitctl [global flags] policies history|diff|rollback ID ...
+//	jitctl [global flags] evaluate -f request.json [-trace]
+//	jitctl [global flags] evaluate batch -f requests.json
+//	jitctl [global flags] audits [-subject S] [-decision D] ...
+//	jitctl [global flags] report [-since 720h] [-provider P]
+//	jitctl [global flags] export [-provider P] [-o policies.yaml]
+//	jitctl [global flags] import -f policies.yaml [-dry-run] [-if-plan TAG]
+//	jitctl [global flags] impact -f policies.yaml [-days N] | impact JOB-ID
+//	jitctl [global flags] lint [-f policies.yaml] [-severity S] [-fail-on S]
+//
+// Global flags: -server, -token, -actor, -output table|json|yaml and -config.
+// The config file (default ~/.config/jitctl/config.yaml, or $JITCTL_CONFIG)
+// holds server, token, actor and output; JITCTL_SERVER, JITCTL_TOKEN and
+// JITCTL_ACTOR override it and flags override both. The actor defaults to
+// $USER.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the config file.
+type Config struct {
+	Server string `yaml:"server"`
+	// Token is sent as a bearer token, for servers behind an authenticating proxy.
+	Token string `yaml:"token"`
+	// Actor is recorded as the author of policy changes.
+	Actor  string `yaml:"actor"`
+	Output string `yaml:"output"`
+}
+
+const usage = `usage: jitctl [-server URL] [-token T] [-actor NAME] [-output table|json|yaml] [-config FILE] COMMAND
+
+commands:
+  policies list [-provider P] [-name N] [-effect E] [-enabled true|false]
+  policies get ID
+  policies create [-provider P] -f policy.json [-reason R]
+  policies update ID [-provider P] -f policy.json [-reason R] [-if-version N]
+  policies delete ID [-reason R] [-if-version N]
+  policies history ID                 (recorded versions, oldest first)
+  policies diff ID [-from N] [-to N]  (default the latest version against the one before)
+  policies rollback ID -version N [-reason R] [-if-version N]
+  policies conflicts [-provider P]    (allows and denies that can apply to the same request)
+  evaluate -f request.json [-trace]
+  evaluate batch -f requests.json     (a JSON array or one request per line)
+  audits [-subject S] [-action A] [-decision D] [-provider P] [-since T] [-until T] [-limit N]
+  report [-since T] [-until T] [-provider P] [-min-permitted N] [-max-usage F]
+  export [-provider P] [-o FILE]      (policy file, YAML or JSON by extension)
+  import -f FILE [-dry-run] [-if-plan TAG] [-reason R]
+  impact -f FILE [-days N] [-limit N] [-samples N] [-wait=false]   (replay recorded requests)
+  impact JOB-ID
+  lint [-f FILE] [-provider P] [-severity S] [-fail-on S|none]
+`
+
+func main() {
+	fs := flag.NewFlagSet("jitctl", flag.ExitOnError)
+	fs.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	configPath := fs.String("config", defaultConfigPath(), "config file")
+	server := fs.String("server", "", "server URL")
+	token := fs.String("token", "", "bearer token")
+	actor := fs.String("actor", "", "author recorded with policy changes (default $USER)")
+	output := fs.String("output", "", "output format: table, json or yaml")
+	_ = fs.Parse(os.Args[1:])
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		fatal(err)
+	}
+	if v := os.Getenv("JITCTL_SERVER"); v != "" {
+		cfg.Server = v
+	}
+	if v := os.Getenv("JITCTL_TOKEN"); v != "" {
+		cfg.Token = v
+	}
+	if v := os.Getenv("JITCTL_ACTOR"); v != "" {
+		cfg.Actor = v
+	}
+	if *server != "" {
+		cfg.Server = *server
+	}
+	if *token != "" {
+		cfg.Token = *token
+	}
+	if *actor != "" {
+		cfg.Actor = *actor
+	}
+	if cfg.Actor == "" {
+		cfg.Actor = os.Getenv("USER")
+	}
+	if *output != "" {
+		cfg.Output = *output
+	}
+	if cfg.Server == "" {
+		cfg.Server = "http://localhost:8080"
+	}
+	out, err := newPrinter(cfg.Output)
+	if err != nil {
+		fatal(err)
+	}
+	c := &client{base: cfg.Server, token: cfg.Token, actor: cfg.Actor}
+
+	args := fs.Args()
+	if len(args) == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	switch args[0] {
+	case "policies":
+		err = policiesCmd(c, out, args[1:])
+	case "evaluate":
+		err = evaluateCmd(c, out, args[1:])
+	case "audits":
+		err = auditsCmd(c, out, args[1:])
+	case "report":
+		err = reportCmd(c, out, args[1:])
+	case "export":
+		err = exportCmd(c, args[1:])
+	case "import":
+		err = importCmd(c, out, args[1:])
+	case "impact":
+		err = impactCmd(c, out, args[1:])
+	case "lint":
+		err = lintCmd(c, out, args[1:])
+	default:
+		fs.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fatal(err)
+	}
+}
+
+func defaultConfigPath() string {
+	if v := os.Getenv("JITCTL_CONFIG"); v != "" {
+		return v
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "jitctl", "config.yaml")
+}
+
+// loadConfig reads path; a missing file is an empty config.
+func loadConfig(path string) (Config, error) {
+	var cfg Config
+	if path == "" {
+		return cfg, nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, err
+	}
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "jitctl:", err)
+	os.Exit(1)
+}
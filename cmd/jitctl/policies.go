@@ -0,0 +1,277 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"example.com/jit-engine/internal/lint"
+	"example.com/jit-engine/internal/model"
+)
+
+func policiesCmd(c *client, out *printer, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: jitctl policies list|get|create|update|delete|conflicts|history|diff|rollback")
+	}
+	switch args[0] {
+	case "list":
+		return listPolicies(c, out, args[1:])
+	case "get":
+		if len(args) != 2 {
+			return errors.New("usage: jitctl policies get ID")
+		}
+		var p model.Policy
+		if err := c.do("GET", "/policies/"+url.PathEscape(args[1]), nil, nil, &p); err != nil {
+			return err
+		}
+		return printPolicies(out, p, []model.Policy{p})
+	case "create", "update":
+		return writePolicy(c, out, args[0], args[1:])
+	case "conflicts":
+		return listConflicts(c, out, args[1:])
+	case "delete":
+		if len(args) < 2 || strings.HasPrefix(args[1], "-") {
+			return errors.New("usage: jitctl policies delete ID [-reason R]")
+		}
+		fs := flag.NewFlagSet("policies delete", flag.ExitOnError)
+		fs.StringVar(&c.reason, "reason", "", "why, recorded with the deletion")
+		fs.IntVar(&c.ifVersion, "if-version", 0, "fail unless the policy is still at this version")
+		_ = fs.Parse(args[2:])
+		if err := c.do("DELETE", "/policies/"+url.PathEscape(args[1]), nil, nil, nil); err != nil {
+			return err
+		}
+		fmt.Println("deleted", args[1])
+		return nil
+	case "history":
+		if len(args) != 2 {
+			return errors.New("usage: jitctl policies history ID")
+		}
+		return policyHistory(c, out, args[1])
+	case "diff":
+		return diffPolicy(c, out, args[1:])
+	case "rollback":
+		return rollbackPolicy(c, out, args[1:])
+	}
+	return fmt.Errorf("unknown policies command %q", args[0])
+}
+
+func listPolicies(c *client, out *printer, args []string) error {
+	fs := flag.NewFlagSet("policies list", flag.ExitOnError)
+	provider := fs.String("provider", "", "provider or alias")
+	name := fs.String("name", "", "name contains")
+	effect := fs.String("effect", "", "allow or deny")
+	enabled := fs.String("enabled", "", "true or false")
+	_ = fs.Parse(args)
+	q := url.Values{}
+	for k, v := range map[string]string{"provider": *provider, "name": *name, "effect": *effect, "enabled": *enabled} {
+		if v != "" {
+			q.Set(k, v)
+		}
+	}
+	var ps []model.Policy
+	if err := c.do("GET", "/policies", q, nil, &ps); err != nil {
+		return err
+	}
+	return printPolicies(out, ps, ps)
+}
+
+// writePolicy creates or updates a policy from a JSON file.
+func writePolicy(c *client, out *printer, op string, args []string) error {
+	var id string
+	if op == "update" {
+		if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+			return errors.New("usage: jitctl policies update ID [-provider P] -f policy.json")
+		}
+		id, args = args[0], args[1:]
+	}
+	fs := flag.NewFlagSet("policies "+op, flag.ExitOnError)
+	provider := fs.String("provider", "", "provider or alias (create defaults to global, update keeps the current one)")
+	file := fs.String("f", "", "policy JSON file, - for stdin")
+	fs.StringVar(&c.reason, "reason", "", "why, recorded with the new version")
+	if op == "update" {
+		fs.IntVar(&c.ifVersion, "if-version", 0, "fail unless the policy is still at this version")
+	}
+	_ = fs.Parse(args)
+	if *file == "" {
+		return errors.New("-f is required")
+	}
+	data, err := readInput(*file)
+	if err != nil {
+		return err
+	}
+	var body json.RawMessage
+	if err := json.Unmarshal(data, &body); err != nil {
+		return fmt.Errorf("%s: %w", *file, err)
+	}
+	q := url.Values{}
+	if *provider != "" {
+		q.Set("provider", *provider)
+	}
+	var res struct {
+		model.Policy
+		Warnings []lint.Conflict `json:"warnings,omitempty"`
+	}
+	if op == "create" {
+		err = c.do("POST", "/policies", q, body, &res)
+	} else {
+		err = c.do("PUT", "/policies/"+url.PathEscape(id), q, body, &res)
+	}
+	if err != nil {
+		return err
+	}
+	for _, w := range res.Warnings {
+		fmt.Fprintln(os.Stderr, "warning:", w.Message)
+	}
+	return printPolicies(out, res, []model.Policy{res.Policy})
+}
+
+// listConflicts lists allow/deny pairs that can apply to the same request.
+func listConflicts(c *client, out *printer, args []string) error {
+	fs := flag.NewFlagSet("policies conflicts", flag.ExitOnError)
+	provider := fs.String("provider", "", "provider or alias")
+	_ = fs.Parse(args)
+	q := url.Values{}
+	if *provider != "" {
+		q.Set("provider", *provider)
+	}
+	var cs []lint.Conflict
+	if err := c.do("GET", "/policies/conflicts", q, nil, &cs); err != nil {
+		return err
+	}
+	rows := make([][]string, 0, len(cs))
+	for _, x := range cs {
+		rows = append(rows, []string{
+			x.Provider, x.Allow.Name, truncate(x.Allow.Resource, 30), strconv.Itoa(x.Allow.Priority),
+			x.Deny.Name, truncate(x.Deny.Resource, 30), strconv.Itoa(x.Deny.Priority),
+		})
+	}
+	return out.print(cs, []string{"PROVIDER", "ALLOW", "RESOURCE", "PRIORITY", "DENY", "RESOURCE", "PRIORITY"}, rows)
+}
+
+// policyHistory lists the recorded versions of a policy.
+func policyHistory(c *client, out *printer, id string) error {
+	var vs []model.PolicyVersion
+	if err := c.do("GET", "/policies/"+url.PathEscape(id)+"/versions", nil, nil, &vs); err != nil {
+		return err
+	}
+	rows := make([][]string, 0, len(vs))
+	for _, v := range vs {
+		op := v.Operation
+		if v.RestoredFrom != nil {
+			op += fmt.Sprintf(" (restores %d)", *v.RestoredFrom)
+		}
+		rows = append(rows, []string{
+			strconv.Itoa(v.Version), op, v.Author, truncate(v.Reason, 50), v.CreatedAt.Local().Format("2006-01-02 15:04:05"),
+		})
+	}
+	return out.print(vs, []string{"VERSION", "OPERATION", "AUTHOR", "REASON", "AT"}, rows)
+}
+
+// diffPolicy shows the fields that differ between two versions.
+func diffPolicy(c *client, out *printer, args []string) error {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return errors.New("usage: jitctl policies diff ID [-from N] [-to N]")
+	}
+	id := args[0]
+	fs := flag.NewFlagSet("policies diff", flag.ExitOnError)
+	from := fs.Int("from", 0, "older version (default the one before -to)")
+	to := fs.Int("to", 0, "newer version (default the latest)")
+	_ = fs.Parse(args[1:])
+	q := url.Values{}
+	for k, v := range map[string]int{"from": *from, "to": *to} {
+		if v > 0 {
+			q.Set(k, strconv.Itoa(v))
+		}
+	}
+	var d struct {
+		From    model.PolicyVersion `json:"from"`
+		To      model.PolicyVersion `json:"to"`
+		Changes []struct {
+			Field string `json:"field"`
+			From  any    `json:"from"`
+			To    any    `json:"to"`
+		} `json:"changes"`
+	}
+	var raw json.RawMessage
+	if err := c.do("GET", "/policies/"+url.PathEscape(id)+"/diff", q, nil, &raw); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(raw, &d); err != nil {
+		return err
+	}
+	if out.format != "table" {
+		return out.print(raw, nil, nil)
+	}
+	fmt.Fprintf(out.w, "version %d (%s by %s) -> %d (%s by %s)\n", d.From.Version, d.From.Operation, or(d.From.Author, "unknown"), d.To.Version, d.To.Operation, or(d.To.Author, "unknown"))
+	rows := make([][]string, 0, len(d.Changes))
+	for _, ch := range d.Changes {
+		rows = append(rows, []string{ch.Field, truncate(diffValue(ch.From), 50), truncate(diffValue(ch.To), 50)})
+	}
+	return out.print(nil, []string{"FIELD", "FROM", "TO"}, rows)
+}
+
+// rollbackPolicy restores a recorded version as a new one.
+func rollbackPolicy(c *client, out *printer, args []string) error {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return errors.New("usage: jitctl policies rollback ID -version N [-reason R]")
+	}
+	id := args[0]
+	fs := flag.NewFlagSet("policies rollback", flag.ExitOnError)
+	version := fs.Int("version", 0, "version to restore")
+	fs.StringVar(&c.reason, "reason", "", "why, recorded with the new version")
+	fs.IntVar(&c.ifVersion, "if-version", 0, "fail unless the policy is still at this version")
+	_ = fs.Parse(args[1:])
+	if *version <= 0 {
+		return errors.New("-version is required")
+	}
+	var res struct {
+		model.Policy
+		Warnings []lint.Conflict `json:"warnings,omitempty"`
+	}
+	if err := c.do("POST", "/policies/"+url.PathEscape(id)+"/rollback", nil, map[string]int{"version": *version}, &res); err != nil {
+		return err
+	}
+	for _, w := range res.Warnings {
+		fmt.Fprintln(os.Stderr, "warning:", w.Message)
+	}
+	return printPolicies(out, res, []model.Policy{res.Policy})
+}
+
+func diffValue(v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+func or(s, fallback string) string {
+	if s == "" {
+		return fallback
+	}
+	return s
+}
+
+func printPolicies(out *printer, v any, ps []model.Policy) error {
+	rows := make([][]string, 0, len(ps))
+	for _, p := range ps {
+		rows = append(rows, []string{
+			p.ID.String(), p.Name, p.Provider, p.Effect, truncate(p.Resource, 40),
+			truncate(strings.Join(p.Actions, ","), 30), strconv.Itoa(p.Priority), strconv.FormatBool(p.Enabled),
+			strconv.Itoa(p.Version), truncate(p.Expr, 50),
+		})
+	}
+	return out.print(v, []string{"ID", "NAME", "PROVIDER", "EFFECT", "RESOURCE", "ACTIONS", "PRIORITY", "ENABLED", "VERSION", "EXPR"}, rows)
+}
+
+func readInput(path string) ([]byte, error) {
+	if path == "-" {
+		return readAllStdin()
+	}
+	return os.ReadFile(path)
+}
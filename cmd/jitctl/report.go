@@ -0,0 +1,75 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"example.com/jit-engine/internal/coverage"
+)
+
+// reportCmd shows the coverage report: unused, underused and over-broad
+// policies over a window of recorded decisions.
+func reportCmd(c *client, out *printer, args []string) error {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	since := fs.String("since", "", "RFC3339 time or a duration such as 720h (server default 30 days)")
+	until := fs.String("until", "", "RFC3339 time or a duration such as 24h")
+	provider := fs.String("provider", "", "only policies of this provider")
+	minPermitted := fs.String("min-permitted", "", "subjects an allow must permit before its usage is judged (server default 5)")
+	maxUsage := fs.String("max-usage", "", "flag allows used by at most this fraction of permitted subjects (server default 0.2)")
+	_ = fs.Parse(args)
+	q := url.Values{}
+	for k, v := range map[string]string{"since": *since, "until": *until} {
+		if v == "" {
+			continue
+		}
+		t, err := parseTime(v)
+		if err != nil {
+			return fmt.Errorf("-%s: %w", k, err)
+		}
+		q.Set(k, t)
+	}
+	for k, v := range map[string]string{"provider": *provider, "min_permitted": *minPermitted, "max_usage": *maxUsage} {
+		if v != "" {
+			q.Set(k, v)
+		}
+	}
+	var rep coverage.Report
+	if err := c.do("GET", "/reports/coverage", q, nil, &rep); err != nil {
+		return err
+	}
+	if out.format != "table" {
+		return out.print(rep, nil, nil)
+	}
+
+	fmt.Fprintf(out.w, "%d decisions from %s to %s\n\n", rep.Audits, rep.Since.Local().Format("2006-01-02 15:04"), rep.Until.Local().Format("2006-01-02 15:04"))
+	fmt.Fprintf(out.w, "Never matched (%d):\n", len(rep.Unused))
+	rows := make([][]string, 0, len(rep.Unused))
+	for _, u := range rep.Unused {
+		rows = append(rows, []string{u.Policy.Provider + "/" + u.Policy.Name, u.Policy.Effect, truncate(u.Policy.Resource, 40), u.Policy.CreatedAt.Local().Format("2006-01-02")})
+	}
+	if err := out.print(nil, []string{"POLICY", "EFFECT", "RESOURCE", "CREATED"}, rows); err != nil {
+		return err
+	}
+	fmt.Fprintf(out.w, "\nUsed by few of the subjects they permit (%d):\n", len(rep.Underused))
+	rows = rows[:0]
+	for _, u := range rep.Underused {
+		rows = append(rows, []string{
+			u.Policy.Provider + "/" + u.Policy.Name, strconv.Itoa(u.Used), strconv.Itoa(u.Permitted),
+			fmt.Sprintf("%.0f%%", u.Usage*100), truncate(strings.Join(u.Subjects, ","), 40),
+		})
+	}
+	if err := out.print(nil, []string{"POLICY", "USED", "PERMITTED", "USAGE", "SUBJECTS"}, rows); err != nil {
+		return err
+	}
+	fmt.Fprintf(out.w, "\nBroader than the resources accessed (%d):\n", len(rep.OverBroad))
+	rows = rows[:0]
+	for _, o := range rep.OverBroad {
+		rows = append(rows, []string{
+			o.Policy.Provider + "/" + o.Policy.Name, truncate(o.Policy.Resource, 30), strconv.Itoa(o.Accessed), truncate(o.Suggested, 40),
+		})
+	}
+	return out.print(nil, []string{"POLICY", "RESOURCE", "ACCESSED", "SUGGESTED"}, rows)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+
+	"example.com/jit-engine/internal/model"
+	"example.com/jit-engine/internal/policyfile"
+)
+
+// exportCmd writes the server's policies as a policy file.
+func exportCmd(c *client, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	provider := fs.String("provider", "", "only this provider")
+	file := fs.String("o", "", "output file, YAML unless it ends in .json (default stdout)")
+	_ = fs.Parse(args)
+	q := url.Values{}
+	if *provider != "" {
+		q.Set("provider", *provider)
+	}
+	var ps []model.Policy
+	if err := c.do("GET", "/policies", q, nil, &ps); err != nil {
+		return err
+	}
+	f := policyfile.FromPolicies(ps)
+	var buf bytes.Buffer
+	if filepath.Ext(*file) == ".json" {
+		enc := json.NewEncoder(&buf)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(f); err != nil {
+			return err
+		}
+	} else {
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(f); err != nil {
+			return err
+		}
+		enc.Close()
+	}
+	if *file == "" {
+		_, err := os.Stdout.Write(buf.Bytes())
+		return err
+	}
+	return os.WriteFile(*file, buf.Bytes(), 0o644)
+}
+
+// importCmd applies a policy file, or plans it with -dry-run.
+func importCmd(c *client, out *printer, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	file := fs.String("f", "", "policy file (YAML or JSON), - for stdin")
+	dryRun := fs.Bool("dry-run", false, "show the changes and the plan's tag without applying them")
+	fs.StringVar(&c.ifPlan, "if-plan", "", "apply only if the plan still has this tag")
+	fs.StringVar(&c.reason, "reason", "", "why, recorded with the changed policies")
+	_ = fs.Parse(args)
+	if *file == "" {
+		return errors.New("-f is required")
+	}
+	data, err := readInput(*file)
+	if err != nil {
+		return err
+	}
+	path := "/policies/apply"
+	if *dryRun {
+		path = "/policies/plan"
+	}
+	var plan policyfile.Plan
+	err = c.do("POST", path, nil, data, &plan)
+	var apiErr *apiError
+	if errors.As(err, &apiErr) && apiErr.Status == http.StatusUnprocessableEntity {
+		// The server refused the file and sent the plan with its errors, or
+		// the change broke test cases.
+		if json.Unmarshal(apiErr.Body, &plan) != nil || len(plan.Errors) == 0 {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+	if out.format == "table" {
+		fmt.Print(plan.String())
+	} else if err := out.print(plan, nil, nil); err != nil {
+		return err
+	}
+	if len(plan.Errors) > 0 {
+		return errors.New("policy file is invalid; nothing was applied")
+	}
+	return nil
+}
@@ -65,6 +65,348 @@ func main() {
 				return tx.Exec(`ALTER TABLE policies DROP COLUMN IF EXISTS provider;`).Error
 			},
 		},
+		{
+			ID: "20251010_add_break_glass_to_audits",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.Exec(`ALTER TABLE policy_audits ADD COLUMN IF NOT EXISTS severity TEXT NOT NULL DEFAULT 'info';`).Error; err != nil {
+					return err
+				}
+				if err := tx.Exec(`ALTER TABLE policy_audits ADD COLUMN IF NOT EXISTS break_glass BOOLEAN NOT NULL DEFAULT false;`).Error; err != nil {
+					return err
+				}
+				return tx.Exec(`CREATE INDEX IF NOT EXISTS idx_policy_audits_break_glass ON policy_audits (created_at) WHERE break_glass;`).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				if err := tx.Exec(`DROP INDEX IF EXISTS idx_policy_audits_break_glass;`).Error; err != nil {
+					return err
+				}
+				return tx.Exec(`ALTER TABLE policy_audits DROP COLUMN IF EXISTS break_glass, DROP COLUMN IF EXISTS severity;`).Error
+			},
+		},
+		{
+			ID: "20251011_create_delegations",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(&model.Delegation{}); err != nil {
+					return err
+				}
+				return tx.Exec(`CREATE INDEX IF NOT EXISTS idx_delegations_active ON delegations (delegate, expires_at) WHERE revoked_at IS NULL;`).Error
+			},
+			Rollback: func(tx *gorm.DB) error { return tx.Migrator().DropTable("delegations") },
+		},
+		{
+			ID: "20251012_create_sod_constraints",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(&model.SoDConstraint{}); err != nil {
+					return err
+				}
+				// Dynamic constraints look up prior allows by subject and action.
+				return tx.Exec(`CREATE INDEX IF NOT EXISTS idx_policy_audits_subject_action ON policy_audits ((request->'subject'->>'id'), (request->>'action')) WHERE decision = 'allow';`).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				if err := tx.Exec(`DROP INDEX IF EXISTS idx_policy_audits_subject_action;`).Error; err != nil {
+					return err
+				}
+				return tx.Migrator().DropTable("sod_constraints")
+			},
+		},
+		{
+			ID: "20251013_create_sessions",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&model.Session{})
+			},
+			Rollback: func(tx *gorm.DB) error { return tx.Migrator().DropTable("sessions") },
+		},
+		{
+			ID: "20251014_notify_policy_changes",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.Exec(`
+CREATE OR REPLACE FUNCTION jit_notify_change() RETURNS trigger AS $$
+DECLARE
+	rec RECORD;
+	payload JSONB;
+BEGIN
+	IF TG_OP = 'DELETE' THEN
+		rec := OLD;
+	ELSE
+		rec := NEW;
+	END IF;
+	payload := jsonb_build_object('table', TG_TABLE_NAME, 'op', TG_OP, 'id', rec.id);
+	IF TG_TABLE_NAME = 'policies' THEN
+		payload := payload || jsonb_build_object('provider', rec.provider);
+		IF TG_OP = 'UPDATE' THEN
+			payload := payload || jsonb_build_object('old_provider', OLD.provider);
+		END IF;
+	END IF;
+	PERFORM pg_notify('jit_policy_changes', payload::text);
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;`).Error; err != nil {
+					return err
+				}
+				for _, table := range []string{"policies", "sod_constraints", "delegations"} {
+					if err := tx.Exec(`DROP TRIGGER IF EXISTS jit_notify_change ON ` + table + `;`).Error; err != nil {
+						return err
+					}
+					if err := tx.Exec(`CREATE TRIGGER jit_notify_change AFTER INSERT OR UPDATE OR DELETE ON ` + table + ` FOR EACH ROW EXECUTE FUNCTION jit_notify_change();`).Error; err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			Rollback: func(tx *gorm.DB) error {
+				for _, table := range []string{"policies", "sod_constraints", "delegations"} {
+					if err := tx.Exec(`DROP TRIGGER IF EXISTS jit_notify_change ON ` + table + `;`).Error; err != nil {
+						return err
+					}
+				}
+				return tx.Exec(`DROP FUNCTION IF EXISTS jit_notify_change();`).Error
+			},
+		},
+		{
+			ID: "20251015_add_resource_matchers_to_policies",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.Exec(`ALTER TABLE policies ADD COLUMN IF NOT EXISTS match_kind TEXT NOT NULL DEFAULT 'glob';`).Error; err != nil {
+					return err
+				}
+				if err := tx.Exec(`ALTER TABLE policies ADD COLUMN IF NOT EXISTS exclude_resources TEXT[];`).Error; err != nil {
+					return err
+				}
+				if err := tx.Exec(`ALTER TABLE policies ADD CONSTRAINT match_kind_check CHECK (match_kind IN ('glob','exact','prefix','regex'));`).Error; err != nil {
+					// ignore if exists
+				}
+				return nil
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Exec(`ALTER TABLE policies DROP CONSTRAINT IF EXISTS match_kind_check, DROP COLUMN IF EXISTS exclude_resources, DROP COLUMN IF EXISTS match_kind;`).Error
+			},
+		},
+		{
+			ID: "20251016_create_action_catalog",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(&model.ActionGroup{}, &model.ActionHierarchy{}); err != nil {
+					return err
+				}
+				// action_groups is keyed by name, so read id defensively.
+				if err := tx.Exec(`
+CREATE OR REPLACE FUNCTION jit_notify_change() RETURNS trigger AS $$
+DECLARE
+	rec RECORD;
+	payload JSONB;
+BEGIN
+	IF TG_OP = 'DELETE' THEN
+		rec := OLD;
+	ELSE
+		rec := NEW;
+	END IF;
+	payload := jsonb_build_object('table', TG_TABLE_NAME, 'op', TG_OP, 'id', to_jsonb(rec)->'id');
+	IF TG_TABLE_NAME = 'policies' THEN
+		payload := payload || jsonb_build_object('provider', rec.provider);
+		IF TG_OP = 'UPDATE' THEN
+			payload := payload || jsonb_build_object('old_provider', OLD.provider);
+		END IF;
+	END IF;
+	PERFORM pg_notify('jit_policy_changes', payload::text);
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;`).Error; err != nil {
+					return err
+				}
+				for _, table := range []string{"action_groups", "action_hierarchies"} {
+					if err := tx.Exec(`DROP TRIGGER IF EXISTS jit_notify_change ON ` + table + `;`).Error; err != nil {
+						return err
+					}
+					if err := tx.Exec(`CREATE TRIGGER jit_notify_change AFTER INSERT OR UPDATE OR DELETE ON ` + table + ` FOR EACH ROW EXECUTE FUNCTION jit_notify_change();`).Error; err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			Rollback: func(tx *gorm.DB) error { return tx.Migrator().DropTable("action_hierarchies", "action_groups") },
+		},
+		{
+			ID: "20251017_create_providers",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(&model.Provider{}); err != nil {
+					return err
+				}
+				// Seed the providers the handlers used to hard-code plus the
+				// platforms the README documents, then any provider already
+				// used by a policy so existing data stays valid.
+				if err := tx.Exec(`
+INSERT INTO providers (name, aliases, default_decision, description, created_at, updated_at) VALUES
+	('global', '{}', 'deny', 'Guardrails evaluated before every provider', now(), now()),
+	('breakglass', '{}', 'deny', 'Who may request emergency access', now(), now()),
+	('aws', '{}', 'deny', 'Amazon Web Services', now(), now()),
+	('gcp', '{}', 'deny', 'Google Cloud', now(), now()),
+	('azure', '{}', 'deny', 'Microsoft Azure', now(), now()),
+	('database', '{db}', 'deny', 'Databases', now(), now()),
+	('ssh', '{}', 'deny', 'SSH on Unix/Linux', now(), now()),
+	('rdp', '{}', 'deny', 'RDP on Windows', now(), now()),
+	('web', '{http,https}', 'deny', 'Web applications', now(), now()),
+	('network', '{}', 'deny', 'Routers, switches and firewalls', now(), now()),
+	('storage', '{}', 'deny', 'Storage systems', now(), now()),
+	('client', '{}', 'deny', 'Thick clients', now(), now()),
+	('mail', '{}', 'deny', 'Mail systems', now(), now()),
+	('hypervisor', '{}', 'deny', 'Hypervisor consoles', now(), now())
+ON CONFLICT (name) DO NOTHING;`).Error; err != nil {
+					return err
+				}
+				if err := tx.Exec(`
+INSERT INTO providers (name, aliases, default_decision, created_at, updated_at)
+SELECT DISTINCT provider, '{}'::text[], 'deny', now(), now() FROM policies
+ON CONFLICT (name) DO NOTHING;`).Error; err != nil {
+					return err
+				}
+				if err := tx.Exec(`DROP TRIGGER IF EXISTS jit_notify_change ON providers;`).Error; err != nil {
+					return err
+				}
+				return tx.Exec(`CREATE TRIGGER jit_notify_change AFTER INSERT OR UPDATE OR DELETE ON providers FOR EACH ROW EXECUTE FUNCTION jit_notify_change();`).Error
+			},
+			Rollback: func(tx *gorm.DB) error { return tx.Migrator().DropTable("providers") },
+		},
+		{
+			ID: "20251018_create_resolution_rules",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(&model.ResolutionRule{}); err != nil {
+					return err
+				}
+				if err := tx.Exec(`ALTER TABLE policy_audits ADD COLUMN IF NOT EXISTS providers TEXT[];`).Error; err != nil {
+					return err
+				}
+				if err := tx.Exec(`DROP TRIGGER IF EXISTS jit_notify_change ON resolution_rules;`).Error; err != nil {
+					return err
+				}
+				return tx.Exec(`CREATE TRIGGER jit_notify_change AFTER INSERT OR UPDATE OR DELETE ON resolution_rules FOR EACH ROW EXECUTE FUNCTION jit_notify_change();`).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				if err := tx.Exec(`ALTER TABLE policy_audits DROP COLUMN IF EXISTS providers;`).Error; err != nil {
+					return err
+				}
+				return tx.Migrator().DropTable("resolution_rules")
+			},
+		},
+		{
+			ID: "20251019_create_policy_test_cases",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(&model.PolicyTestCase{}); err != nil {
+					return err
+				}
+				return tx.Exec(`ALTER TABLE policy_test_cases ADD CONSTRAINT fk_policy_test_cases_policy FOREIGN KEY (policy_id) REFERENCES policies(id) ON DELETE CASCADE;`).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("policy_test_cases")
+			},
+		},
+		{
+			ID: "20251020_create_policy_versions",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(&model.PolicyVersion{}); err != nil {
+					return err
+				}
+				if err := tx.Exec(`
+CREATE OR REPLACE FUNCTION jit_policy_versions_immutable() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'policy versions are immutable';
+END;
+$$ LANGUAGE plpgsql;`).Error; err != nil {
+					return err
+				}
+				if err := tx.Exec(`CREATE TRIGGER jit_policy_versions_immutable BEFORE UPDATE OR DELETE ON policy_versions FOR EACH ROW EXECUTE FUNCTION jit_policy_versions_immutable();`).Error; err != nil {
+					return err
+				}
+				// Existing policies start their history at their current version
+				return tx.Exec(`
+INSERT INTO policy_versions (policy_id, version, operation, policy, reason, created_at)
+SELECT id, version, 'create', to_jsonb(p), 'recorded when version history was introduced', updated_at
+FROM policies p;`).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				if err := tx.Migrator().DropTable("policy_versions"); err != nil {
+					return err
+				}
+				return tx.Exec(`DROP FUNCTION IF EXISTS jit_policy_versions_immutable();`).Error
+			},
+		},
+		{
+			ID: "20251021_notify_session_revocations",
+			Migrate: func(tx *gorm.DB) error {
+				// Every replica announces the revocations to its own subscribers.
+				if err := tx.Exec(`
+CREATE OR REPLACE FUNCTION jit_notify_session_revoked() RETURNS trigger AS $$
+BEGIN
+	PERFORM pg_notify('jit_policy_changes', jsonb_build_object(
+		'table', TG_TABLE_NAME, 'op', TG_OP,
+		'session', jsonb_build_object('type', 'revoked', 'session_id', NEW.id, 'subject_id', NEW.subject_id,
+			'resource', NEW.resource, 'action', NEW.action, 'reason', left(NEW.reason, 4000), 'time', NEW.revoked_at)
+	)::text);
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;`).Error; err != nil {
+					return err
+				}
+				return tx.Exec(`CREATE TRIGGER jit_notify_session_revoked AFTER UPDATE ON sessions FOR EACH ROW
+WHEN (OLD.status = 'active' AND NEW.status = 'revoked') EXECUTE FUNCTION jit_notify_session_revoked();`).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				if err := tx.Exec(`DROP TRIGGER IF EXISTS jit_notify_session_revoked ON sessions;`).Error; err != nil {
+					return err
+				}
+				return tx.Exec(`DROP FUNCTION IF EXISTS jit_notify_session_revoked();`).Error
+			},
+		},
+		{
+			ID: "20251022_notify_change_origin",
+			Migrate: func(tx *gorm.DB) error {
+				// The origin lets the replica that made a change skip reapplying it.
+				return tx.Exec(`
+CREATE OR REPLACE FUNCTION jit_notify_change() RETURNS trigger AS $$
+DECLARE
+	rec RECORD;
+	payload JSONB;
+BEGIN
+	IF TG_OP = 'DELETE' THEN
+		rec := OLD;
+	ELSE
+		rec := NEW;
+	END IF;
+	payload := jsonb_build_object('table', TG_TABLE_NAME, 'op', TG_OP, 'id', to_jsonb(rec)->'id',
+		'origin', current_setting('application_name', true));
+	IF TG_TABLE_NAME = 'policies' THEN
+		payload := payload || jsonb_build_object('provider', rec.provider);
+		IF TG_OP = 'UPDATE' THEN
+			payload := payload || jsonb_build_object('old_provider', OLD.provider);
+		END IF;
+	END IF;
+	PERFORM pg_notify('jit_policy_changes', payload::text);
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;`).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Exec(`
+CREATE OR REPLACE FUNCTION jit_notify_change() RETURNS trigger AS $$
+DECLARE
+	rec RECORD;
+	payload JSONB;
+BEGIN
+	IF TG_OP = 'DELETE' THEN
+		rec := OLD;
+	ELSE
+		rec := NEW;
+	END IF;
+	payload := jsonb_build_object('table', TG_TABLE_NAME, 'op', TG_OP, 'id', to_jsonb(rec)->'id');
+	IF TG_TABLE_NAME = 'policies' THEN
+		payload := payload || jsonb_build_object('provider', rec.provider);
+		IF TG_OP = 'UPDATE' THEN
+			payload := payload || jsonb_build_object('old_provider', OLD.provider);
+		END IF;
+	END IF;
+	PERFORM pg_notify('jit_policy_changes', payload::text);
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;`).Error
+			},
+		},
 	})
 
 	if err := m.Migrate(); err != nil {
@@ -0,0 +1,139 @@
+// Command policysync syncs a YAML or JSON policy file to the database.
+//
+//	policysync plan  -f policies.yaml
+//	policysync apply -f policies.yaml [-if-plan TAG] [-conflicts MODE] [-author A] [-reason R]
+//
+// plan prints the changes and the plan's tag; apply makes them in one
+// transaction, gated like the server's policy writes: it refuses when the
+// file is invalid, when the stored test cases break, on conflicts with
+// -conflicts strict, and with -if-plan when the plan is no longer the one
+// printed. The versions it records name -author (default $USER) and -reason.
+// Running servers pick the changes up through the change feed.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/joho/godotenv"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+
+	"example.com/jit-engine/internal/eval"
+	"example.com/jit-engine/internal/gate"
+	"example.com/jit-engine/internal/model"
+	"example.com/jit-engine/internal/policyfile"
+	"example.com/jit-engine/internal/store"
+)
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 || (os.Args[1] != "plan" && os.Args[1] != "apply") {
+		log.Fatal("usage: policysync plan|apply -f FILE")
+	}
+	godotenv.Load()
+	cmd := os.Args[1]
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+	path := fs.String("f", "", "policy file (YAML or JSON), - for stdin")
+	ifPlan := fs.String("if-plan", "", "apply only if the plan still has this tag")
+	mode := os.Getenv("POLICY_CONFLICT_MODE")
+	if mode == "" {
+		mode = gate.ConflictsWarn
+	}
+	conflictMode := fs.String("conflicts", mode, "conflicts with existing policies: warn, strict or off")
+	author := fs.String("author", os.Getenv("USER"), "who makes the change, recorded with the changed policies")
+	reason := fs.String("reason", "policysync", "why, recorded with the changed policies")
+	_ = fs.Parse(os.Args[2:])
+	if *path == "" {
+		log.Fatal("-f is required")
+	}
+	if !gate.ValidMode(*conflictMode) {
+		log.Fatalf("invalid -conflicts %q (want warn, strict or off)", *conflictMode)
+	}
+
+	data, err := readFile(*path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	f, err := policyfile.Parse(data)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db, err := gorm.Open(postgres.Open(os.Getenv("DATABASE_URL")), &gorm.Config{})
+	if err != nil {
+		log.Fatal(err)
+	}
+	s := store.NewPostgres(db)
+
+	plan, err := policyfile.NewPlan(f, s)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if cmd == "plan" || len(plan.Errors) > 0 {
+		fmt.Print(plan.String())
+		if len(plan.Errors) > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+	if *ifPlan != "" && *ifPlan != plan.Tag {
+		log.Fatalf("policies changed since plan %s; plan again", *ifPlan)
+	}
+
+	// The stored test cases are decided by the current policies first.
+	eng, err := eval.NewEngine(s, s, true)
+	if err != nil {
+		log.Fatal(err)
+	}
+	tag, change := plan.Tag, store.Change{Author: *author, Reason: *reason}
+	conflicts, err := gate.Gate{Engine: eng, ConflictMode: *conflictMode}.Write(s, plan.Deleted(), func(tx store.PolicyStore) ([]model.Policy, error) {
+		var err error
+		if plan, err = policyfile.NewPlan(f, tx); err != nil {
+			return nil, err
+		}
+		if plan.Tag != tag {
+			return nil, store.ErrVersionConflict
+		}
+		return plan.Write(tx, change)
+	})
+	if err != nil {
+		fail(err)
+	}
+	fmt.Print(plan.String())
+	for _, c := range conflicts {
+		log.Printf("warning: %s", c.Message)
+	}
+}
+
+// fail explains why apply wrote nothing and exits.
+func fail(err error) {
+	var (
+		regression *gate.RegressionError
+		conflict   *gate.ConflictError
+	)
+	switch {
+	case errors.As(err, &regression):
+		for _, b := range regression.Broken {
+			log.Printf("test case %s: %v", b.Name, b.Failures)
+		}
+	case errors.As(err, &conflict):
+		for _, c := range conflict.Conflicts {
+			log.Printf("conflict: %s", c.Message)
+		}
+	case errors.Is(err, store.ErrVersionConflict):
+		err = errors.New("policies changed while applying; plan again")
+	}
+	log.Fatalf("nothing applied: %v", err)
+}
+
+func readFile(path string) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(path)
+}
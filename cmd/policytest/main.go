@@ -0,0 +1,78 @@
+// Command policytest runs policy test files against a policy directory or a
+// live server.
+//
+//	policytest -policies ./policies ./tests
+//	policytest -server http://localhost:8080 -junit report.xml ./tests
+//
+// Test paths default to the policy directory, so a repository keeping
+// *_test.yaml files beside its policies runs with just -policies. It exits 1
+// when a test fails.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"example.com/jit-engine/internal/policytest"
+)
+
+func main() {
+	log.SetFlags(0)
+	policies := flag.String("policies", "", "directory or file of policy files to evaluate locally")
+	server := flag.String("server", "", "evaluate against this server instead")
+	token := flag.String("token", os.Getenv("JITCTL_TOKEN"), "bearer token for -server")
+	junit := flag.String("junit", "", "write a JUnit XML report to this file")
+	verbose := flag.Bool("v", false, "list passing tests too")
+	flag.Parse()
+	if (*policies == "") == (*server == "") {
+		log.Fatal("exactly one of -policies and -server is required")
+	}
+	paths := flag.Args()
+	if len(paths) == 0 {
+		if *policies == "" {
+			log.Fatal("test paths are required with -server")
+		}
+		paths = []string{*policies}
+	}
+
+	suites, err := policytest.LoadSuites(paths...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var ev policytest.Evaluator
+	if *server != "" {
+		ev = &policytest.Remote{URL: *server, Token: *token}
+	} else if ev, err = policytest.NewLocal(*policies); err != nil {
+		log.Fatal(err)
+	}
+	rep, err := policytest.Run(ev, suites)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *verbose {
+		for i := range rep.Outcomes {
+			if o := &rep.Outcomes[i]; o.Passed() {
+				fmt.Printf("ok   %s: %s\n", o.Suite, o.Case.Name)
+			}
+		}
+	}
+	fmt.Print(rep.String())
+	if *junit != "" {
+		f, err := os.Create(*junit)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := rep.WriteJUnit(f); err != nil {
+			log.Fatal(err)
+		}
+		if err := f.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if rep.Failed() > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+
+	"example.com/jit-engine/internal/bundle"
+	"example.com/jit-engine/internal/httpapi"
+	"example.com/jit-engine/internal/store"
+)
+
+// serveBundle evaluates a signed bundle without Postgres. Only the evaluation
+// and introspection endpoints are served; policies change by deploying a new
+// bundle and restarting. Decisions are recorded in the SQLite database at
+// BUNDLE_AUDIT_DB.
+func serveBundle(path string, failClosed bool) {
+	trusted, err := bundle.ParsePublicKeys(os.Getenv("BUNDLE_TRUSTED_KEYS"))
+	if err != nil {
+		log.Fatal("invalid BUNDLE_TRUSTED_KEYS: ", err)
+	}
+	auditPath := os.Getenv("BUNDLE_AUDIT_DB")
+	if auditPath == "" {
+		auditPath = "audit.db"
+	}
+	audits, err := store.OpenSQLite(auditPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	eng, b, err := bundle.NewEngine(path, trusted, audits, failClosed)
+	if err != nil {
+		log.Fatal(err)
+	}
+	configureEngine(eng)
+	log.Printf("serving bundle %s (key %s)", b.Manifest.Version, b.Manifest.KeyID)
+
+	mux := http.NewServeMux()
+	mux.Handle("/evaluate", &httpapi.EvalHandler{Engine: eng})
+	mux.Handle("/cache/stats", &httpapi.CacheStatsHandler{Engine: eng})
+	mux.HandleFunc("/admin/snapshot", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		(&httpapi.SnapshotHandler{Engine: eng}).Info(w, r)
+	})
+
+	addr := os.Getenv("ADDR")
+	if addr == "" {
+		addr = ":8080"
+	}
+	log.Println("listening on", addr)
+	log.Fatal(http.ListenAndServe(addr, mux))
+}
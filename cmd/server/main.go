@@ -1,12 +1,25 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
+	"example.com/jit-engine/internal/bundle"
+	"example.com/jit-engine/internal/changefeed"
 	"example.com/jit-engine/internal/eval"
+	"example.com/jit-engine/internal/gate"
 	"example.com/jit-engine/internal/httpapi"
+	"example.com/jit-engine/internal/impact"
+	"example.com/jit-engine/internal/notify"
+	"example.com/jit-engine/internal/session"
+	"example.com/jit-engine/internal/store"
+	"github.com/google/uuid"
 	"github.com/joho/godotenv"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -14,29 +27,113 @@ import (
 
 func main() {
 	godotenv.Load()
-	dsn := os.Getenv("DATABASE_URL")
-	if dsn == "" {
-		log.Fatal("DATABASE_URL is required")
-	}
 	failClosed := true
 	if v := os.Getenv("FAIL_CLOSED"); v == "false" || v == "0" {
 		failClosed = false
 	}
+	if path := os.Getenv("POLICY_BUNDLE"); path != "" {
+		serveBundle(path, failClosed)
+		return
+	}
+	// dsn stays empty unless the store is Postgres, the only one with a
+	// change feed.
+	var (
+		policies    store.Store
+		dsn, origin string
+		err         error
+	)
+	switch kind := os.Getenv("STORE"); kind {
+	case "", "postgres":
+		if dsn = os.Getenv("DATABASE_URL"); dsn == "" {
+			log.Fatal("DATABASE_URL is required")
+		}
+		// The change feed recognizes this replica's own writes by application_name.
+		origin = "jit-engine-" + uuid.NewString()[:8]
+		db, err := gorm.Open(postgres.Open(withApplicationName(dsn, origin)), &gorm.Config{})
+		if err != nil {
+			log.Fatal(err)
+		}
+		policies = store.NewPostgres(db)
+	case "sqlite":
+		path := os.Getenv("SQLITE_PATH")
+		if path == "" {
+			path = "jit.db"
+		}
+		if policies, err = store.OpenSQLite(path); err != nil {
+			log.Fatal(err)
+		}
+	case "memory":
+		policies = store.NewMemory()
+	default:
+		log.Fatalf("invalid STORE %q (want postgres, sqlite or memory)", kind)
+	}
 
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	eng, err := eval.NewEngine(policies, policies, failClosed)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	eng, err := eval.NewEvalEngine(db, failClosed)
-	if err != nil {
-		log.Fatal(err)
+	configureEngine(eng)
+
+	refresh := time.Minute
+	if v := os.Getenv("POLICY_REFRESH_INTERVAL"); v != "" {
+		if refresh, err = time.ParseDuration(v); err != nil {
+			log.Fatal("invalid POLICY_REFRESH_INTERVAL: ", err)
+		}
+	}
+	if refresh > 0 {
+		go eng.RunRefresh(context.Background(), refresh)
+	}
+
+	sessions := session.NewRegistry(policies, eng)
+	go sessions.Run(context.Background())
+
+	if v := os.Getenv("POLICY_CHANGEFEED"); dsn != "" && v != "false" && v != "0" {
+		resync := 15 * time.Minute
+		if v := os.Getenv("POLICY_RESYNC_INTERVAL"); v != "" {
+			if resync, err = time.ParseDuration(v); err != nil {
+				log.Fatal("invalid POLICY_RESYNC_INTERVAL: ", err)
+			}
+		}
+		feed := &changefeed.Listener{DSN: dsn, Engine: eng, Sessions: sessions, Origin: origin, ResyncInterval: resync}
+		go func() {
+			if err := feed.Run(context.Background()); err != nil {
+				log.Printf("changefeed stopped: %v", err)
+			}
+		}()
+	}
+
+	conflictMode := os.Getenv("POLICY_CONFLICT_MODE")
+	if conflictMode == "" {
+		conflictMode = gate.ConflictsWarn
+	} else if !gate.ValidMode(conflictMode) {
+		log.Fatalf("invalid POLICY_CONFLICT_MODE %q (want warn, strict or off)", conflictMode)
+	}
+
+	requireIfMatch := false
+	if v := os.Getenv("POLICY_REQUIRE_IF_MATCH"); v == "true" || v == "1" {
+		requireIfMatch = true
 	}
 
 	mux := http.NewServeMux()
 	mux.Handle("/evaluate", &httpapi.EvalHandler{Engine: eng})
+	mux.Handle("/cache/stats", &httpapi.CacheStatsHandler{Engine: eng})
+	mux.HandleFunc("/admin/snapshot", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		(&httpapi.SnapshotHandler{Engine: eng}).Info(w, r)
+	})
+	mux.HandleFunc("/admin/reload", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		(&httpapi.SnapshotHandler{Engine: eng}).Reload(w, r)
+	})
 	mux.HandleFunc("/policies", func(w http.ResponseWriter, r *http.Request) {
-		h := &httpapi.PolicyHandler{DB: db, Engine: eng}
+		h := &httpapi.PolicyHandler{Store: policies, Engine: eng, Sessions: sessions, ConflictMode: conflictMode, RequireIfMatch: requireIfMatch}
 		switch r.Method {
 		case http.MethodPost:
 			h.Create(w, r)
@@ -46,8 +143,40 @@ func main() {
 			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		}
 	})
+	mux.HandleFunc("/policies/plan", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		(&httpapi.PolicyFileHandler{Store: policies, Engine: eng, Sessions: sessions, ConflictMode: conflictMode, RequireIfMatch: requireIfMatch}).Plan(w, r)
+	})
+	mux.HandleFunc("/policies/apply", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		(&httpapi.PolicyFileHandler{Store: policies, Engine: eng, Sessions: sessions, ConflictMode: conflictMode, RequireIfMatch: requireIfMatch}).Apply(w, r)
+	})
+	mux.HandleFunc("/policies/conflicts", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		(&httpapi.ConflictHandler{Store: policies}).List(w, r)
+	})
+	lintHandler := &httpapi.LintHandler{Store: policies, SubjectFields: splitList(os.Getenv("LINT_SUBJECT_FIELDS"))}
+	mux.HandleFunc("/policies/lint", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			lintHandler.Stored(w, r)
+		case http.MethodPost:
+			lintHandler.File(w, r)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
 	mux.HandleFunc("/policies/", func(w http.ResponseWriter, r *http.Request) {
-		h := &httpapi.PolicyHandler{DB: db, Engine: eng}
+		h := &httpapi.PolicyHandler{Store: policies, Engine: eng, Sessions: sessions, ConflictMode: conflictMode, RequireIfMatch: requireIfMatch}
 		// If the path is exactly "/policies/", treat like collection
 		if r.URL.Path == "/policies/" {
 			switch r.Method {
@@ -60,6 +189,32 @@ func main() {
 			}
 			return
 		}
+		if strings.HasSuffix(r.URL.Path, "/actions") {
+			if r.Method != http.MethodGet {
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			(&httpapi.ActionHandler{Actions: policies, Store: policies, Engine: eng}).PolicyActions(w, r)
+			return
+		}
+		// Version history: /policies/{id}/versions[/{n}], /diff and /rollback
+		if sub := policySubroute(r.URL.Path); sub != "" {
+			method, handle := http.MethodGet, h.Versions
+			switch sub {
+			case "version":
+				handle = h.Version
+			case "diff":
+				handle = h.Diff
+			case "rollback":
+				method, handle = http.MethodPost, h.Rollback
+			}
+			if r.Method != method {
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			handle(w, r)
+			return
+		}
 		// Else it's expected to be item route
 		switch r.Method {
 		case http.MethodGet:
@@ -73,6 +228,242 @@ func main() {
 		}
 	})
 
+	mux.HandleFunc("/reports/coverage", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		(&httpapi.ReportHandler{Audits: policies, Store: policies}).Coverage(w, r)
+	})
+	impactJobs := &impact.Manager{Audits: policies, Store: policies, Engine: eng}
+	mux.HandleFunc("/impact", func(w http.ResponseWriter, r *http.Request) {
+		h := &httpapi.ImpactHandler{Jobs: impactJobs}
+		switch r.Method {
+		case http.MethodPost:
+			h.Create(w, r)
+		case http.MethodGet:
+			h.List(w, r)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("/impact/", func(w http.ResponseWriter, r *http.Request) {
+		h := &httpapi.ImpactHandler{Jobs: impactJobs}
+		switch r.Method {
+		case http.MethodGet:
+			h.Get(w, r)
+		case http.MethodDelete:
+			h.Cancel(w, r)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("/audits", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		(&httpapi.AuditHandler{Audits: policies}).List(w, r)
+	})
+
+	if v := os.Getenv("BUNDLE_SIGNING_KEY"); v != "" {
+		key, err := bundle.ParsePrivateKey(v)
+		if err != nil {
+			log.Fatal("invalid BUNDLE_SIGNING_KEY: ", err)
+		}
+		mux.HandleFunc("/bundle", func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet {
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			(&httpapi.BundleHandler{Store: policies, Key: key}).Get(w, r)
+		})
+	}
+
+	mux.HandleFunc("/policy-tests", func(w http.ResponseWriter, r *http.Request) {
+		h := &httpapi.PolicyTestHandler{Tests: policies, Store: policies, Engine: eng}
+		switch r.Method {
+		case http.MethodPost:
+			h.Create(w, r)
+		case http.MethodGet:
+			h.List(w, r)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("/policy-tests/run", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		(&httpapi.PolicyTestHandler{Tests: policies, Store: policies, Engine: eng}).Run(w, r)
+	})
+	mux.HandleFunc("/policy-tests/", func(w http.ResponseWriter, r *http.Request) {
+		h := &httpapi.PolicyTestHandler{Tests: policies, Store: policies, Engine: eng}
+		switch r.Method {
+		case http.MethodPut:
+			h.Update(w, r)
+		case http.MethodDelete:
+			h.Delete(w, r)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+
+	mux.HandleFunc("/breakglass", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		(&httpapi.BreakGlassHandler{Audits: policies}).List(w, r)
+	})
+
+	mux.HandleFunc("/providers", func(w http.ResponseWriter, r *http.Request) {
+		h := &httpapi.ProviderHandler{Store: policies, Engine: eng}
+		switch r.Method {
+		case http.MethodPost:
+			h.Create(w, r)
+		case http.MethodGet:
+			h.List(w, r)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("/providers/", func(w http.ResponseWriter, r *http.Request) {
+		h := &httpapi.ProviderHandler{Store: policies, Engine: eng}
+		switch r.Method {
+		case http.MethodGet:
+			h.Get(w, r)
+		case http.MethodPut:
+			h.Update(w, r)
+		case http.MethodDelete:
+			h.Delete(w, r)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("/resolution-rules", func(w http.ResponseWriter, r *http.Request) {
+		h := &httpapi.ResolutionRuleHandler{Rules: policies, Store: policies, Engine: eng}
+		switch r.Method {
+		case http.MethodPost:
+			h.Create(w, r)
+		case http.MethodGet:
+			h.List(w, r)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("/resolution-rules/", func(w http.ResponseWriter, r *http.Request) {
+		h := &httpapi.ResolutionRuleHandler{Rules: policies, Store: policies, Engine: eng}
+		switch r.Method {
+		case http.MethodPut:
+			h.Update(w, r)
+		case http.MethodDelete:
+			h.Delete(w, r)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("/action-groups", func(w http.ResponseWriter, r *http.Request) {
+		h := &httpapi.ActionHandler{Actions: policies, Store: policies, Engine: eng}
+		switch r.Method {
+		case http.MethodPost, http.MethodPut:
+			h.PutGroup(w, r)
+		case http.MethodGet:
+			h.ListGroups(w, r)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("/action-groups/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		(&httpapi.ActionHandler{Actions: policies, Store: policies, Engine: eng}).DeleteGroup(w, r)
+	})
+	mux.HandleFunc("/action-hierarchies", func(w http.ResponseWriter, r *http.Request) {
+		h := &httpapi.ActionHandler{Actions: policies, Store: policies, Engine: eng}
+		switch r.Method {
+		case http.MethodPost, http.MethodPut:
+			h.PutHierarchy(w, r)
+		case http.MethodGet:
+			h.ListHierarchies(w, r)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("/action-hierarchies/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		(&httpapi.ActionHandler{Actions: policies, Store: policies, Engine: eng}).DeleteHierarchy(w, r)
+	})
+	mux.HandleFunc("/delegations", func(w http.ResponseWriter, r *http.Request) {
+		h := &httpapi.DelegationHandler{Store: policies, Policies: policies, Engine: eng, Audits: policies, Sessions: sessions}
+		switch r.Method {
+		case http.MethodPost:
+			h.Create(w, r)
+		case http.MethodGet:
+			h.List(w, r)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("/delegations/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		(&httpapi.DelegationHandler{Store: policies, Engine: eng, Sessions: sessions}).Revoke(w, r)
+	})
+
+	mux.HandleFunc("/sod-constraints", func(w http.ResponseWriter, r *http.Request) {
+		h := &httpapi.SoDHandler{Store: policies, Engine: eng}
+		switch r.Method {
+		case http.MethodPost:
+			h.Create(w, r)
+		case http.MethodGet:
+			h.List(w, r)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("/sod-constraints/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		(&httpapi.SoDHandler{Store: policies, Engine: eng}).Delete(w, r)
+	})
+
+	mux.HandleFunc("/sessions", func(w http.ResponseWriter, r *http.Request) {
+		h := &httpapi.SessionHandler{Store: policies, Registry: sessions}
+		switch r.Method {
+		case http.MethodPost:
+			h.Register(w, r)
+		case http.MethodGet:
+			h.List(w, r)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("/sessions/events", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		(&httpapi.SessionHandler{Store: policies, Registry: sessions}).Events(w, r)
+	})
+	mux.HandleFunc("/sessions/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		(&httpapi.SessionHandler{Store: policies, Registry: sessions}).Close(w, r)
+	})
+
 	// Service-specific policy creation endpoints
 
 	addr := os.Getenv("ADDR")
@@ -82,3 +473,81 @@ func main() {
 	log.Println("listening on", addr)
 	log.Fatal(http.ListenAndServe(addr, mux))
 }
+
+// configureEngine applies the break-glass, delegation and decision cache
+// settings from the environment.
+func configureEngine(eng *eval.EvalEngine) {
+	bg := eval.BreakGlassConfig{}
+	if v := os.Getenv("BREAK_GLASS_TTL"); v != "" {
+		ttl, err := time.ParseDuration(v)
+		if err != nil {
+			log.Fatal("invalid BREAK_GLASS_TTL: ", err)
+		}
+		bg.TTL = ttl
+	}
+	if v := os.Getenv("BREAK_GLASS_WEBHOOK_URL"); v != "" {
+		bg.Notifiers = append(bg.Notifiers, &notify.Webhook{URL: v})
+	}
+	if v := os.Getenv("BREAK_GLASS_NOTIFY_FILE"); v != "" {
+		bg.Notifiers = append(bg.Notifiers, &notify.FileSink{Path: v})
+	}
+	eng.ConfigureBreakGlass(bg)
+	if v := os.Getenv("DELEGATION_MAX_CHAIN"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			log.Fatal("invalid DELEGATION_MAX_CHAIN: ", err)
+		}
+		eng.ConfigureDelegation(n)
+	}
+	if v := os.Getenv("DECISION_CACHE_SIZE"); v != "" {
+		size, err := strconv.Atoi(v)
+		if err != nil {
+			log.Fatal("invalid DECISION_CACHE_SIZE: ", err)
+		}
+		var ttl time.Duration
+		if v := os.Getenv("DECISION_CACHE_TTL"); v != "" {
+			if ttl, err = time.ParseDuration(v); err != nil {
+				log.Fatal("invalid DECISION_CACHE_TTL: ", err)
+			}
+		}
+		eng.EnableDecisionCache(size, ttl)
+	}
+}
+
+// withApplicationName sets application_name in a URL or keyword/value DSN.
+func withApplicationName(dsn, name string) string {
+	if u, err := url.Parse(dsn); err == nil && (u.Scheme == "postgres" || u.Scheme == "postgresql") {
+		q := u.Query()
+		q.Set("application_name", name)
+		u.RawQuery = q.Encode()
+		return u.String()
+	}
+	return dsn + " application_name=" + name
+}
+
+// splitList splits a comma-separated setting, dropping empty entries.
+func splitList(v string) []string {
+	var out []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// policySubroute names the version history route under /policies/{id}/, or
+// returns "" for the policy itself.
+func policySubroute(path string) string {
+	switch {
+	case strings.HasSuffix(path, "/versions"):
+		return "versions"
+	case strings.Contains(path, "/versions/"):
+		return "version"
+	case strings.HasSuffix(path, "/diff"):
+		return "diff"
+	case strings.HasSuffix(path, "/rollback"):
+		return "rollback"
+	}
+	return ""
+}
@@ -0,0 +1,335 @@
+// Package bundle writes and reads signed policy bundles: a gzipped tar of
+// JSON files (policies, providers, action catalog, resolution rules and SoD
+// constraints) described by a manifest that is signed with ed25519. There is
+// no variables file: policy expressions see only the request's variables,
+// and the store keeps none of its own.
+package bundle
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"example.com/jit-engine/internal/model"
+	"example.com/jit-engine/internal/store"
+)
+
+// FormatVersion is the bundle layout this package writes and reads.
+const FormatVersion = 1
+
+const (
+	manifestFile  = "manifest.json"
+	signatureFile = "manifest.sig"
+	// maxSize bounds a bundle's decompressed size, all files together.
+	maxSize = 256 << 20
+)
+
+// Contents is everything a bundle carries.
+type Contents struct {
+	Policies          []model.Policy          `json:"policies"`
+	Providers         []model.Provider        `json:"providers"`
+	ActionGroups      []model.ActionGroup     `json:"action_groups"`
+	ActionHierarchies []model.ActionHierarchy `json:"action_hierarchies"`
+	ResolutionRules   []model.ResolutionRule  `json:"resolution_rules"`
+	SoDConstraints    []model.SoDConstraint   `json:"sod_constraints"`
+}
+
+// Manifest describes a bundle. Files maps each member to its SHA-256, so the
+// signature over the manifest covers every file.
+type Manifest struct {
+	Format    int               `json:"format"`
+	Version   string            `json:"version"`
+	CreatedAt time.Time         `json:"created_at"`
+	KeyID     string            `json:"key_id"`
+	Files     map[string]string `json:"files"`
+	Counts    map[string]int    `json:"counts"`
+}
+
+// Bundle is a verified bundle.
+type Bundle struct {
+	Manifest Manifest
+	Contents Contents
+}
+
+var (
+	ErrUnsigned     = errors.New("bundle is not signed")
+	ErrUntrusted    = errors.New("bundle is not signed by a trusted key")
+	ErrBadSignature = errors.New("bundle signature does not verify")
+)
+
+// Export reads the current contents of s. Disabled resolution rules and SoD
+// constraints have no effect on decisions and are left out.
+func Export(s store.PolicyStore) (*Contents, error) {
+	var c Contents
+	var err error
+	if c.Policies, err = s.ListPolicies(store.PolicyFilter{}); err != nil {
+		return nil, err
+	}
+	if c.Providers, err = s.Providers(); err != nil {
+		return nil, err
+	}
+	if c.ActionGroups, err = s.ActionGroups(); err != nil {
+		return nil, err
+	}
+	if c.ActionHierarchies, err = s.ActionHierarchies(); err != nil {
+		return nil, err
+	}
+	if c.ResolutionRules, err = s.EnabledResolutionRules(); err != nil {
+		return nil, err
+	}
+	if c.SoDConstraints, err = s.EnabledSoDConstraints(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// members splits c into the bundle's files.
+func (c *Contents) members() (map[string][]byte, map[string]int, error) {
+	parts := map[string]any{
+		"policies.json":           c.Policies,
+		"providers.json":          c.Providers,
+		"action_groups.json":      c.ActionGroups,
+		"action_hierarchies.json": c.ActionHierarchies,
+		"resolution_rules.json":   c.ResolutionRules,
+		"sod_constraints.json":    c.SoDConstraints,
+	}
+	files := map[string][]byte{}
+	counts := map[string]int{
+		"policies":           len(c.Policies),
+		"providers":          len(c.Providers),
+		"action_groups":      len(c.ActionGroups),
+		"action_hierarchies": len(c.ActionHierarchies),
+		"resolution_rules":   len(c.ResolutionRules),
+		"sod_constraints":    len(c.SoDConstraints),
+	}
+	for name, v := range parts {
+		b, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return nil, nil, err
+		}
+		files[name] = b
+	}
+	return files, counts, nil
+}
+
+// Digest identifies c: equal contents have equal digests whatever the
+// bundle's version or signing time.
+func (c *Contents) Digest() (string, error) {
+	files, _, err := c.members()
+	if err != nil {
+		return "", err
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	h := sha256.New()
+	for _, name := range names {
+		sum := sha256.Sum256(files[name])
+		fmt.Fprintf(h, "%s %x\n", name, sum)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Write signs c with key and writes the bundle to w.
+func Write(w io.Writer, c *Contents, version string, key ed25519.PrivateKey) (Manifest, error) {
+	files, counts, err := c.members()
+	if err != nil {
+		return Manifest{}, err
+	}
+	m := Manifest{
+		Format:    FormatVersion,
+		Version:   version,
+		CreatedAt: time.Now().UTC(),
+		KeyID:     KeyID(key.Public().(ed25519.PublicKey)),
+		Files:     map[string]string{},
+		Counts:    counts,
+	}
+	for name, b := range files {
+		sum := sha256.Sum256(b)
+		m.Files[name] = hex.EncodeToString(sum[:])
+	}
+	mb, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return Manifest{}, err
+	}
+	files[manifestFile] = mb
+	files[signatureFile] = []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(key, mb)))
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		hdr := &tar.Header{Name: name, Mode: 0o644, Size: int64(len(files[name])), ModTime: m.CreatedAt}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return Manifest{}, err
+		}
+		if _, err := tw.Write(files[name]); err != nil {
+			return Manifest{}, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return Manifest{}, err
+	}
+	return m, gz.Close()
+}
+
+// Read reads a bundle and verifies its signature against trusted and every
+// file against the manifest.
+func Read(r io.Reader, trusted []ed25519.PublicKey) (*Bundle, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("bundle: %w", err)
+	}
+	tr := tar.NewReader(gz)
+	files := map[string][]byte{}
+	left := int64(maxSize)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("bundle: %w", err)
+		}
+		b, err := io.ReadAll(io.LimitReader(tr, left+1))
+		if err != nil {
+			return nil, fmt.Errorf("bundle: %w", err)
+		}
+		if left -= int64(len(hdr.Name) + len(b)); left < 0 {
+			return nil, fmt.Errorf("bundle: larger than %d MiB decompressed", maxSize>>20)
+		}
+		files[hdr.Name] = b
+	}
+
+	mb, ok := files[manifestFile]
+	if !ok {
+		return nil, errors.New("bundle: missing manifest")
+	}
+	sig, ok := files[signatureFile]
+	if !ok {
+		return nil, ErrUnsigned
+	}
+	if err := verify(mb, sig, trusted); err != nil {
+		return nil, err
+	}
+	var b Bundle
+	if err := json.Unmarshal(mb, &b.Manifest); err != nil {
+		return nil, fmt.Errorf("bundle: manifest: %w", err)
+	}
+	if b.Manifest.Format != FormatVersion {
+		return nil, fmt.Errorf("bundle: unsupported format %d", b.Manifest.Format)
+	}
+	for name, digest := range b.Manifest.Files {
+		data, ok := files[name]
+		if !ok {
+			return nil, fmt.Errorf("bundle: missing %s", name)
+		}
+		sum := sha256.Sum256(data)
+		if hex.EncodeToString(sum[:]) != digest {
+			return nil, fmt.Errorf("bundle: %s does not match the manifest", name)
+		}
+	}
+	targets := map[string]any{
+		"policies.json":           &b.Contents.Policies,
+		"providers.json":          &b.Contents.Providers,
+		"action_groups.json":      &b.Contents.ActionGroups,
+		"action_hierarchies.json": &b.Contents.ActionHierarchies,
+		"resolution_rules.json":   &b.Contents.ResolutionRules,
+		"sod_constraints.json":    &b.Contents.SoDConstraints,
+	}
+	for name, v := range targets {
+		if _, ok := b.Manifest.Files[name]; !ok {
+			return nil, fmt.Errorf("bundle: manifest does not list %s", name)
+		}
+		if err := json.Unmarshal(files[name], v); err != nil {
+			return nil, fmt.Errorf("bundle: %s: %w", name, err)
+		}
+	}
+	return &b, nil
+}
+
+func verify(manifest, sig []byte, trusted []ed25519.PublicKey) error {
+	if len(trusted) == 0 {
+		return ErrUntrusted
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+	if err != nil {
+		return ErrBadSignature
+	}
+	for _, k := range trusted {
+		if ed25519.Verify(k, manifest, raw) {
+			return nil
+		}
+	}
+	var m Manifest
+	if json.Unmarshal(manifest, &m) == nil {
+		for _, k := range trusted {
+			if KeyID(k) == m.KeyID {
+				return ErrBadSignature
+			}
+		}
+	}
+	return ErrUntrusted
+}
+
+// KeyID is a short fingerprint of a public key.
+func KeyID(k ed25519.PublicKey) string {
+	sum := sha256.Sum256(k)
+	return hex.EncodeToString(sum[:8])
+}
+
+// ParsePublicKey decodes a base64 ed25519 public key.
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil || len(b) != ed25519.PublicKeySize {
+		return nil, errors.New("invalid ed25519 public key")
+	}
+	return ed25519.PublicKey(b), nil
+}
+
+// ParsePublicKeys decodes a comma-separated list of base64 public keys.
+func ParsePublicKeys(list string) ([]ed25519.PublicKey, error) {
+	var out []ed25519.PublicKey
+	for _, s := range strings.Split(list, ",") {
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+		k, err := ParsePublicKey(s)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, k)
+	}
+	return out, nil
+}
+
+// ParsePrivateKey decodes a base64 ed25519 seed or private key.
+func ParsePrivateKey(s string) (ed25519.PrivateKey, error) {
+	b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, errors.New("invalid ed25519 private key")
+	}
+	switch len(b) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(b), nil
+	case ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(b), nil
+	}
+	return nil, errors.New("invalid ed25519 private key")
+}
@@ -0,0 +1,166 @@
+package bundle
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"example.com/jit-engine/internal/eval"
+	"example.com/jit-engine/internal/gate"
+	"example.com/jit-engine/internal/lint"
+	"example.com/jit-engine/internal/model"
+	"example.com/jit-engine/internal/store"
+)
+
+// Open reads and verifies the bundle at path.
+func Open(path string, trusted []ed25519.PublicKey) (*Bundle, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f, trusted)
+}
+
+// Memory returns an in-memory store holding c on top of the default
+// providers. Policies keep their IDs and Enabled flags.
+func (c *Contents) Memory() (*store.Memory, error) {
+	m := store.NewMemory()
+	for _, p := range c.Providers {
+		m.PutProvider(p)
+	}
+	for _, g := range c.ActionGroups {
+		m.PutActionGroup(g)
+	}
+	for _, h := range c.ActionHierarchies {
+		m.AddActionHierarchy(h)
+	}
+	for _, r := range c.ResolutionRules {
+		m.AddResolutionRule(r)
+	}
+	for _, sc := range c.SoDConstraints {
+		m.AddSoDConstraint(sc)
+	}
+	for _, p := range c.Policies {
+		if err := m.PutPolicy(p); err != nil {
+			return nil, fmt.Errorf("policy %s: %w", p.Name, err)
+		}
+	}
+	return m, nil
+}
+
+// NewEngine evaluates the bundle at path without a database. Decisions are
+// recorded in audits, or kept in memory when audits is nil.
+func NewEngine(path string, trusted []ed25519.PublicKey, audits store.AuditStore, failClosed bool) (*eval.EvalEngine, *Bundle, error) {
+	b, err := Open(path, trusted)
+	if err != nil {
+		return nil, nil, err
+	}
+	m, err := b.Contents.Memory()
+	if err != nil {
+		return nil, nil, err
+	}
+	if audits == nil {
+		audits = m
+	}
+	eng, err := eval.NewEngine(m, audits, failClosed)
+	if err != nil {
+		return nil, nil, err
+	}
+	return eng, b, nil
+}
+
+// Import replaces the contents of s with the bundle in one transaction,
+// gated by g like any other policy write, and returns the conflicts of the
+// imported policies. Providers are upserted; policies, action groups and
+// hierarchies, resolution rules and SoD constraints not in the bundle are
+// deleted. Delegations, sessions and audits are left alone. Updates and
+// deletes fail with store.ErrVersionConflict if a policy changes while the
+// import runs.
+func Import(s *store.SQL, b *Bundle, g gate.Gate) ([]lint.Conflict, error) {
+	c := b.Contents
+	keep := map[uuid.UUID]bool{}
+	for _, p := range c.Policies {
+		keep[p.ID] = true
+	}
+	existing, err := s.ListPolicies(store.PolicyFilter{})
+	if err != nil {
+		return nil, err
+	}
+	// Test cases of the policies the bundle drops go with them
+	var dropped []uuid.UUID
+	for _, p := range existing {
+		if !keep[p.ID] {
+			dropped = append(dropped, p.ID)
+		}
+	}
+	return g.Write(s, dropped, func(ps store.PolicyStore) ([]model.Policy, error) {
+		tx := ps.(*store.SQL).DB()
+		if len(c.Providers) > 0 {
+			if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&c.Providers).Error; err != nil {
+				return nil, fmt.Errorf("providers: %w", err)
+			}
+		}
+		if err := replace(tx, &model.ActionGroup{}, &c.ActionGroups, len(c.ActionGroups)); err != nil {
+			return nil, fmt.Errorf("action groups: %w", err)
+		}
+
+		existing, err := ps.ListPolicies(store.PolicyFilter{})
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range existing {
+			if !keep[p.ID] {
+				if err := ps.WithChange(store.Change{IfVersion: p.Version}).DeletePolicy(p.ID); err != nil {
+					return nil, fmt.Errorf("delete policy %s: %w", p.Name, err)
+				}
+			}
+		}
+		written := make([]model.Policy, 0, len(c.Policies))
+		for _, p := range c.Policies {
+			if err := putPolicy(ps, &p); err != nil {
+				return nil, fmt.Errorf("policy %s: %w", p.Name, err)
+			}
+			written = append(written, p)
+		}
+
+		if err := replace(tx, &model.ActionHierarchy{}, &c.ActionHierarchies, len(c.ActionHierarchies)); err != nil {
+			return nil, fmt.Errorf("action hierarchies: %w", err)
+		}
+		if err := replace(tx, &model.ResolutionRule{}, &c.ResolutionRules, len(c.ResolutionRules)); err != nil {
+			return nil, fmt.Errorf("resolution rules: %w", err)
+		}
+		if err := replace(tx, &model.SoDConstraint{}, &c.SoDConstraints, len(c.SoDConstraints)); err != nil {
+			return nil, fmt.Errorf("sod constraints: %w", err)
+		}
+		return written, nil
+	})
+}
+
+// putPolicy creates or updates p keeping its ID. An update expects the
+// policy to be at the version just read.
+func putPolicy(s store.PolicyStore, p *model.Policy) error {
+	cur, err := s.GetPolicy(p.ID)
+	if errors.Is(err, store.ErrNotFound) {
+		return store.CreatePolicyAsIs(s, p)
+	} else if err != nil {
+		return err
+	}
+	return s.WithChange(store.Change{IfVersion: cur.Version}).UpdatePolicy(p)
+}
+
+// replace deletes every row of table and inserts rows.
+func replace(tx *gorm.DB, table, rows any, n int) error {
+	if err := tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(table).Error; err != nil {
+		return err
+	}
+	if n == 0 {
+		return nil
+	}
+	return tx.Create(rows).Error
+}
@@ -0,0 +1,189 @@
+// Package changefeed keeps every server replica's policy snapshot and caches
+// in sync using Postgres LISTEN/NOTIFY.
+package changefeed
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"example.com/jit-engine/internal/eval"
+	"example.com/jit-engine/internal/session"
+)
+
+// Channel is the NOTIFY channel written by the triggers installed by cmd/migrate.
+const Channel = "jit_policy_changes"
+
+// Change is the payload of one notification.
+type Change struct {
+	Table       string    `json:"table"`
+	Op          string    `json:"op"`
+	ID          uuid.UUID `json:"id"`
+	Provider    string    `json:"provider,omitempty"`
+	OldProvider string    `json:"old_provider,omitempty"`
+	// Origin is the application_name of the connection that made the change.
+	Origin string `json:"origin,omitempty"`
+	// Session is set for session revocations.
+	Session *session.Event `json:"session,omitempty"`
+}
+
+type Listener struct {
+	DSN    string
+	Engine *eval.EvalEngine
+	// Origin is the application_name this replica's own database connections
+	// use. Changes they made were already applied to Engine by the handler that
+	// made them, so their notifications only re-evaluate sessions.
+	Origin string
+	// Sessions, when set, re-evaluates active sessions after every change and
+	// receives the revocations made by any replica.
+	Sessions *session.Registry
+	// ResyncInterval forces a full resync even when no notification arrived,
+	// covering notifications lost while disconnected. Zero disables it.
+	ResyncInterval time.Duration
+}
+
+// Run listens until ctx is done. The underlying connection reconnects on its
+// own; every reconnect triggers a full resync because notifications sent
+// while disconnected are lost. Session revocations are left to the feed only
+// while it is listening; otherwise the registry announces its own.
+func (l *Listener) Run(ctx context.Context) error {
+	fb := &feedBroadcast{sessions: l.Sessions}
+	pl := pq.NewListener(l.DSN, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		switch ev {
+		case pq.ListenerEventDisconnected:
+			log.Printf("changefeed: disconnected: %v", err)
+			fb.set(false)
+		case pq.ListenerEventConnectionAttemptFailed:
+			log.Printf("changefeed: reconnect failed: %v", err)
+		case pq.ListenerEventReconnected:
+			log.Printf("changefeed: reconnected")
+			fb.set(true)
+		}
+	})
+	defer pl.Close()
+	if err := pl.Listen(Channel); err != nil {
+		return err
+	}
+	fb.start()
+	defer fb.stop()
+
+	var resync <-chan time.Time
+	if l.ResyncInterval > 0 {
+		t := time.NewTicker(l.ResyncInterval)
+		defer t.Stop()
+		resync = t.C
+	}
+	ping := time.NewTicker(90 * time.Second)
+	defer ping.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case n := <-pl.Notify:
+			if n == nil {
+				// pq sends nil after a reconnect.
+				l.resync("reconnect")
+				continue
+			}
+			l.apply(n.Extra)
+		case <-resync:
+			l.resync("periodic")
+		case <-ping.C:
+			go func() {
+				if err := pl.Ping(); err != nil {
+					log.Printf("changefeed: ping: %v", err)
+				}
+			}()
+		}
+	}
+}
+
+// feedBroadcast tells the session registry whether the feed delivers
+// revocations: only while Run is listening and the connection is up.
+type feedBroadcast struct {
+	sessions *session.Registry
+
+	mu        sync.Mutex
+	listening bool
+	connected bool
+}
+
+func (f *feedBroadcast) start() {
+	f.mu.Lock()
+	f.listening, f.connected = true, true
+	f.update()
+	f.mu.Unlock()
+}
+
+func (f *feedBroadcast) stop() {
+	f.mu.Lock()
+	f.listening = false
+	f.update()
+	f.mu.Unlock()
+}
+
+func (f *feedBroadcast) set(connected bool) {
+	f.mu.Lock()
+	f.connected = connected
+	f.update()
+	f.mu.Unlock()
+}
+
+func (f *feedBroadcast) update() {
+	if f.sessions != nil {
+		f.sessions.Broadcast(f.listening && f.connected)
+	}
+}
+
+func (l *Listener) apply(payload string) {
+	var c Change
+	if err := json.Unmarshal([]byte(payload), &c); err != nil {
+		log.Printf("changefeed: bad payload %q: %v", payload, err)
+		l.resync("bad payload")
+		return
+	}
+	switch c.Table {
+	case "sessions":
+		if l.Sessions != nil && c.Session != nil {
+			l.Sessions.Deliver(*c.Session)
+		}
+		return
+	}
+	if l.Origin == "" || c.Origin != l.Origin {
+		l.applyToEngine(c)
+	}
+	if l.Sessions != nil {
+		l.Sessions.Trigger()
+	}
+}
+
+func (l *Listener) applyToEngine(c Change) {
+	switch c.Table {
+	case "policies":
+		providers := []string{c.Provider}
+		if c.OldProvider != "" && c.OldProvider != c.Provider {
+			providers = append(providers, c.OldProvider)
+		}
+		l.Engine.PolicyChanged(c.ID, providers...)
+	case "sod_constraints", "action_groups", "action_hierarchies", "providers", "resolution_rules":
+		if _, err := l.Engine.Reload(); err != nil {
+			log.Printf("changefeed: reload: %v", err)
+		}
+	default:
+		l.Engine.InvalidateDecisions()
+	}
+}
+
+func (l *Listener) resync(why string) {
+	if _, err := l.Engine.Resync(); err != nil {
+		log.Printf("changefeed: %s resync: %v", why, err)
+	}
+	if l.Sessions != nil {
+		l.Sessions.Trigger()
+	}
+}
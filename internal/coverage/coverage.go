@@ -0,0 +1,302 @@
+// Package coverage analyses recorded decisions to find policies that can be
+// retired or tightened: policies that never matched, allows used by few of
+// the subjects they permit, and resource patterns much broader than the
+// resources actually accessed through them.
+//
+// A policy counts as matched by a request when its trace entry records a
+// true condition, whether or not it decided the request.
+package coverage
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/uuid"
+
+	"example.com/jit-engine/internal/eval"
+	"example.com/jit-engine/internal/model"
+	"example.com/jit-engine/internal/policy"
+)
+
+// Options tune the least-privilege checks.
+type Options struct {
+	// MinPermitted is how many observed subjects an allow must permit before
+	// its usage is judged. Default 5.
+	MinPermitted int
+	// MaxUsage flags allows used by at most this fraction of the subjects
+	// they permit. Default 0.2.
+	MaxUsage float64
+	// Samples caps the subjects and resources listed per finding. Default 10.
+	Samples int
+}
+
+func (o *Options) defaults() {
+	if o.MinPermitted <= 0 {
+		o.MinPermitted = 5
+	}
+	if o.MaxUsage <= 0 {
+		o.MaxUsage = 0.2
+	}
+	if o.Samples <= 0 {
+		o.Samples = 10
+	}
+}
+
+// PolicyRef identifies a policy in a report.
+type PolicyRef struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	Provider  string    `json:"provider"`
+	Effect    string    `json:"effect"`
+	Resource  string    `json:"resource"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Unused is a policy that matched no request in the window.
+type Unused struct {
+	Policy PolicyRef `json:"policy"`
+}
+
+// Underused is an allow used by few of the subjects it permits. Permitted
+// counts subjects seen in the window whose attributes satisfy, or may
+// satisfy, the policy's condition.
+type Underused struct {
+	Policy    PolicyRef `json:"policy"`
+	Permitted int       `json:"permitted"`
+	Used      int       `json:"used"`
+	Usage     float64   `json:"usage"`
+	Subjects  []string  `json:"subjects"`
+}
+
+// OverBroad is an allow whose resource pattern covers much more than the
+// resources accessed through it. Suggested covers exactly those resources'
+// common prefix.
+type OverBroad struct {
+	Policy    PolicyRef `json:"policy"`
+	Accessed  int       `json:"accessed"`
+	Resources []string  `json:"resources"`
+	Suggested string    `json:"suggested"`
+}
+
+// Report is the outcome of an analysis.
+type Report struct {
+	Since     time.Time   `json:"since"`
+	Until     time.Time   `json:"until"`
+	Audits    int         `json:"audits"`
+	Unused    []Unused    `json:"unused"`
+	Underused []Underused `json:"underused"`
+	OverBroad []OverBroad `json:"over_broad"`
+}
+
+// usage is what the window shows about one policy.
+type usage struct {
+	subjects  map[string]bool
+	resources map[string]bool
+}
+
+// Analyzer accumulates audits and reports on policies.
+type Analyzer struct {
+	opts     Options
+	env      *cel.Env
+	policies []model.Policy
+	usage    map[uuid.UUID]*usage
+	// subjects holds the latest attributes seen for each subject id.
+	subjects map[string]map[string]any
+	audits   int
+}
+
+// NewAnalyzer reports on ps, typically the enabled policies.
+func NewAnalyzer(ps []model.Policy, opts Options) (*Analyzer, error) {
+	opts.defaults()
+	env, err := policy.NewEnv()
+	if err != nil {
+		return nil, err
+	}
+	a := &Analyzer{opts: opts, env: env, policies: ps, usage: map[uuid.UUID]*usage{}, subjects: map[string]map[string]any{}}
+	for _, p := range ps {
+		a.usage[p.ID] = &usage{subjects: map[string]bool{}, resources: map[string]bool{}}
+	}
+	return a, nil
+}
+
+// Add records one decision. Audits should be added oldest first so the
+// latest subject attributes win.
+func (a *Analyzer) Add(audit *model.PolicyAudit) {
+	a.audits++
+	var req eval.Request
+	if json.Unmarshal(audit.Request, &req) != nil {
+		return
+	}
+	subject := ""
+	if id, ok := req.Subject["id"]; ok {
+		subject = fmt.Sprint(id)
+		a.subjects[subject] = req.Subject
+	}
+	var trace []eval.TraceItem
+	_ = json.Unmarshal(audit.Trace, &trace)
+	for _, t := range trace {
+		u, ok := a.usage[t.PolicyID]
+		if !ok || t.Result == nil || !*t.Result {
+			continue
+		}
+		if subject != "" {
+			u.subjects[subject] = true
+		}
+		u.resources[req.Resource] = true
+	}
+}
+
+// Report analyses what was added. since and until only label the report.
+func (a *Analyzer) Report(since, until time.Time) *Report {
+	r := &Report{Since: since, Until: until, Audits: a.audits, Unused: []Unused{}, Underused: []Underused{}, OverBroad: []OverBroad{}}
+	for i := range a.policies {
+		p := &a.policies[i]
+		u := a.usage[p.ID]
+		ref := PolicyRef{ID: p.ID, Name: p.Name, Provider: p.Provider, Effect: p.Effect, Resource: p.Resource, CreatedAt: p.CreatedAt}
+		if len(u.resources) == 0 {
+			r.Unused = append(r.Unused, Unused{Policy: ref})
+			continue
+		}
+		if p.Effect != "allow" {
+			continue
+		}
+		if f, ok := a.underused(p, u); ok {
+			f.Policy = ref
+			r.Underused = append(r.Underused, f)
+		}
+		if f, ok := a.overBroad(p, u); ok {
+			f.Policy = ref
+			r.OverBroad = append(r.OverBroad, f)
+		}
+	}
+	sort.SliceStable(r.Unused, func(i, j int) bool { return refLess(r.Unused[i].Policy, r.Unused[j].Policy) })
+	sort.SliceStable(r.Underused, func(i, j int) bool { return r.Underused[i].Usage < r.Underused[j].Usage })
+	sort.SliceStable(r.OverBroad, func(i, j int) bool { return r.OverBroad[i].Accessed < r.OverBroad[j].Accessed })
+	return r
+}
+
+func refLess(a, b PolicyRef) bool {
+	if a.Provider != b.Provider {
+		return a.Provider < b.Provider
+	}
+	return a.Name < b.Name
+}
+
+func (a *Analyzer) underused(p *model.Policy, u *usage) (Underused, bool) {
+	permitted := 0
+	prg := a.program(p.Expr)
+	if prg == nil {
+		return Underused{}, false
+	}
+	for id, attrs := range a.subjects {
+		if u.subjects[id] || mayPermit(prg, attrs) {
+			permitted++
+		}
+	}
+	if permitted < a.opts.MinPermitted {
+		return Underused{}, false
+	}
+	usage := float64(len(u.subjects)) / float64(permitted)
+	if usage > a.opts.MaxUsage {
+		return Underused{}, false
+	}
+	return Underused{Permitted: permitted, Used: len(u.subjects), Usage: usage, Subjects: a.sample(u.subjects)}, true
+}
+
+// program prepares expr for partial evaluation; nil when it cannot be.
+func (a *Analyzer) program(expr string) cel.Program {
+	checked, iss := a.env.Compile(expr)
+	if iss != nil && iss.Err() != nil {
+		return nil
+	}
+	prg, err := a.env.Program(checked, cel.EvalOptions(cel.OptPartialEval))
+	if err != nil {
+		return nil
+	}
+	return prg
+}
+
+// mayPermit evaluates prg with the subject's attributes known and the rest of
+// the request unknown. Only a definite false rules the subject out.
+func mayPermit(prg cel.Program, subject map[string]any) bool {
+	var unknowns []*cel.AttributePatternType
+	for _, v := range policy.RequestVars {
+		if v != "subject" {
+			unknowns = append(unknowns, cel.AttributePattern(v))
+		}
+	}
+	vars, err := cel.PartialVars(map[string]any{"subject": subject}, unknowns...)
+	if err != nil {
+		return true
+	}
+	out, _, err := prg.Eval(vars)
+	if err != nil || types.IsError(out) {
+		// Missing attributes make the engine's evaluation fail too.
+		return false
+	}
+	if types.IsUnknown(out) {
+		return true
+	}
+	b, ok := out.Value().(bool)
+	return !ok || b
+}
+
+func (a *Analyzer) overBroad(p *model.Policy, u *usage) (OverBroad, bool) {
+	if p.MatchKind != "" && p.MatchKind != policy.MatchGlob && p.MatchKind != policy.MatchPrefix {
+		return OverBroad{}, false
+	}
+	m, err := policy.CompileMatcher(p.MatchKind, p.Resource, nil)
+	if err != nil {
+		return OverBroad{}, false
+	}
+	resources := make([]string, 0, len(u.resources))
+	for r := range u.resources {
+		resources = append(resources, r)
+	}
+	sort.Strings(resources)
+	prefix := commonPrefix(resources)
+	if len(prefix) <= len(m.LiteralPrefix()) {
+		return OverBroad{}, false
+	}
+	suggested := prefix + "*"
+	if len(resources) == 1 {
+		suggested = resources[0]
+	}
+	if suggested == p.Resource {
+		return OverBroad{}, false
+	}
+	if len(resources) > a.opts.Samples {
+		resources = resources[:a.opts.Samples]
+	}
+	return OverBroad{Accessed: len(u.resources), Resources: resources, Suggested: suggested}, true
+}
+
+// commonPrefix returns the longest prefix of the sorted strings ss.
+func commonPrefix(ss []string) string {
+	if len(ss) == 0 {
+		return ""
+	}
+	first, last := ss[0], ss[len(ss)-1]
+	n := 0
+	for n < len(first) && n < len(last) && first[n] == last[n] {
+		n++
+	}
+	return strings.ToValidUTF8(first[:n], "")
+}
+
+func (a *Analyzer) sample(set map[string]bool) []string {
+	out := make([]string, 0, len(set))
+	for s := range set {
+		out = append(out, s)
+	}
+	sort.Strings(out)
+	if len(out) > a.opts.Samples {
+		out = out[:a.opts.Samples]
+	}
+	return out
+}
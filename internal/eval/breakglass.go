@@ -0,0 +1,87 @@
+package eval
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+
+	"example.com/jit-engine/internal/model"
+	"example.com/jit-engine/internal/notify"
+)
+
+// BreakGlassProvider is the policy set consulted to decide who may use break-glass access.
+const BreakGlassProvider = "breakglass"
+
+type BreakGlassConfig struct {
+	// TTL caps the session length granted by a break-glass allow.
+	TTL       time.Duration
+	Notifiers []notify.Notifier
+}
+
+const defaultBreakGlassTTL = 15 * time.Minute
+
+func (e *EvalEngine) ConfigureBreakGlass(cfg BreakGlassConfig) {
+	if cfg.TTL <= 0 {
+		cfg.TTL = defaultBreakGlassTTL
+	}
+	e.breakGlass = cfg
+}
+
+func (e *EvalEngine) evaluateBreakGlass(req Request, traceOut []TraceItem) (Result, error) {
+	policies, err := e.loadPolicies(BreakGlassProvider, req.Action, req.Resource)
+	if err != nil {
+		return e.loadFailure(BreakGlassProvider, err, traceOut), err
+	}
+	decision, matched, reason, trace, winner, err := e.evaluateCandidates(policies, req)
+	traceOut = append(traceOut, trace...)
+	if err != nil || decision == "deny" {
+		return Result{Decision: decision, Matched: matched, Reason: reason, Trace: traceOut}, err
+	}
+	if winner == nil {
+		return Result{Decision: "deny", Reason: "Access denied: subject is not authorized for break-glass access", Trace: traceOut}, nil
+	}
+	ob := e.breakGlassObligations(*winner)
+	return Result{
+		Decision:    "allow",
+		Matched:     &winner.ID,
+		Reason:      policyMessageOrDefault(*winner, fmt.Sprintf("Break-glass access granted by policy '%s'", winner.Name)),
+		Trace:       traceOut,
+		Obligations: &ob,
+	}, nil
+}
+
+// breakGlassObligations always requires session recording. A policy may shorten
+// the configured TTL via metadata.max_session_ttl_seconds but never extend it.
+func (e *EvalEngine) breakGlassObligations(p model.Policy) Obligations {
+	ttl := int(e.breakGlass.TTL / time.Second)
+	if len(p.Metadata) > 0 {
+		var m map[string]any
+		if err := json.Unmarshal(p.Metadata, &m); err == nil {
+			if v, ok := m["max_session_ttl_seconds"].(float64); ok && int(v) > 0 && int(v) < ttl {
+				ttl = int(v)
+			}
+		}
+	}
+	return Obligations{MaxSessionTTLSeconds: ttl, SessionRecording: true}
+}
+
+func (e *EvalEngine) notifyBreakGlass(auditID uuid.UUID, auditErr error, req Request, res Result) {
+	if auditErr != nil {
+		log.Printf("break-glass audit write failed: %v", auditErr)
+	}
+	notify.Dispatch(e.breakGlass.Notifiers, notify.Event{
+		Kind:     "break_glass",
+		Severity: "high",
+		AuditID:  auditID,
+		Time:     time.Now().UTC(),
+		Subject:  req.Subject,
+		Resource: req.Resource,
+		Action:   req.Action,
+		Decision: res.Decision,
+		Reason:   res.Reason,
+		Details:  res.Obligations,
+	})
+}
@@ -0,0 +1,238 @@
+package eval
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const defaultDecisionCacheTTL = 30 * time.Second
+
+// CacheStats reports decision cache activity since start-up.
+type CacheStats struct {
+	Enabled       bool   `json:"enabled"`
+	Entries       int    `json:"entries"`
+	Capacity      int    `json:"capacity"`
+	TTLSeconds    int    `json:"ttl_seconds"`
+	Hits          uint64 `json:"hits"`
+	Misses        uint64 `json:"misses"`
+	Bypassed      uint64 `json:"bypassed"`
+	Stale         uint64 `json:"stale"`
+	Evictions     uint64 `json:"evictions"`
+	Invalidations uint64 `json:"invalidations"`
+}
+
+// decisionCache is a bounded LRU of decisions. Each entry remembers the
+// revision of every policy set it was computed from and is treated as a miss
+// once any of them moved on.
+type decisionCache struct {
+	mu    sync.Mutex
+	size  int
+	ttl   time.Duration
+	ll    *list.List
+	items map[string]*list.Element
+
+	hits, misses, bypassed, stale, evictions, invalidations atomic.Uint64
+}
+
+type cachedDecision struct {
+	key        string
+	res        Result
+	generation uint64
+	revisions  map[string]uint64
+	expires    time.Time
+}
+
+// EnableDecisionCache caches up to size decisions for ttl. size <= 0 disables the cache.
+func (e *EvalEngine) EnableDecisionCache(size int, ttl time.Duration) {
+	if size <= 0 {
+		e.decisions = nil
+		return
+	}
+	if ttl <= 0 {
+		ttl = defaultDecisionCacheTTL
+	}
+	e.decisions = &decisionCache{size: size, ttl: ttl, ll: list.New(), items: map[string]*list.Element{}}
+}
+
+func (e *EvalEngine) DecisionCacheStats() CacheStats {
+	c := e.decisions
+	if c == nil {
+		return CacheStats{}
+	}
+	c.mu.Lock()
+	n := c.ll.Len()
+	c.mu.Unlock()
+	return CacheStats{
+		Enabled:       true,
+		Entries:       n,
+		Capacity:      c.size,
+		TTLSeconds:    int(c.ttl / time.Second),
+		Hits:          c.hits.Load(),
+		Misses:        c.misses.Load(),
+		Bypassed:      c.bypassed.Load(),
+		Stale:         c.stale.Load(),
+		Evictions:     c.evictions.Load(),
+		Invalidations: c.invalidations.Load(),
+	}
+}
+
+// PolicyChanged drops the compiled program for id, reloads the snapshot and
+// invalidates cached decisions that consulted any of providers.
+func (e *EvalEngine) PolicyChanged(id uuid.UUID, providers ...string) {
+	e.Invalidate(id)
+	if _, err := e.Reload(); err != nil {
+		log.Printf("policy snapshot reload after change to %s: %v", id, err)
+	}
+	e.bumpRevisions(providers...)
+}
+
+// PoliciesChanged is PolicyChanged for a batch of writes, reloading once.
+func (e *EvalEngine) PoliciesChanged(ids []uuid.UUID, providers ...string) {
+	e.InvalidateMany(ids)
+	if _, err := e.Reload(); err != nil {
+		log.Printf("policy snapshot reload after %d policy changes: %v", len(ids), err)
+	}
+	e.bumpRevisions(providers...)
+}
+
+func (e *EvalEngine) bumpRevisions(providers ...string) {
+	e.revMu.Lock()
+	for _, p := range providers {
+		e.revisions[p]++
+	}
+	e.revMu.Unlock()
+	if e.decisions != nil {
+		e.decisions.invalidations.Add(1)
+	}
+}
+
+// InvalidateDecisions discards every cached decision. Use it for changes that
+// are not scoped to a provider, such as delegations or SoD constraints.
+func (e *EvalEngine) InvalidateDecisions() {
+	e.revMu.Lock()
+	e.generation++
+	e.revMu.Unlock()
+	if e.decisions != nil {
+		e.decisions.invalidations.Add(1)
+	}
+}
+
+func (e *EvalEngine) revisionState() (uint64, map[string]uint64) {
+	e.revMu.Lock()
+	defer e.revMu.Unlock()
+	revs := make(map[string]uint64, len(e.revisions))
+	for k, v := range e.revisions {
+		revs[k] = v
+	}
+	return e.generation, revs
+}
+
+func (e *EvalEngine) isCurrent(d *cachedDecision) bool {
+	e.revMu.Lock()
+	defer e.revMu.Unlock()
+	if d.generation != e.generation {
+		return false
+	}
+	for p, rev := range d.revisions {
+		if e.revisions[p] != rev {
+			return false
+		}
+	}
+	return true
+}
+
+// decide evaluates req, serving it from the decision cache when possible.
+// Break-glass requests and volatile decisions always go to the evaluator.
+func (e *EvalEngine) decide(req Request) (Result, error) {
+	c := e.decisions
+	if c == nil {
+		return e.evaluate(req, &evalState{})
+	}
+	if req.BreakGlass {
+		c.bypassed.Add(1)
+		return e.evaluate(req, &evalState{})
+	}
+	key, err := requestKey(req)
+	if err != nil {
+		c.bypassed.Add(1)
+		return e.evaluate(req, &evalState{})
+	}
+	if res, ok := c.get(key, e.isCurrent); ok {
+		return res, nil
+	}
+	// Capture revisions before evaluating so a concurrent policy change
+	// leaves this entry stale rather than cached as current.
+	gen, revs := e.revisionState()
+	st := &evalState{}
+	res, err := e.evaluate(req, st)
+	if err != nil {
+		return res, err
+	}
+	if st.volatile {
+		c.bypassed.Add(1)
+		return res, nil
+	}
+	used := make(map[string]uint64, len(st.providers))
+	for _, p := range st.providers {
+		used[p] = revs[p]
+	}
+	c.put(&cachedDecision{key: key, res: res, generation: gen, revisions: used, expires: time.Now().Add(c.ttl)})
+	return res, nil
+}
+
+// requestKey hashes the canonical JSON form of req; encoding/json writes map
+// keys in sorted order, so equal requests produce equal keys.
+func requestKey(req Request) (string, error) {
+	b, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func (c *decisionCache) get(key string, current func(*cachedDecision) bool) (Result, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		c.misses.Add(1)
+		return Result{}, false
+	}
+	d := el.Value.(*cachedDecision)
+	if time.Now().After(d.expires) || !current(d) {
+		c.ll.Remove(el)
+		delete(c.items, key)
+		c.stale.Add(1)
+		c.misses.Add(1)
+		return Result{}, false
+	}
+	c.ll.MoveToFront(el)
+	c.hits.Add(1)
+	return d.res, true
+}
+
+func (c *decisionCache) put(d *cachedDecision) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[d.key]; ok {
+		el.Value = d
+		c.ll.MoveToFront(el)
+		return
+	}
+	c.items[d.key] = c.ll.PushFront(d)
+	for c.ll.Len() > c.size {
+		last := c.ll.Back()
+		c.ll.Remove(last)
+		delete(c.items, last.Value.(*cachedDecision).key)
+		c.evictions.Add(1)
+	}
+}
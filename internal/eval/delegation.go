@@ -0,0 +1,86 @@
+package eval
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const defaultMaxDelegationChain = 3
+
+// DelegationTrace records which delegation an allow came through.
+type DelegationTrace struct {
+	ID        uuid.UUID `json:"id"`
+	Delegator string    `json:"delegator"`
+	Delegate  string    `json:"delegate"`
+	Depth     int       `json:"depth"`
+}
+
+// ConfigureDelegation sets how many delegation hops a decision may follow.
+// Zero disables delegation.
+func (e *EvalEngine) ConfigureDelegation(maxChain int) {
+	if maxChain < 0 {
+		maxChain = 0
+	}
+	e.maxChain = maxChain
+}
+
+// SubjectID returns the stable identifier of the requesting subject (subject.id).
+func SubjectID(req Request) string {
+	if v, ok := req.Subject["id"].(string); ok {
+		return v
+	}
+	return ""
+}
+
+// evaluateDelegations is consulted only when no policy decided req. Each active
+// delegation to the subject is followed by re-evaluating the same request as
+// the delegator, so a delegation never grants more than the delegator holds at
+// this moment. ok reports whether a delegation produced the decision; when it
+// did not, the returned Result only carries the extended trace.
+func (e *EvalEngine) evaluateDelegations(req Request, st *evalState, traceOut []TraceItem) (Result, bool, error) {
+	delegate := SubjectID(req)
+	if delegate == "" || e.maxChain == 0 {
+		return Result{Trace: traceOut}, false, nil
+	}
+	ds, err := e.policies.ActiveDelegations(delegate, req.Action, time.Now())
+	if err != nil {
+		return e.loadFailure(st.providers[len(st.providers)-1], err, traceOut), true, err
+	}
+	for _, d := range ds {
+		if !resourceMatch(d.Resource, req.Resource) {
+			continue
+		}
+		// The outcome now depends on when the delegation expires.
+		st.volatile = true
+		dt := &DelegationTrace{ID: d.ID, Delegator: d.Delegator, Delegate: d.Delegate, Depth: st.depth + 1}
+		if st.depth+1 > e.maxChain {
+			traceOut = append(traceOut, TraceItem{Effect: "allow", Reason: fmt.Sprintf("delegation chain limit (%d) reached", e.maxChain), Delegation: dt})
+			continue
+		}
+		var subject map[string]any
+		if err := json.Unmarshal(d.DelegatorSubject, &subject); err != nil {
+			traceOut = append(traceOut, TraceItem{Effect: "allow", Error: "delegator subject: " + err.Error(), Reason: "delegation skipped", Delegation: dt})
+			continue
+		}
+		if subject == nil {
+			subject = map[string]any{}
+		}
+		subject["id"] = d.Delegator
+		asDelegator := req
+		asDelegator.Subject = subject
+		res, err := e.evaluate(asDelegator, &evalState{depth: st.depth + 1})
+		if err != nil || res.Decision != "allow" || res.Matched == nil {
+			traceOut = append(traceOut, TraceItem{Effect: "allow", Reason: fmt.Sprintf("delegator '%s' is not allowed: %s", d.Delegator, res.Reason), Delegation: dt})
+			continue
+		}
+		reason := fmt.Sprintf("Access allowed through delegation from '%s'", d.Delegator)
+		traceOut = append(traceOut, TraceItem{PolicyID: *res.Matched, Effect: "allow", Result: boolPtr(true), Reason: reason, Delegation: dt})
+		return Result{Decision: "allow", Matched: res.Matched, Reason: reason, Trace: traceOut}, true, nil
+	}
+	return Result{Trace: traceOut}, false, nil
+}
+
+func boolPtr(b bool) *bool { return &b }
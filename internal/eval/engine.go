@@ -3,29 +3,48 @@ package eval
 import (
 	"encoding/json"
 	"fmt"
-	"sort"
-	"strings"
 	"sync"
+	"sync/atomic"
 
-	"github.com/gobwas/glob"
 	"github.com/google/cel-go/cel"
 	"github.com/google/cel-go/checker/decls"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 
 	"example.com/jit-engine/internal/model"
+	"example.com/jit-engine/internal/policy"
+	"example.com/jit-engine/internal/store"
 )
 
 type programEntry struct{ prog cel.Program }
 
 type EvalEngine struct {
-	db         *gorm.DB
+	policies   store.PolicyStore
+	audits     store.AuditStore
 	env        *cel.Env
 	cache      sync.Map
 	failClosed bool
+	breakGlass BreakGlassConfig
+	maxChain   int
+
+	snap     atomic.Pointer[snapshot]
+	reloadMu sync.Mutex
+
+	decisions *decisionCache
+	revMu     sync.Mutex
+	revisions map[string]uint64
+	// generation invalidates every cached decision, e.g. after delegation changes.
+	generation uint64
 }
 
+// NewEvalEngine evaluates policies stored in Postgres.
 func NewEvalEngine(db *gorm.DB, failClosed bool) (*EvalEngine, error) {
+	s := store.NewPostgres(db)
+	return NewEngine(s, s, failClosed)
+}
+
+// NewEngine evaluates the policies in policies and records decisions in audits.
+func NewEngine(policies store.PolicyStore, audits store.AuditStore, failClosed bool) (*EvalEngine, error) {
 	env, err := cel.NewEnv(
 		cel.Declarations(
 			decls.NewConst("subject", decls.NewMapType(decls.String, decls.Dyn), nil),
@@ -40,9 +59,36 @@ func NewEvalEngine(db *gorm.DB, failClosed bool) (*EvalEngine, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &EvalEngine{db: db, env: env, failClosed: failClosed}, nil
+	e := &EvalEngine{policies: policies, audits: audits, env: env, failClosed: failClosed, breakGlass: BreakGlassConfig{TTL: defaultBreakGlassTTL}, maxChain: defaultMaxDelegationChain, revisions: map[string]uint64{}}
+	if _, err := e.Reload(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Preview returns an engine configured like e that evaluates the policies in
+// policies, such as a transaction holding an unsaved change. It has no
+// decision cache and sees no audit history.
+func (e *EvalEngine) Preview(policies store.PolicyStore) (*EvalEngine, error) {
+	p, err := NewEngine(policies, noAudits{}, e.failClosed)
+	if err != nil {
+		return nil, err
+	}
+	p.breakGlass = e.breakGlass
+	p.maxChain = e.maxChain
+	return p, nil
 }
 
+// noAudits records nothing and remembers nothing.
+type noAudits struct{}
+
+func (noAudits) RecordAudit(*model.PolicyAudit) error { return nil }
+func (noAudits) LastAllow(string, string, string, []string) (*model.PolicyAudit, error) {
+	return nil, nil
+}
+func (noAudits) ListAudits(store.AuditFilter) ([]model.PolicyAudit, error) { return nil, nil }
+func (noAudits) CountAudits(store.AuditFilter) (int, error)                { return 0, nil }
+
 func (e *EvalEngine) compileOrGet(id uuid.UUID, expr string) (cel.Program, error) {
 	if v, ok := e.cache.Load(id); ok {
 		return v.(programEntry).prog, nil
@@ -64,13 +110,14 @@ func (e *EvalEngine) compileOrGet(id uuid.UUID, expr string) (cel.Program, error
 }
 
 type Request struct {
-	Subject  map[string]any `json:"subject"`
-	Resource string         `json:"resource"`
-	Action   string         `json:"action"`
-	Metadata map[string]any `json:"metadata"`
-	Protocol string         `json:"protocol,omitempty"`
-	Platform string         `json:"platform,omitempty"`
-	Cloud    string         `json:"cloud,omitempty"`
+	Subject    map[string]any `json:"subject"`
+	Resource   string         `json:"resource"`
+	Action     string         `json:"action"`
+	Metadata   map[string]any `json:"metadata"`
+	Protocol   string         `json:"protocol,omitempty"`
+	Platform   string         `json:"platform,omitempty"`
+	Cloud      string         `json:"cloud,omitempty"`
+	BreakGlass bool           `json:"break_glass,omitempty"`
 }
 
 type TraceItem struct {
@@ -79,100 +126,165 @@ type TraceItem struct {
 	Effect   string    `json:"effect"`
 	Reason   string    `json:"reason,omitempty"`
 	Error    string    `json:"error,omitempty"`
+	// MatchedAction explains how the request action was covered when it was
+	// not listed literally, e.g. "@readonly" or "admin -> read".
+	MatchedAction string `json:"matched_action,omitempty"`
+
+	Delegation *DelegationTrace `json:"delegation,omitempty"`
+	SoD        *SoDViolation    `json:"sod,omitempty"`
 }
 
-func (e *EvalEngine) EvaluateAndAudit(req Request) (decision string, matched *uuid.UUID, reason string, trace []TraceItem, err error) {
-	decision, matched, reason, trace, err = e.evaluate(req)
-	_ = e.persistAudit(req, decision, matched, trace)
-	return
+// Obligations are conditions the caller must enforce when acting on an allow decision.
+type Obligations struct {
+	MaxSessionTTLSeconds int  `json:"max_session_ttl_seconds"`
+	SessionRecording     bool `json:"session_recording"`
 }
 
-func (e *EvalEngine) evaluate(req Request) (string, *uuid.UUID, string, []TraceItem, error) {
-	var traceOut []TraceItem
+type Result struct {
+	Decision    string       `json:"decision"`
+	Matched     *uuid.UUID   `json:"matched"`
+	Reason      string       `json:"reason"`
+	Trace       []TraceItem  `json:"trace"`
+	Obligations *Obligations `json:"obligations,omitempty"`
+	// Providers are the provider policy sets the request was resolved to.
+	Providers []string `json:"providers,omitempty"`
+}
 
-	// Step 1: Evaluate global policies
-	globalPolicies, err := e.loadPolicies("global", req.Action)
-	if err != nil {
-		if e.failClosed {
-			return "deny", nil, "database error: " + err.Error(), nil, err
+// EvaluateAndAudit decides req and records the decision. A break-glass
+// request whose audit record cannot be written is denied: emergency access
+// is only granted with a trail.
+func (e *EvalEngine) EvaluateAndAudit(req Request) (Result, error) {
+	res, err := e.decide(req)
+	auditID, auditErr := e.persistAudit(req, res)
+	if req.BreakGlass {
+		if auditErr != nil && res.Decision == "allow" {
+			res = Result{Decision: "deny", Reason: "break-glass request could not be audited", Trace: res.Trace, Providers: res.Providers}
 		}
-		return "allow", nil, "database error (fail-open)", nil, err
+		e.notifyBreakGlass(auditID, auditErr, req, res)
 	}
+	return res, err
+}
 
-	for _, p := range globalPolicies {
-		if resourceMatch(p.Resource, req.Resource) {
-			result, matched, reason, trace, err := e.evaluatePolicy(p, req)
-			traceOut = append(traceOut, trace...)
-			if err != nil {
-				return result, matched, reason, traceOut, err
-			}
-			if result == "deny" {
-				return "deny", matched, reason, traceOut, nil
-			}
-		}
-	}
+// Evaluate decides req without writing an audit record.
+func (e *EvalEngine) Evaluate(req Request) (Result, error) {
+	return e.decide(req)
+}
 
-	// Step 2: If global policies pass, evaluate provider-specific policies
-	provider := req.Cloud
-	if provider == "" || provider == "none" {
-		provider = req.Protocol
+// evalState carries bookkeeping for one decision through the evaluation.
+type evalState struct {
+	// depth counts the delegation hops that led here; zero for caller requests.
+	depth int
+	// providers lists the policy sets consulted.
+	providers []string
+	// volatile is set when the decision depends on state outside the policy
+	// set (audit history, delegation expiry) and must not be cached.
+	volatile bool
+}
+
+// evaluate decides req.
+func (e *EvalEngine) evaluate(req Request, st *evalState) (Result, error) {
+	var traceOut []TraceItem
+
+	// Step 1: Evaluate separation-of-duties constraints and global policies
+	sodTrace, sodReason, err := e.checkSoD(req, st)
+	traceOut = append(traceOut, sodTrace...)
+	if err != nil {
+		return e.loadFailure("global", err, traceOut), err
 	}
-	if provider == "" {
-		return "deny", nil, "Access denied: no provider specified", traceOut, nil
+	if sodReason != "" {
+		return Result{Decision: "deny", Reason: sodReason, Trace: traceOut}, nil
 	}
-	providerPolicies, err := e.loadPolicies(provider, req.Action)
+
+	st.providers = append(st.providers, "global")
+	globalPolicies, err := e.loadPolicies("global", req.Action, req.Resource)
 	if err != nil {
-		if e.failClosed {
-			return "deny", nil, "database error: " + err.Error(), traceOut, err
+		return e.loadFailure("global", err, traceOut), err
+	}
+
+	for _, c := range globalPolicies {
+		result, matched, reason, trace, err := e.evaluatePolicy(c, req)
+		traceOut = append(traceOut, withMatchedAction(trace, c.via)...)
+		if err != nil {
+			return Result{Decision: result, Matched: matched, Reason: reason, Trace: traceOut}, err
+		}
+		if result == "deny" {
+			return Result{Decision: "deny", Matched: matched, Reason: reason, Trace: traceOut}, nil
 		}
-		return "allow", nil, "database error (fail-open)", traceOut, err
 	}
 
-	type candidate struct {
-		p  model.Policy
-		sp int
+	// Break-glass requests bypass provider policies but never the global guardrails above.
+	if req.BreakGlass {
+		st.volatile = true
+		return e.evaluateBreakGlass(req, traceOut)
 	}
-	var cands []candidate
-	for _, p := range providerPolicies {
-		if resourceMatch(p.Resource, req.Resource) {
-			cands = append(cands, candidate{p: p, sp: computeSpecificity(p.Resource)})
-		}
+
+	// Step 2: If global policies pass, evaluate the provider policy sets the
+	// request resolves to
+	defs, combine, reason, err := e.resolveProviders(req)
+	if err != nil {
+		return e.loadFailure("global", err, traceOut), err
+	}
+	if reason != "" {
+		return Result{Decision: "deny", Reason: reason, Trace: traceOut}, nil
+	}
+	providers := make([]string, len(defs))
+	for i, d := range defs {
+		providers[i] = d.Name
+	}
+	res, ok, err := e.evaluateProviders(req, st, defs, combine, traceOut)
+	res.Providers = providers
+	if ok {
+		return res, err
+	}
+	// No provider decided: fall back to rights delegated to the subject
+	res, ok, err = e.evaluateDelegations(req, st, res.Trace)
+	res.Providers = providers
+	if ok {
+		return res, err
 	}
+	traceOut = res.Trace
+	// Default to deny
+	return Result{Decision: "deny", Reason: fmt.Sprintf("Access denied: no allow policy matched for action '%s' on resource '%s'", req.Action, req.Resource), Trace: traceOut, Providers: providers}, nil
+}
 
-	sort.Slice(cands, func(i, j int) bool {
-		if cands[i].p.Priority != cands[j].p.Priority {
-			return cands[i].p.Priority < cands[j].p.Priority
-		}
-		if cands[i].sp != cands[j].sp {
-			return cands[i].sp > cands[j].sp
-		}
-		if !cands[i].p.CreatedAt.Equal(cands[j].p.CreatedAt) {
-			return cands[i].p.CreatedAt.Before(cands[j].p.CreatedAt)
-		}
-		return strings.Compare(cands[i].p.ID.String(), cands[j].p.ID.String()) < 0
-	})
+func (e *EvalEngine) loadFailure(provider string, err error, trace []TraceItem) Result {
+	if e.failClosedFor(provider) {
+		return Result{Decision: "deny", Reason: "database error: " + err.Error(), Trace: trace}
+	}
+	return Result{Decision: "allow", Reason: "database error (fail-open)", Trace: trace}
+}
 
+// evaluateCandidates applies deny-overrides over already sorted candidates. A
+// non-empty decision means evaluation stopped early; otherwise allowWinner is
+// the last allow policy that matched, if any.
+func (e *EvalEngine) evaluateCandidates(cands []candidate, req Request) (string, *uuid.UUID, string, []TraceItem, *model.Policy, error) {
+	var traceOut []TraceItem
 	var allowWinner *model.Policy
 	for _, c := range cands {
-		p := c.p
-		result, matched, reason, trace, err := e.evaluatePolicy(p, req)
-		traceOut = append(traceOut, trace...)
+		p := c.policy
+		result, matched, reason, trace, err := e.evaluatePolicy(c, req)
+		traceOut = append(traceOut, withMatchedAction(trace, c.via)...)
 		if err != nil {
-			return result, matched, reason, traceOut, err
+			return result, matched, reason, traceOut, nil, err
 		}
 		if result == "deny" {
-			return "deny", matched, reason, traceOut, nil
+			return "deny", matched, reason, traceOut, nil, nil
 		}
 		if result == "allow" {
 			allowWinner = &p
 		}
 	}
+	return "", nil, "", traceOut, allowWinner, nil
+}
 
-	if allowWinner != nil {
-		return "allow", &allowWinner.ID, policyMessageOrDefault(*allowWinner, fmt.Sprintf("Access allowed by policy '%s'", allowWinner.Name)), traceOut, nil
+// withMatchedAction records how a policy's action entries covered the request action.
+func withMatchedAction(trace []TraceItem, via string) []TraceItem {
+	if via != "" {
+		for i := range trace {
+			trace[i].MatchedAction = via
+		}
 	}
-	// Default to deny
-	return "deny", nil, fmt.Sprintf("Access denied: no allow policy matched for action '%s' on resource '%s'", req.Action, req.Resource), traceOut, nil
+	return trace
 }
 
 // policyMessageOrDefault checks policy.Metadata for key "message" and returns it if present (string), otherwise defaultMsg.
@@ -202,76 +314,58 @@ func policyNonMatchReason(p model.Policy) string {
 	return "conditions not met"
 }
 
-func (e *EvalEngine) persistAudit(req Request, decision string, matched *uuid.UUID, trace []TraceItem) error {
+func (e *EvalEngine) persistAudit(req Request, res Result) (uuid.UUID, error) {
 	rb, _ := json.Marshal(req)
-	tb, _ := json.Marshal(trace)
-	a := model.PolicyAudit{Request: rb, Decision: decision, MatchedID: matched, Trace: tb}
-	return e.db.Create(&a).Error
+	tb, _ := json.Marshal(res.Trace)
+	a := model.PolicyAudit{Request: rb, Decision: res.Decision, MatchedID: res.Matched, Trace: tb, Providers: res.Providers}
+	if req.BreakGlass {
+		a.Severity = "high"
+		a.BreakGlass = true
+	}
+	err := e.audits.RecordAudit(&a)
+	return a.ID, err
 }
 
-var globCache sync.Map
-
+// resourceMatch matches value against a glob pattern. Invalid patterns match nothing.
 func resourceMatch(pattern, value string) bool {
-	if pattern == "" || pattern == "*" {
-		return true
-	}
-	if g, ok := globCache.Load(pattern); ok {
-		return g.(glob.Glob).Match(value)
-	}
-	g := glob.MustCompile(pattern)
-	globCache.Store(pattern, g)
-	return g.Match(value)
+	m, err := policy.CompileMatcher(policy.MatchGlob, pattern, nil)
+	return err == nil && m.Match(value)
 }
 
-func computeSpecificity(pattern string) int {
-	if pattern == "" {
-		return 0
-	}
-	wildcards := 0
-	for _, r := range pattern {
-		if r == '*' || r == '?' {
-			wildcards++
-		}
+// loadPolicies returns the enabled policies of provider that apply to action
+// on resource, in evaluation order, from the current snapshot.
+func (e *EvalEngine) loadPolicies(provider, action, resource string) ([]candidate, error) {
+	snap := e.snap.Load()
+	if snap == nil {
+		return nil, errNoSnapshot
 	}
-	return len(pattern) - (wildcards * 10)
-}
-
-func (e *EvalEngine) loadPolicies(provider, action string) ([]model.Policy, error) {
-    var policies []model.Policy
-
-    // Fix the typo: use 'enabled' instead of 'enab led'
-    q := e.db.Where("enabled = ? AND provider = ?", true, provider)
-
-    if action != "" {
-        q = q.Where("? = ANY(actions) OR array_length(actions,1) IS NULL", action)
-    }
-
-    return policies, q.Find(&policies).Error
+	return snap.providers[provider].lookup(action, resource), nil
 }
 
-
-func (e *EvalEngine) evaluatePolicy(p model.Policy, req Request) (string, *uuid.UUID, string, []TraceItem, error) {
+// evaluatePolicy evaluates a candidate's expression against req. A resource
+// pattern that did not compile when the snapshot was built is reported here.
+func (e *EvalEngine) evaluatePolicy(c candidate, req Request) (string, *uuid.UUID, string, []TraceItem, error) {
+	p := c.policy
 	var traceOut []TraceItem
+	if err := c.matchErr; err != nil {
+		traceOut = append(traceOut, TraceItem{PolicyID: p.ID, Effect: p.Effect, Error: "resource: " + err.Error(), Reason: "policy resource pattern is invalid"})
+		if e.failClosedFor(p.Provider) {
+			return "deny", &p.ID, fmt.Sprintf("Access denied by policy '%s': resource pattern is invalid", p.Name), traceOut, nil
+		}
+		return "allow", nil, "invalid resource pattern (fail-open)", traceOut, err
+	}
 	prog, err := e.compileOrGet(p.ID, p.Expr)
 	if err != nil {
 		traceOut = append(traceOut, TraceItem{PolicyID: p.ID, Effect: p.Effect, Error: "compile: " + err.Error(), Reason: "policy expression failed to compile"})
-		if e.failClosed {
+		if e.failClosedFor(p.Provider) {
 			return "deny", &p.ID, fmt.Sprintf("Access denied by policy '%s': expression failed to compile", p.Name), traceOut, nil
 		}
 		return "allow", nil, "expression failed to compile (fail-open)", traceOut, err
 	}
-	out, _, evalErr := prog.Eval(map[string]any{
-		"subject":  req.Subject,
-		"resource": req.Resource,
-		"action":   req.Action,
-		"metadata": req.Metadata,
-		"protocol": req.Protocol,
-		"platform": req.Platform,
-		"cloud":    req.Cloud,
-	})
+	out, _, evalErr := prog.Eval(activation(req))
 	if evalErr != nil {
 		traceOut = append(traceOut, TraceItem{PolicyID: p.ID, Effect: p.Effect, Error: "runtime: " + evalErr.Error(), Reason: "policy evaluation runtime error"})
-		if e.failClosed {
+		if e.failClosedFor(p.Provider) {
 			return "deny", &p.ID, fmt.Sprintf("Access denied by policy '%s': runtime error during evaluation", p.Name), traceOut, nil
 		}
 		return "allow", nil, "runtime error (fail-open)", traceOut, evalErr
@@ -279,7 +373,7 @@ func (e *EvalEngine) evaluatePolicy(p model.Policy, req Request) (string, *uuid.
 	b, ok := out.Value().(bool)
 	if !ok {
 		traceOut = append(traceOut, TraceItem{PolicyID: p.ID, Effect: p.Effect, Error: "non-boolean result", Reason: "policy expression did not return boolean"})
-		if e.failClosed {
+		if e.failClosedFor(p.Provider) {
 			return "deny", &p.ID, fmt.Sprintf("Access denied by policy '%s': expression did not return true/false", p.Name), traceOut, nil
 		}
 		return "allow", nil, "non-boolean result (fail-open)", traceOut, nil
@@ -301,6 +395,19 @@ func (e *EvalEngine) evaluatePolicy(p model.Policy, req Request) (string, *uuid.
 	return "", nil, "", traceOut, nil
 }
 
+// activation exposes req to CEL expressions.
+func activation(req Request) map[string]any {
+	return map[string]any{
+		"subject":  req.Subject,
+		"resource": req.Resource,
+		"action":   req.Action,
+		"metadata": req.Metadata,
+		"protocol": req.Protocol,
+		"platform": req.Platform,
+		"cloud":    req.Cloud,
+	}
+}
+
 func (e *EvalEngine) Invalidate(id uuid.UUID) { e.cache.Delete(id) }
 func (e *EvalEngine) InvalidateMany(ids []uuid.UUID) {
 	for _, id := range ids {
@@ -309,5 +416,5 @@ func (e *EvalEngine) InvalidateMany(ids []uuid.UUID) {
 }
 func (e *EvalEngine) InvalidateAll() {
 	e.cache.Range(func(k, _ any) bool { e.cache.Delete(k); return true })
+	e.InvalidateDecisions()
 }
- 
\ No newline at end of file
@@ -0,0 +1,129 @@
+package eval
+
+import (
+	"sort"
+	"strings"
+
+	"example.com/jit-engine/internal/policy"
+)
+
+// ResourceIndex finds the resource patterns that may match a resource without
+// scanning all of them. Patterns are filed in a trie under the segments of
+// their literal prefix (for globs, the text before the first meta character);
+// patterns with no literal prefix go to a residual list that is always
+// returned. Candidates are a superset of the matches, so callers still apply
+// the real matcher and get the same result as a linear scan.
+type ResourceIndex struct {
+	root     *trieNode
+	residual []int
+}
+
+type trieNode struct {
+	children map[string]*trieNode
+	// entries end at this node; rest is the partial segment left of their
+	// literal prefix, which must prefix the remainder of the resource.
+	entries []prefixEntry
+}
+
+type prefixEntry struct {
+	rest string
+	pos  int
+}
+
+// NewResourceIndex indexes matchers; positions returned by Candidates refer to
+// this slice. A nil matcher (a pattern that failed to compile) is always a
+// candidate so evaluation can report it.
+func NewResourceIndex(matchers []policy.Matcher) *ResourceIndex {
+	idx := &ResourceIndex{root: &trieNode{}}
+	for i, m := range matchers {
+		prefix := ""
+		if m != nil {
+			prefix = m.LiteralPrefix()
+		}
+		idx.add(prefix, i)
+	}
+	return idx
+}
+
+func (idx *ResourceIndex) add(prefix string, pos int) {
+	if prefix == "" {
+		idx.residual = append(idx.residual, pos)
+		return
+	}
+	segs, rest := splitSegments(prefix)
+	n := idx.root
+	for _, s := range segs {
+		if n.children == nil {
+			n.children = map[string]*trieNode{}
+		}
+		next, ok := n.children[s]
+		if !ok {
+			next = &trieNode{}
+			n.children[s] = next
+		}
+		n = next
+	}
+	n.entries = append(n.entries, prefixEntry{rest: rest, pos: pos})
+}
+
+// Candidates returns the ascending positions of patterns that may match resource.
+func (idx *ResourceIndex) Candidates(resource string) []int {
+	out := append([]int(nil), idx.residual...)
+	segs, _ := splitSegments(resource)
+	n := idx.root
+	remaining := resource
+	for i := 0; ; i++ {
+		for _, e := range n.entries {
+			if strings.HasPrefix(remaining, e.rest) {
+				out = append(out, e.pos)
+			}
+		}
+		if i == len(segs) {
+			break
+		}
+		next, ok := n.children[segs[i]]
+		if !ok {
+			break
+		}
+		n = next
+		remaining = remaining[len(segs[i]):]
+	}
+	sort.Ints(out)
+	return out
+}
+
+// scanMatches is the linear reference: positions of matchers accepting resource.
+func scanMatches(matchers []policy.Matcher, resource string) []int {
+	var out []int
+	for i, m := range matchers {
+		if m == nil || m.Match(resource) {
+			out = append(out, i)
+		}
+	}
+	return out
+}
+
+// Matches returns the positions of matchers accepting resource, in ascending order.
+func (idx *ResourceIndex) Matches(matchers []policy.Matcher, resource string) []int {
+	cands := idx.Candidates(resource)
+	out := cands[:0]
+	for _, i := range cands {
+		if m := matchers[i]; m == nil || m.Match(resource) {
+			out = append(out, i)
+		}
+	}
+	return out
+}
+
+// splitSegments cuts s after every ':' or '/'. The trailing text without a
+// separator is returned as rest.
+func splitSegments(s string) (segs []string, rest string) {
+	start := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] == ':' || s[i] == '/' {
+			segs = append(segs, s[start:i+1])
+			start = i + 1
+		}
+	}
+	return segs, s[start:]
+}
@@ -0,0 +1,108 @@
+package eval
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"example.com/jit-engine/internal/policy"
+)
+
+var benchKinds = []string{"ssh:unix:host/", "rdp:windows:host/", "aws:s3:bucket/", "db:oracle:instance/"}
+
+func randomPattern(rng *rand.Rand, n int) (matchKind, pattern string, exclude []string) {
+	kind := benchKinds[rng.Intn(len(benchKinds))]
+	id := rng.Intn(n)
+	switch rng.Intn(24) {
+	case 0:
+		return policy.MatchGlob, "*", nil
+	case 1:
+		return policy.MatchGlob, kind + "*", []string{kind + fmt.Sprintf("host-%d*", id)}
+	case 2:
+		return policy.MatchGlob, "*:" + kind[len(kind)-5:] + "*", nil
+	case 3:
+		return policy.MatchGlob, kind + fmt.Sprintf("host-%d-?", id), nil
+	case 4:
+		return policy.MatchGlob, kind + fmt.Sprintf("{host-%d,host-%d}", id, id+1), nil
+	case 5:
+		return policy.MatchRegex, kind + fmt.Sprintf(`host-%d(-\d)?`, id), nil
+	case 6:
+		return policy.MatchRegex, fmt.Sprintf(`.*host-%d`, id), nil
+	case 7:
+		return policy.MatchPrefix, kind + fmt.Sprintf("host-%d", id), nil
+	case 8, 9, 10:
+		return policy.MatchGlob, kind + fmt.Sprintf("host-%d*", id), nil
+	case 11, 12:
+		return policy.MatchExact, kind + fmt.Sprintf("host-%d", id), nil
+	default:
+		return policy.MatchGlob, kind + fmt.Sprintf("host-%d", id), nil
+	}
+}
+
+func randomResource(rng *rand.Rand, n int) string {
+	kind := benchKinds[rng.Intn(len(benchKinds))]
+	r := kind + fmt.Sprintf("host-%d", rng.Intn(n))
+	if rng.Intn(3) == 0 {
+		r += fmt.Sprintf("-%d", rng.Intn(10))
+	}
+	return r
+}
+
+// randomMatchers compiles n random patterns.
+func randomMatchers(tb testing.TB, rng *rand.Rand, n int) []policy.Matcher {
+	tb.Helper()
+	matchers := make([]policy.Matcher, n)
+	for i := range matchers {
+		kind, pattern, exclude := randomPattern(rng, n)
+		m, err := policy.CompileMatcher(kind, pattern, exclude)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		matchers[i] = m
+	}
+	return matchers
+}
+
+func TestIndexMatchesScan(t *testing.T) {
+	for _, n := range []int{1, 10, 1000, 20000} {
+		seed := rand.Int63()
+		rng := rand.New(rand.NewSource(seed))
+		// a pattern that failed to compile is always a match
+		matchers := append(randomMatchers(t, rng, n), nil)
+		idx := NewResourceIndex(matchers)
+		for i := 0; i < 2000; i++ {
+			r := randomResource(rng, n)
+			want := scanMatches(matchers, r)
+			if got := idx.Matches(matchers, r); !slices.Equal(want, got) {
+				t.Fatalf("seed %d, %d patterns: %q: index %v, scan %v", seed, n, r, got, want)
+			}
+		}
+	}
+}
+
+func benchmarkLookup(b *testing.B, lookup func(matchers []policy.Matcher, idx *ResourceIndex, r string) []int) {
+	rng := rand.New(rand.NewSource(1))
+	matchers := randomMatchers(b, rng, 50000)
+	idx := NewResourceIndex(matchers)
+	resources := make([]string, 1024)
+	for i := range resources {
+		resources[i] = randomResource(rng, 50000)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		lookup(matchers, idx, resources[i%len(resources)])
+	}
+}
+
+func BenchmarkIndex(b *testing.B) {
+	benchmarkLookup(b, func(matchers []policy.Matcher, idx *ResourceIndex, r string) []int {
+		return idx.Matches(matchers, r)
+	})
+}
+
+func BenchmarkScan(b *testing.B) {
+	benchmarkLookup(b, func(matchers []policy.Matcher, _ *ResourceIndex, r string) []int {
+		return scanMatches(matchers, r)
+	})
+}
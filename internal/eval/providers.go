@@ -0,0 +1,132 @@
+package eval
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"example.com/jit-engine/internal/model"
+	"example.com/jit-engine/internal/policy"
+)
+
+// providerDef is a registered provider prepared for evaluation.
+type providerDef struct {
+	model.Provider
+	resources []policy.Matcher
+	actions   policy.ActionSet
+	schema    *policy.Schema
+	schemaErr error
+}
+
+// providerRegistry resolves the provider named by a request, by name or alias.
+type providerRegistry struct {
+	byName map[string]*providerDef
+	alias  map[string]string
+}
+
+// newProviderRegistry expands group references in each provider's actions
+// with catalog. The API refuses them, but bundles and embedded sources can
+// carry them. Hierarchies are not applied: a provider supports the actions
+// it lists, as policy writes are checked.
+func newProviderRegistry(ps []model.Provider, catalog *policy.ActionCatalog) *providerRegistry {
+	groups := &policy.ActionCatalog{}
+	if catalog != nil {
+		groups.Groups = catalog.Groups
+	}
+	r := &providerRegistry{byName: make(map[string]*providerDef, len(ps)), alias: map[string]string{}}
+	for _, p := range ps {
+		d := &providerDef{Provider: p, actions: groups.Expand(p.Name, p.Actions)}
+		for _, pat := range p.ResourcePatterns {
+			// Patterns are validated on write; one that no longer compiles
+			// matches nothing rather than everything.
+			m, err := policy.CompileMatcher(policy.MatchGlob, pat, nil)
+			if err != nil {
+				m = noMatch{}
+			}
+			d.resources = append(d.resources, m)
+		}
+		// An unreadable schema rejects every request instead of none.
+		d.schema, d.schemaErr = policy.ParseSchema(p.RequestSchema)
+		r.byName[p.Name] = d
+		for _, a := range p.Aliases {
+			r.alias[a] = p.Name
+		}
+	}
+	return r
+}
+
+func (r *providerRegistry) resolve(name string) (*providerDef, bool) {
+	if r == nil {
+		return nil, false
+	}
+	if d, ok := r.byName[name]; ok {
+		return d, true
+	}
+	d, ok := r.byName[r.alias[name]]
+	return d, ok
+}
+
+// check returns why req does not fit the provider, or "". mismatch is set
+// when the resource or action is outside the provider's conventions, as
+// opposed to a request the provider rejects.
+func (d *providerDef) check(req Request) (reason string, mismatch bool) {
+	if len(d.resources) > 0 {
+		ok := false
+		for _, m := range d.resources {
+			if m.Match(req.Resource) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return fmt.Sprintf("Access denied: resource '%s' does not follow the conventions of provider '%s'", req.Resource, d.Name), true
+		}
+	}
+	if _, ok := d.actions.Match(req.Action); !ok {
+		return fmt.Sprintf("Access denied: action '%s' is not supported by provider '%s'", req.Action, d.Name), true
+	}
+	if d.schemaErr != nil {
+		return fmt.Sprintf("Access denied: provider '%s' has an invalid request schema", d.Name), false
+	}
+	if d.schema != nil {
+		var v any
+		b, _ := json.Marshal(req)
+		_ = json.Unmarshal(b, &v)
+		if err := d.schema.Validate(v); err != nil {
+			return fmt.Sprintf("Access denied: invalid request for provider '%s': %v", d.Name, err), false
+		}
+	}
+	return "", false
+}
+
+type noMatch struct{}
+
+func (noMatch) Match(string) bool     { return false }
+func (noMatch) LiteralPrefix() string { return "" }
+
+// resolveProvider looks up a provider by name or alias in the current snapshot.
+func (e *EvalEngine) resolveProvider(name string) (*providerDef, bool) {
+	s := e.snap.Load()
+	if s == nil {
+		return nil, false
+	}
+	return s.registry.resolve(name)
+}
+
+// ResolveProvider returns the canonical name of the provider registered as
+// name or with name as an alias.
+func (e *EvalEngine) ResolveProvider(name string) (string, bool) {
+	d, ok := e.resolveProvider(name)
+	if !ok {
+		return "", false
+	}
+	return d.Name, true
+}
+
+// failClosedFor reports whether evaluation errors deny for provider, honouring
+// the provider's override of the engine setting.
+func (e *EvalEngine) failClosedFor(provider string) bool {
+	if d, ok := e.resolveProvider(provider); ok && d.FailClosed != nil {
+		return *d.FailClosed
+	}
+	return e.failClosed
+}
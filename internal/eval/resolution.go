@@ -0,0 +1,248 @@
+package eval
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/uuid"
+
+	"example.com/jit-engine/internal/model"
+	"example.com/jit-engine/internal/policy"
+)
+
+// How the decisions of several resolved providers are combined.
+const (
+	// CombineAll allows only when every provider allows.
+	CombineAll = "all"
+	// CombineAny allows when one provider allows and none denies.
+	CombineAny = "any"
+	// CombineFirst takes the decision of the first provider that reaches one.
+	CombineFirst = "first"
+)
+
+// resolutionRule is a ResolutionRule prepared for evaluation.
+type resolutionRule struct {
+	model.ResolutionRule
+	fields map[string]policy.Matcher
+	prog   cel.Program
+}
+
+// compileRules prepares enabled rules, already in priority order. Rules are
+// validated on write; one that no longer compiles is skipped and logged.
+func compileRules(env *cel.Env, rules []model.ResolutionRule) []resolutionRule {
+	out := make([]resolutionRule, 0, len(rules))
+	for _, r := range rules {
+		rr := resolutionRule{ResolutionRule: r, fields: map[string]policy.Matcher{}}
+		if err := rr.compile(env); err != nil {
+			log.Printf("resolution rule %s (%s) skipped: %v", r.ID, r.Name, err)
+			continue
+		}
+		out = append(out, rr)
+	}
+	return out
+}
+
+func (r *resolutionRule) compile(env *cel.Env) error {
+	for field, v := range r.Match {
+		pattern, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("match %q must be a string", field)
+		}
+		m, err := policy.CompileMatcher(policy.MatchGlob, pattern, nil)
+		if err != nil {
+			return err
+		}
+		r.fields[field] = m
+	}
+	if r.Expr == "" {
+		return nil
+	}
+	ast, iss := env.Compile(r.Expr)
+	if iss != nil && iss.Err() != nil {
+		return iss.Err()
+	}
+	prog, err := env.Program(ast)
+	if err != nil {
+		return err
+	}
+	r.prog = prog
+	return nil
+}
+
+func (r *resolutionRule) matches(req Request, fields map[string]any) (bool, error) {
+	for field, m := range r.fields {
+		v, ok := lookupPath(fields, field)
+		if !ok || v == nil || !m.Match(fmt.Sprint(v)) {
+			return false, nil
+		}
+	}
+	if r.prog == nil {
+		return true, nil
+	}
+	out, _, err := r.prog.Eval(activation(req))
+	if err != nil {
+		return false, fmt.Errorf("resolution rule '%s': %w", r.Name, err)
+	}
+	b, ok := out.Value().(bool)
+	if !ok {
+		return false, fmt.Errorf("resolution rule '%s': expression did not return true/false", r.Name)
+	}
+	return b, nil
+}
+
+// defaultProvider is the provider named by the request itself: cloud, or
+// protocol when cloud is empty or "none".
+func defaultProvider(req Request) string {
+	if req.Cloud == "" || req.Cloud == "none" {
+		return req.Protocol
+	}
+	return req.Cloud
+}
+
+// resolveProviders picks the provider policy sets req goes through and how
+// their decisions combine. A non-empty reason denies the request.
+func (e *EvalEngine) resolveProviders(req Request) (defs []*providerDef, combine, reason string, err error) {
+	snap := e.snap.Load()
+	if snap == nil {
+		return nil, "", "", errNoSnapshot
+	}
+	names := []string{defaultProvider(req)}
+	combine = CombineAll
+	if len(snap.rules) > 0 {
+		var fields map[string]any
+		b, _ := json.Marshal(req)
+		_ = json.Unmarshal(b, &fields)
+		for _, r := range snap.rules {
+			ok, err := r.matches(req, fields)
+			if err != nil {
+				return nil, "", "", err
+			}
+			if ok {
+				names, combine = r.Providers, r.Combine
+				break
+			}
+		}
+	}
+	seen := map[string]bool{}
+	for _, n := range names {
+		switch n {
+		case "$cloud":
+			if n = req.Cloud; n == "none" {
+				n = ""
+			}
+		case "$protocol":
+			n = req.Protocol
+		case "$platform":
+			n = req.Platform
+		}
+		if n == "" {
+			continue
+		}
+		d, ok := snap.registry.resolve(n)
+		if !ok {
+			return nil, "", fmt.Sprintf("Access denied: unknown provider '%s'", n), nil
+		}
+		if !seen[d.Name] {
+			seen[d.Name] = true
+			defs = append(defs, d)
+		}
+	}
+	if len(defs) == 0 {
+		return nil, "", "Access denied: no provider specified", nil
+	}
+	return defs, combine, "", nil
+}
+
+// providerOutcome is one provider's verdict on a request.
+type providerOutcome struct {
+	provider string
+	decision string // allow, deny or "" when nothing decided
+	matched  *uuid.UUID
+	reason   string
+	// skipped is set when the request does not fit the provider's
+	// conventions and combine lets another provider decide.
+	skipped bool
+}
+
+// evaluateProviders evaluates req against each resolved provider and combines
+// their outcomes. ok is false when no decision was reached. Under any and
+// first, providers whose conventions the request does not fit are skipped;
+// it is denied only when it fits none of them.
+func (e *EvalEngine) evaluateProviders(req Request, st *evalState, defs []*providerDef, combine string, traceOut []TraceItem) (Result, bool, error) {
+	var allows []providerOutcome
+	var skipped []string
+	undecided := false
+	for _, def := range defs {
+		out, trace, err := e.evaluateProvider(req, st, def, combine)
+		traceOut = append(traceOut, trace...)
+		if err != nil {
+			return Result{Decision: out.decision, Matched: out.matched, Reason: out.reason, Trace: traceOut}, true, err
+		}
+		switch {
+		case out.decision == "deny":
+			return Result{Decision: "deny", Matched: out.matched, Reason: out.reason, Trace: traceOut}, true, nil
+		case out.skipped:
+			skipped = append(skipped, out.reason)
+		case out.decision == "allow":
+			if combine == CombineFirst {
+				return Result{Decision: "allow", Matched: out.matched, Reason: out.reason, Trace: traceOut}, true, nil
+			}
+			allows = append(allows, out)
+		default:
+			undecided = true
+		}
+	}
+	if len(skipped) == len(defs) {
+		return Result{Decision: "deny", Reason: strings.Join(skipped, "; "), Trace: traceOut}, true, nil
+	}
+	if len(allows) == 0 || (combine == CombineAll && undecided) {
+		return Result{Trace: traceOut}, false, nil
+	}
+	res := Result{Decision: "allow", Trace: traceOut}
+	var reasons []string
+	for _, a := range allows {
+		if a.matched != nil {
+			res.Matched = a.matched
+		}
+		reasons = append(reasons, a.reason)
+	}
+	res.Reason = strings.Join(reasons, "; ")
+	return res, true, nil
+}
+
+func (e *EvalEngine) evaluateProvider(req Request, st *evalState, def *providerDef, combine string) (providerOutcome, []TraceItem, error) {
+	out := providerOutcome{provider: def.Name}
+	if reason, mismatch := def.check(req); reason != "" {
+		out.reason = reason
+		if mismatch && combine != CombineAll {
+			out.skipped = true
+		} else {
+			out.decision = "deny"
+		}
+		return out, nil, nil
+	}
+	st.providers = append(st.providers, def.Name)
+	cands, err := e.loadPolicies(def.Name, req.Action, req.Resource)
+	if err != nil {
+		res := e.loadFailure(def.Name, err, nil)
+		out.decision, out.reason = res.Decision, res.Reason
+		return out, nil, err
+	}
+	decision, matched, reason, trace, winner, err := e.evaluateCandidates(cands, req)
+	if err != nil || decision == "deny" {
+		out.decision, out.matched, out.reason = decision, matched, reason
+		return out, trace, err
+	}
+	switch {
+	case winner != nil:
+		out.decision, out.matched = "allow", &winner.ID
+		out.reason = policyMessageOrDefault(*winner, fmt.Sprintf("Access allowed by policy '%s'", winner.Name))
+	case def.DefaultDecision == "allow":
+		out.decision = "allow"
+		out.reason = fmt.Sprintf("Access allowed: no policy matched and provider '%s' defaults to allow", def.Name)
+	}
+	return out, trace, nil
+}
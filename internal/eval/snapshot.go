@@ -0,0 +1,302 @@
+package eval
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"example.com/jit-engine/internal/model"
+	"example.com/jit-engine/internal/policy"
+)
+
+var errNoSnapshot = errors.New("policy snapshot not loaded")
+
+// snapshot is an immutable, pre-sorted view of every enabled policy. It is
+// built by Reload and swapped in atomically; evaluations never touch the
+// database for policies.
+type snapshot struct {
+	loadedAt  time.Time
+	providers map[string]*policySet
+	sod       []model.SoDConstraint
+	// fingerprints summarise each provider's policies so Reload can tell
+	// which policy sets changed.
+	fingerprints  map[string]string
+	sodPrint      string
+	catalog       *policy.ActionCatalog
+	catalogPrint  string
+	registry      *providerRegistry
+	registryPrint string
+	// rules are the enabled resolution rules in priority order.
+	rules      []resolutionRule
+	rulesPrint string
+	// updated maps policy ID to UpdatedAt; Reload drops compiled programs of
+	// policies whose entry changed or disappeared.
+	updated map[uuid.UUID]time.Time
+}
+
+// policySet holds one provider's policies in evaluation order with an index
+// over their resource patterns and their expanded actions.
+type policySet struct {
+	policies  []model.Policy
+	matchers  []policy.Matcher
+	matchErrs []error
+	actions   []policy.ActionSet
+	resources *ResourceIndex
+}
+
+// candidate is a policy selected for a request.
+type candidate struct {
+	policy model.Policy
+	// via is the policy action entry that covered the request action, e.g.
+	// "@readonly", "s3:Get*" or "admin -> write -> read".
+	via string
+	// matchErr is why the policy's resource pattern did not compile.
+	matchErr error
+}
+
+// SnapshotInfo describes the snapshot currently used for evaluation.
+type SnapshotInfo struct {
+	LoadedAt  time.Time      `json:"loaded_at"`
+	Policies  int            `json:"policies"`
+	Providers map[string]int `json:"providers"`
+}
+
+func buildSnapshot(policies []model.Policy, sod []model.SoDConstraint, catalog *policy.ActionCatalog, providers []model.Provider, rules []resolutionRule) *snapshot {
+	byProvider := map[string][]model.Policy{}
+	for _, p := range policies {
+		byProvider[p.Provider] = append(byProvider[p.Provider], p)
+	}
+	s := &snapshot{
+		loadedAt:     time.Now().UTC(),
+		providers:    make(map[string]*policySet, len(byProvider)),
+		sod:          sod,
+		catalog:      catalog,
+		registry:     newProviderRegistry(providers, catalog),
+		rules:        rules,
+		fingerprints: make(map[string]string, len(byProvider)),
+		updated:      make(map[uuid.UUID]time.Time, len(policies)),
+	}
+	for _, p := range policies {
+		s.updated[p.ID] = p.UpdatedAt
+	}
+	for provider, ps := range byProvider {
+		sortPolicies(ps)
+		set := &policySet{policies: ps, matchers: make([]policy.Matcher, len(ps)), matchErrs: make([]error, len(ps)), actions: make([]policy.ActionSet, len(ps))}
+		h := sha256.New()
+		for i, p := range ps {
+			// A pattern that does not compile leaves a nil matcher; the
+			// policy then reaches evaluatePolicy, which reports it.
+			set.matchers[i], set.matchErrs[i] = policy.CompileMatcher(p.MatchKind, p.Resource, p.ExcludeResources)
+			set.actions[i] = catalog.Expand(provider, p.Actions)
+			fmt.Fprintf(h, "%s|%s\n", p.ID, p.UpdatedAt.UTC().Format(time.RFC3339Nano))
+		}
+		set.resources = NewResourceIndex(set.matchers)
+		s.providers[provider] = set
+		s.fingerprints[provider] = fmt.Sprintf("%x", h.Sum(nil))
+	}
+	h := sha256.New()
+	for _, c := range sod {
+		fmt.Fprintf(h, "%s|%s\n", c.ID, c.UpdatedAt.UTC().Format(time.RFC3339Nano))
+	}
+	s.sodPrint = fmt.Sprintf("%x", h.Sum(nil))
+	s.catalogPrint = catalogFingerprint(catalog)
+	h = sha256.New()
+	for _, p := range providers {
+		fmt.Fprintf(h, "%s|%s\n", p.Name, p.UpdatedAt.UTC().Format(time.RFC3339Nano))
+	}
+	s.registryPrint = fmt.Sprintf("%x", h.Sum(nil))
+	h = sha256.New()
+	for _, r := range rules {
+		fmt.Fprintf(h, "%s|%s\n", r.ID, r.UpdatedAt.UTC().Format(time.RFC3339Nano))
+	}
+	s.rulesPrint = fmt.Sprintf("%x", h.Sum(nil))
+	return s
+}
+
+func catalogFingerprint(c *policy.ActionCatalog) string {
+	h := sha256.New()
+	if c != nil {
+		b, _ := json.Marshal(c)
+		h.Write(b)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+func loadCatalog(groups []model.ActionGroup, hierarchies []model.ActionHierarchy) *policy.ActionCatalog {
+	c := &policy.ActionCatalog{Groups: map[string][]string{}, Implies: map[string]map[string][]string{}}
+	for _, g := range groups {
+		c.Groups[g.Name] = g.Actions
+	}
+	for _, h := range hierarchies {
+		if c.Implies[h.Provider] == nil {
+			c.Implies[h.Provider] = map[string][]string{}
+		}
+		c.Implies[h.Provider][h.Action] = h.Implies
+	}
+	return c
+}
+
+// sortPolicies orders policies by priority asc, specificity desc, created_at asc, uuid asc.
+func sortPolicies(ps []model.Policy) {
+	sort.SliceStable(ps, func(i, j int) bool {
+		if ps[i].Priority != ps[j].Priority {
+			return ps[i].Priority < ps[j].Priority
+		}
+		si, sj := policy.Specificity(ps[i].Resource), policy.Specificity(ps[j].Resource)
+		if si != sj {
+			return si > sj
+		}
+		if !ps[i].CreatedAt.Equal(ps[j].CreatedAt) {
+			return ps[i].CreatedAt.Before(ps[j].CreatedAt)
+		}
+		return strings.Compare(ps[i].ID.String(), ps[j].ID.String()) < 0
+	})
+}
+
+// lookup returns the policies that cover action and whose resource pattern
+// matches resource, in evaluation order.
+func (set *policySet) lookup(action, resource string) []candidate {
+	if set == nil {
+		return nil
+	}
+	var out []candidate
+	for _, i := range set.resources.Matches(set.matchers, resource) {
+		if via, ok := set.actions[i].Match(action); ok {
+			if via == action {
+				via = ""
+			}
+			out = append(out, candidate{policy: set.policies[i], via: via, matchErr: set.matchErrs[i]})
+		}
+	}
+	return out
+}
+
+func (s *snapshot) info() SnapshotInfo {
+	info := SnapshotInfo{LoadedAt: s.loadedAt, Providers: map[string]int{}}
+	for name, set := range s.providers {
+		info.Providers[name] = len(set.policies)
+		info.Policies += len(set.policies)
+	}
+	return info
+}
+
+// Reload rebuilds the snapshot from the database and swaps it in. Cached
+// decisions are invalidated for every provider whose policies changed.
+func (e *EvalEngine) Reload() (SnapshotInfo, error) {
+	e.reloadMu.Lock()
+	defer e.reloadMu.Unlock()
+
+	policies, err := e.policies.EnabledPolicies()
+	if err != nil {
+		return SnapshotInfo{}, err
+	}
+	sod, err := e.policies.EnabledSoDConstraints()
+	if err != nil {
+		return SnapshotInfo{}, err
+	}
+	groups, err := e.policies.ActionGroups()
+	if err != nil {
+		return SnapshotInfo{}, err
+	}
+	hierarchies, err := e.policies.ActionHierarchies()
+	if err != nil {
+		return SnapshotInfo{}, err
+	}
+	providers, err := e.policies.Providers()
+	if err != nil {
+		return SnapshotInfo{}, err
+	}
+	rules, err := e.policies.EnabledResolutionRules()
+	if err != nil {
+		return SnapshotInfo{}, err
+	}
+	next := buildSnapshot(policies, sod, loadCatalog(groups, hierarchies), providers, compileRules(e.env, rules))
+	// Programs are cached by policy ID, so drop those whose policy was edited
+	// or removed, possibly by another replica, before warming the cache.
+	if prev := e.snap.Load(); prev != nil {
+		for id, at := range prev.updated {
+			if nat, ok := next.updated[id]; !ok || !nat.Equal(at) {
+				e.cache.Delete(id)
+			}
+		}
+	}
+	// Warm the program cache; compile errors surface in the trace at evaluation.
+	for _, p := range policies {
+		_, _ = e.compileOrGet(p.ID, p.Expr)
+	}
+	prev := e.snap.Swap(next)
+	if prev != nil {
+		var changed []string
+		for name, fp := range next.fingerprints {
+			if prev.fingerprints[name] != fp {
+				changed = append(changed, name)
+			}
+		}
+		for name := range prev.fingerprints {
+			if _, ok := next.fingerprints[name]; !ok {
+				changed = append(changed, name)
+			}
+		}
+		if len(changed) > 0 {
+			e.bumpRevisions(changed...)
+		}
+		if prev.sodPrint != next.sodPrint || prev.catalogPrint != next.catalogPrint ||
+			prev.registryPrint != next.registryPrint || prev.rulesPrint != next.rulesPrint {
+			e.InvalidateDecisions()
+		}
+	}
+	return next.info(), nil
+}
+
+// Resync discards every compiled program and cached decision and rebuilds
+// the snapshot from scratch. It is the fallback when change notifications
+// may have been missed.
+func (e *EvalEngine) Resync() (SnapshotInfo, error) {
+	e.cache.Range(func(k, _ any) bool { e.cache.Delete(k); return true })
+	e.InvalidateDecisions()
+	return e.Reload()
+}
+
+// ExpandActions resolves policy action entries for provider with the action
+// groups and hierarchies of the current snapshot.
+func (e *EvalEngine) ExpandActions(provider string, entries []string) policy.ActionSet {
+	var c *policy.ActionCatalog
+	if s := e.snap.Load(); s != nil {
+		c = s.catalog
+	}
+	return c.Expand(provider, entries)
+}
+
+// SnapshotInfo describes the snapshot in use, or reports false if none was loaded yet.
+func (e *EvalEngine) SnapshotInfo() (SnapshotInfo, bool) {
+	s := e.snap.Load()
+	if s == nil {
+		return SnapshotInfo{}, false
+	}
+	return s.info(), true
+}
+
+// RunRefresh reloads the snapshot every interval until ctx is done, picking up
+// changes made outside this process.
+func (e *EvalEngine) RunRefresh(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if _, err := e.Reload(); err != nil {
+				log.Printf("policy snapshot refresh: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package eval
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"example.com/jit-engine/internal/model"
+)
+
+// SoDViolation identifies the separation-of-duties constraint behind a trace entry.
+type SoDViolation struct {
+	ConstraintID uuid.UUID `json:"constraint_id"`
+	Name         string    `json:"name"`
+	Kind         string    `json:"kind"`
+	Detail       string    `json:"detail"`
+}
+
+// checkSoD evaluates every enabled constraint and reports each violation as its
+// own trace entry. The returned reason describes the first violation.
+func (e *EvalEngine) checkSoD(req Request, st *evalState) ([]TraceItem, string, error) {
+	snap := e.snap.Load()
+	if snap == nil {
+		return nil, "", errNoSnapshot
+	}
+	var trace []TraceItem
+	var reason string
+	for _, c := range snap.sod {
+		if c.Resource != "" && !resourceMatch(c.Resource, req.Resource) {
+			continue
+		}
+		var detail string
+		switch c.Kind {
+		case "static":
+			detail = staticSoDViolation(c, req)
+		case "dynamic":
+			if inActions(c.Actions, req.Action) {
+				// History-based: the answer changes as audits accumulate.
+				st.volatile = true
+			}
+			d, err := e.dynamicSoDViolation(c, req)
+			if err != nil {
+				return trace, reason, err
+			}
+			detail = d
+		}
+		if detail == "" {
+			continue
+		}
+		r := c.Message
+		if r == "" {
+			r = fmt.Sprintf("Access denied: separation-of-duties constraint '%s' violated", c.Name)
+		}
+		if reason == "" {
+			reason = r
+		}
+		deny := true
+		trace = append(trace, TraceItem{Effect: "deny", Result: &deny, Reason: r, SoD: &SoDViolation{ConstraintID: c.ID, Name: c.Name, Kind: c.Kind, Detail: detail}})
+	}
+	return trace, reason, nil
+}
+
+func staticSoDViolation(c model.SoDConstraint, req Request) string {
+	v, ok := lookupPath(req.Subject, c.Attribute)
+	if !ok {
+		return ""
+	}
+	held := map[string]bool{}
+	switch t := v.(type) {
+	case string:
+		held[t] = true
+	case []any:
+		for _, x := range t {
+			if s, ok := x.(string); ok {
+				held[s] = true
+			}
+		}
+	}
+	var conflicting []string
+	for _, want := range c.Values {
+		if held[want] {
+			conflicting = append(conflicting, want)
+		}
+	}
+	if len(conflicting) < 2 {
+		return ""
+	}
+	return fmt.Sprintf("subject.%s holds mutually exclusive values %s", c.Attribute, strings.Join(conflicting, ", "))
+}
+
+// dynamicSoDViolation looks for an earlier allow, recorded in the audit store,
+// of a conflicting action by the same subject within the same scope.
+func (e *EvalEngine) dynamicSoDViolation(c model.SoDConstraint, req Request) (string, error) {
+	var others []string
+	inSet := false
+	for _, a := range c.Actions {
+		if a == req.Action {
+			inSet = true
+		} else {
+			others = append(others, a)
+		}
+	}
+	subject := SubjectID(req)
+	if !inSet || len(others) == 0 || subject == "" {
+		return "", nil
+	}
+	scope, ok := req.Metadata[c.ScopeKey]
+	if !ok || scope == nil {
+		return "", nil
+	}
+	scopeValue := fmt.Sprint(scope)
+	prior, err := e.audits.LastAllow(subject, c.ScopeKey, scopeValue, others)
+	if err != nil || prior == nil {
+		return "", err
+	}
+	return fmt.Sprintf("subject '%s' was already allowed a conflicting action for %s '%s' (audit %s)", subject, c.ScopeKey, scopeValue, prior.ID), nil
+}
+
+func inActions(actions []string, action string) bool {
+	for _, a := range actions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupPath resolves a dotted path such as "org.groups" inside m.
+func lookupPath(m map[string]any, path string) (any, bool) {
+	var cur any = m
+	for _, part := range strings.Split(path, ".") {
+		mm, ok := cur.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if cur, ok = mm[part]; !ok {
+			return nil, false
+		}
+	}
+	return cur, true
+}
@@ -0,0 +1,200 @@
+// Package gate checks policy writes before they commit. A write runs in a
+// transaction together with its checks: the stored regression test cases
+// must give the same results as before, and in strict mode the written
+// policies may not conflict with others. Single-policy endpoints, policy
+// file applies and bundle imports all go through it.
+package gate
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"example.com/jit-engine/internal/eval"
+	"example.com/jit-engine/internal/lint"
+	"example.com/jit-engine/internal/model"
+	"example.com/jit-engine/internal/policytest"
+	"example.com/jit-engine/internal/store"
+)
+
+// How writes treat conflicts with existing policies.
+const (
+	// ConflictsWarn returns conflicts with the written policies; the default.
+	ConflictsWarn = "warn"
+	// ConflictsStrict rejects writes that introduce conflicts.
+	ConflictsStrict = "strict"
+	// ConflictsOff skips the check.
+	ConflictsOff = "off"
+)
+
+// ValidMode reports whether mode names a conflict mode.
+func ValidMode(mode string) bool {
+	return mode == ConflictsWarn || mode == ConflictsStrict || mode == ConflictsOff
+}
+
+// ConflictError rejects a write that introduces conflicts in strict mode.
+type ConflictError struct {
+	Conflicts []lint.Conflict
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("policy conflicts with %d existing policy(ies)", len(e.Conflicts))
+}
+
+// RegressionError rejects a write that breaks stored test cases.
+type RegressionError struct {
+	Broken []policytest.Regression
+}
+
+func (e *RegressionError) Error() string {
+	return fmt.Sprintf("change breaks %d test case(s)", len(e.Broken))
+}
+
+// CheckError is a failure to run the checks, as opposed to a rejected or
+// failed write.
+type CheckError struct {
+	Err error
+}
+
+func (e *CheckError) Error() string { return "checking policy change: " + e.Err.Error() }
+
+func (e *CheckError) Unwrap() error { return e.Err }
+
+// Gate checks writes to a store.
+type Gate struct {
+	// Engine supplies the configuration the test cases are decided with,
+	// before and after the write. Nil skips the test cases.
+	Engine *eval.EvalEngine
+	// ConflictMode is ConflictsWarn (the default when empty),
+	// ConflictsStrict or ConflictsOff.
+	ConflictMode string
+}
+
+// Write runs write in a transaction of s and commits only if the checks
+// pass. write returns the policies it created or updated, as stored, which
+// are checked for conflicts. Test cases attached to the policies in skip,
+// which the write deletes, are not run. Write returns the conflicts found;
+// a rejected write is a *RegressionError or *ConflictError and a failure to
+// check a *CheckError. Errors from write are returned as they are.
+func (g Gate) Write(s store.PolicyStore, skip []uuid.UUID, write func(store.PolicyStore) ([]model.Policy, error)) ([]lint.Conflict, error) {
+	tx, ok := s.(store.Transactor)
+	if !ok {
+		return nil, &CheckError{Err: errors.New("store does not support transactions")}
+	}
+	tests, err := newRegressions(s, g.Engine, skip)
+	if err != nil {
+		return nil, &CheckError{Err: err}
+	}
+	var conflicts []lint.Conflict
+	err = tx.Transaction(func(s store.PolicyStore) error {
+		written, err := write(s)
+		if err != nil {
+			return err
+		}
+		if conflicts, err = g.conflicts(s, written); err != nil {
+			return err
+		}
+		broken, err := tests.check(s)
+		if err != nil {
+			return &CheckError{Err: err}
+		}
+		if len(broken) > 0 {
+			return &RegressionError{Broken: broken}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return conflicts, nil
+}
+
+// conflicts returns the conflicts written, as stored in s, has with the
+// other policies of their providers. In strict mode a conflict is an error.
+func (g Gate) conflicts(s store.PolicyStore, written []model.Policy) ([]lint.Conflict, error) {
+	if g.ConflictMode == ConflictsOff || len(written) == 0 {
+		return nil, nil
+	}
+	l, err := lint.New(s, nil)
+	if err != nil {
+		return nil, &CheckError{Err: err}
+	}
+	others := map[string][]model.Policy{}
+	seen := map[[2]uuid.UUID]bool{}
+	var cs []lint.Conflict
+	for i := range written {
+		p := &written[i]
+		if _, ok := others[p.Provider]; !ok {
+			ps, err := s.ListPolicies(store.PolicyFilter{Provider: p.Provider})
+			if err != nil {
+				return nil, &CheckError{Err: err}
+			}
+			others[p.Provider] = ps
+		}
+		// Two written policies conflicting with each other are one conflict
+		for _, c := range l.Conflicts(p, others[p.Provider]) {
+			key := [2]uuid.UUID{c.Allow.ID, c.Deny.ID}
+			if !seen[key] {
+				seen[key] = true
+				cs = append(cs, c)
+			}
+		}
+	}
+	if g.ConflictMode == ConflictsStrict && len(cs) > 0 {
+		return cs, &ConflictError{Conflicts: cs}
+	}
+	return cs, nil
+}
+
+// regressions runs the stored test cases against a proposed change.
+type regressions struct {
+	engine *eval.EvalEngine
+	suite  policytest.Suite
+	before *policytest.Report
+}
+
+// newRegressions evaluates the stored test cases, except those attached to
+// the policies in skip, as the baseline. Both runs use previews of eng, so
+// neither sees the decision cache or the audit history and a case that
+// depends on history gives the same result on both sides. It returns nil
+// when there is nothing to check.
+func newRegressions(s store.PolicyStore, eng *eval.EvalEngine, skip []uuid.UUID) (*regressions, error) {
+	if eng == nil {
+		return nil, nil
+	}
+	tcs, err := s.EnabledTestCases()
+	if err != nil || len(tcs) == 0 {
+		return nil, err
+	}
+	suite, err := policytest.StoredSuite(tcs, skip...)
+	if err != nil {
+		return nil, err
+	}
+	base, err := eng.Preview(s)
+	if err != nil {
+		return nil, err
+	}
+	before, err := policytest.Run(&policytest.Local{Engine: base, Store: s}, []policytest.Suite{suite})
+	if err != nil {
+		return nil, err
+	}
+	return &regressions{engine: eng, suite: suite, before: before}, nil
+}
+
+// check runs the cases against tx, which holds the change, and returns the
+// cases it breaks.
+func (r *regressions) check(tx store.PolicyStore) ([]policytest.Regression, error) {
+	if r == nil {
+		return nil, nil
+	}
+	preview, err := r.engine.Preview(tx)
+	if err != nil {
+		return nil, err
+	}
+	after, err := policytest.Run(&policytest.Local{Engine: preview, Store: tx}, []policytest.Suite{r.suite})
+	if err != nil {
+		return nil, err
+	}
+	return policytest.Regressions(r.before, after), nil
+}
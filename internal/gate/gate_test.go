@@ -0,0 +1,163 @@
+package gate
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"example.com/jit-engine/internal/eval"
+	"example.com/jit-engine/internal/model"
+	"example.com/jit-engine/internal/store"
+)
+
+// newStore returns a store with an ssh policy allowing everything, a
+// dynamic SoD constraint between submitting and approving a change, and an
+// engine over it.
+func newStore(t *testing.T) (*store.Memory, *eval.EvalEngine, *model.Policy) {
+	t.Helper()
+	m := store.NewMemory()
+	allow := &model.Policy{Name: "ssh", Provider: "ssh", Effect: "allow", Expr: "true", Resource: "ssh:unix:*", Actions: []string{"*"}}
+	if err := m.CreatePolicy(allow); err != nil {
+		t.Fatal(err)
+	}
+	m.AddSoDConstraint(model.SoDConstraint{Name: "four eyes", Kind: "dynamic", Actions: []string{"submit", "approve"}, ScopeKey: "change", Enabled: true})
+	eng, err := eval.NewEngine(m, m, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m, eng, allow
+}
+
+func request(subject, action string) eval.Request {
+	return eval.Request{Subject: map[string]any{"id": subject}, Resource: "ssh:unix:host/h1", Action: action, Protocol: "ssh", Metadata: map[string]any{"change": "CHG1"}}
+}
+
+func addCase(t *testing.T, m *store.Memory, name string, req eval.Request, decision string) {
+	t.Helper()
+	b, err := json.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.AddTestCase(model.PolicyTestCase{Name: name, Request: b, ExpectDecision: decision, Enabled: true})
+}
+
+func TestWriteKeepsHistoryDependentCases(t *testing.T) {
+	m, eng, _ := newStore(t)
+	if res, err := eng.EvaluateAndAudit(request("alice", "submit")); err != nil || res.Decision != "allow" {
+		t.Fatalf("submit: %v %v", res.Decision, err)
+	}
+	// Passes on the live engine only because alice's submit was audited
+	addCase(t, m, "alice may not approve her change", request("alice", "approve"), "deny")
+
+	unrelated := model.Policy{Name: "rdp", Provider: "rdp", Effect: "allow", Expr: "true", Resource: "rdp:*", Actions: []string{"login"}}
+	_, err := Gate{Engine: eng}.Write(m, nil, func(s store.PolicyStore) ([]model.Policy, error) {
+		return []model.Policy{unrelated}, s.CreatePolicy(&unrelated)
+	})
+	if err != nil {
+		t.Fatalf("unrelated write rejected: %v", err)
+	}
+}
+
+func TestWriteRejectsRegressions(t *testing.T) {
+	m, eng, allow := newStore(t)
+	addCase(t, m, "bob may log in", request("bob", "login"), "allow")
+
+	_, err := Gate{Engine: eng}.Write(m, nil, func(s store.PolicyStore) ([]model.Policy, error) {
+		p := *allow
+		p.Enabled = false
+		return []model.Policy{p}, s.UpdatePolicy(&p)
+	})
+	var regression *RegressionError
+	if !errors.As(err, &regression) || len(regression.Broken) != 1 || regression.Broken[0].Name != "bob may log in" {
+		t.Fatalf("want the case reported broken, got %v", err)
+	}
+	if p, _ := m.GetPolicy(allow.ID); !p.Enabled {
+		t.Fatal("rejected write was kept")
+	}
+}
+
+func TestWriteConflictModes(t *testing.T) {
+	tests := []struct {
+		mode      string
+		conflicts int
+		rejected  bool
+	}{
+		{"", 1, false},
+		{ConflictsWarn, 1, false},
+		{ConflictsStrict, 1, true},
+		{ConflictsOff, 0, false},
+	}
+	for _, tt := range tests {
+		m, eng, _ := newStore(t)
+		deny := model.Policy{Name: "no prod", Provider: "ssh", Effect: "deny", Expr: "true", Resource: "ssh:unix:prod*", Actions: []string{"login"}}
+		conflicts, err := Gate{Engine: eng, ConflictMode: tt.mode}.Write(m, nil, func(s store.PolicyStore) ([]model.Policy, error) {
+			return []model.Policy{deny}, s.CreatePolicy(&deny)
+		})
+		var conflict *ConflictError
+		if tt.rejected {
+			if !errors.As(err, &conflict) || len(conflict.Conflicts) != tt.conflicts {
+				t.Errorf("mode %q: want a conflict error, got %v", tt.mode, err)
+			}
+		} else if err != nil || len(conflicts) != tt.conflicts {
+			t.Errorf("mode %q: got %d conflicts, %v; want %d", tt.mode, len(conflicts), err, tt.conflicts)
+		}
+		if _, err := m.GetPolicy(deny.ID); (err == nil) == tt.rejected {
+			t.Errorf("mode %q: write kept %v, want %v", tt.mode, err == nil, !tt.rejected)
+		}
+	}
+}
+
+func TestWriteSkipsCasesOfDeletedPolicies(t *testing.T) {
+	for _, skip := range []bool{false, true} {
+		m, eng, allow := newStore(t)
+		b, err := json.Marshal(request("bob", "login"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		m.AddTestCase(model.PolicyTestCase{Name: "bob may log in", PolicyID: &allow.ID, Request: b, ExpectDecision: "allow", Enabled: true})
+
+		var ids []uuid.UUID
+		if skip {
+			ids = []uuid.UUID{allow.ID}
+		}
+		_, err = Gate{Engine: eng}.Write(m, ids, func(s store.PolicyStore) ([]model.Policy, error) {
+			return nil, s.DeletePolicy(allow.ID)
+		})
+		var regression *RegressionError
+		if skip && err != nil || !skip && !errors.As(err, &regression) {
+			t.Errorf("skip %v: got %v", skip, err)
+		}
+	}
+}
+
+func TestWriteWithoutEngine(t *testing.T) {
+	m, _, allow := newStore(t)
+	addCase(t, m, "bob may log in", request("bob", "login"), "allow")
+	_, err := Gate{}.Write(m, nil, func(s store.PolicyStore) ([]model.Policy, error) {
+		p := *allow
+		p.Enabled = false
+		return []model.Policy{p}, s.UpdatePolicy(&p)
+	})
+	if err != nil {
+		t.Fatalf("write without an engine was checked against the cases: %v", err)
+	}
+}
+
+func TestWriteReturnsWriteErrors(t *testing.T) {
+	m, eng, allow := newStore(t)
+	failed := errors.New("failed")
+	_, err := Gate{Engine: eng}.Write(m, nil, func(s store.PolicyStore) ([]model.Policy, error) {
+		if err := s.DeletePolicy(allow.ID); err != nil {
+			return nil, err
+		}
+		return nil, failed
+	})
+	if err != failed {
+		t.Fatalf("got %v, want the write's error", err)
+	}
+	if _, err := m.GetPolicy(allow.ID); err != nil {
+		t.Fatal("failed write was kept")
+	}
+}
@@ -0,0 +1,181 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+
+	"example.com/jit-engine/internal/eval"
+	"example.com/jit-engine/internal/model"
+	"example.com/jit-engine/internal/policy"
+	"example.com/jit-engine/internal/store"
+)
+
+// ActionHandler manages action groups and action hierarchies.
+type ActionHandler struct {
+	Actions store.ActionStore
+	Store   store.PolicyStore
+	Engine  *eval.EvalEngine
+}
+
+// PutGroup creates or replaces an action group.
+func (h *ActionHandler) PutGroup(w http.ResponseWriter, r *http.Request) {
+	var g model.ActionGroup
+	if err := json.NewDecoder(r.Body).Decode(&g); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if g.Name == "" || strings.HasPrefix(g.Name, policy.GroupPrefix) || len(g.Actions) == 0 {
+		http.Error(w, "name and actions are required", http.StatusBadRequest)
+		return
+	}
+	if err := policy.ValidateActions(g.Actions); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(policy.GroupRefs(g.Actions)) > 0 {
+		http.Error(w, "action groups cannot reference other groups", http.StatusBadRequest)
+		return
+	}
+	if err := h.Actions.SaveActionGroup(&g); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.reload()
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(g)
+}
+
+func (h *ActionHandler) ListGroups(w http.ResponseWriter, r *http.Request) {
+	gs, err := h.Actions.ActionGroups()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(gs)
+}
+
+// DeleteGroup refuses to delete a group that policies still reference.
+func (h *ActionHandler) DeleteGroup(w http.ResponseWriter, r *http.Request) {
+	name, ok := tailID(r.URL.Path, "/action-groups/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	switch err := h.Actions.DeleteActionGroup(name); err {
+	case nil:
+	case store.ErrInUse:
+		http.Error(w, "action group is referenced by policies", http.StatusConflict)
+		return
+	case store.ErrNotFound:
+		http.NotFound(w, r)
+		return
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.reload()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PutHierarchy declares, or replaces, what an action implies for a provider.
+func (h *ActionHandler) PutHierarchy(w http.ResponseWriter, r *http.Request) {
+	var in model.ActionHierarchy
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if in.Provider == "" || in.Action == "" || len(in.Implies) == 0 {
+		http.Error(w, "provider, action and implies are required", http.StatusBadRequest)
+		return
+	}
+	for _, a := range in.Implies {
+		if a == "" || a == in.Action || strings.HasPrefix(a, policy.GroupPrefix) || strings.ContainsAny(a, "*?[]{}") {
+			http.Error(w, "implies must list other concrete actions", http.StatusBadRequest)
+			return
+		}
+	}
+	if err := h.Actions.SaveActionHierarchy(&in); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.reload()
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(in)
+}
+
+func (h *ActionHandler) ListHierarchies(w http.ResponseWriter, r *http.Request) {
+	hs, err := h.Actions.ActionHierarchies()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if v := r.URL.Query().Get("provider"); v != "" {
+		hs = slices.DeleteFunc(hs, func(x model.ActionHierarchy) bool { return x.Provider != v })
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(hs)
+}
+
+func (h *ActionHandler) DeleteHierarchy(w http.ResponseWriter, r *http.Request) {
+	id, ok := tailUUID(r.URL.Path, "/action-hierarchies/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if err := h.Actions.DeleteActionHierarchy(id); err != nil {
+		if err == store.ErrNotFound {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.reload()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type expandedActions struct {
+	Entries  []string          `json:"entries"`
+	Any      bool              `json:"any"`
+	Actions  []string          `json:"actions"`
+	Via      map[string]string `json:"via"`
+	Patterns []string          `json:"patterns,omitempty"`
+}
+
+// PolicyActions enumerates what a policy's action entries expand to.
+func (h *ActionHandler) PolicyActions(w http.ResponseWriter, r *http.Request) {
+	id, sub, ok := policySubpath(r.URL.Path)
+	if !ok || sub != "actions" {
+		http.NotFound(w, r)
+		return
+	}
+	p, err := h.Store.GetPolicy(id)
+	if err != nil {
+		if err == store.ErrNotFound {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	set := h.Engine.ExpandActions(p.Provider, p.Actions)
+	out := expandedActions{Entries: p.Actions, Any: set.Any, Actions: set.Actions(), Via: set.Exact}
+	for _, g := range set.Globs {
+		out.Patterns = append(out.Patterns, g.Pattern)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+func (h *ActionHandler) reload() {
+	if h.Engine == nil {
+		return
+	}
+	if _, err := h.Engine.Reload(); err != nil {
+		log.Printf("policy snapshot reload after action catalog change: %v", err)
+	}
+}
@@ -0,0 +1,56 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"example.com/jit-engine/internal/store"
+)
+
+type AuditHandler struct {
+	Audits store.AuditStore
+}
+
+// List returns recorded decisions, newest first. Optional query: subject,
+// action, decision, provider, since/until (RFC3339) and limit (default 100,
+// at most 1000).
+func (h *AuditHandler) List(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := store.AuditFilter{
+		Subject:  q.Get("subject"),
+		Action:   q.Get("action"),
+		Decision: q.Get("decision"),
+		Provider: q.Get("provider"),
+		Limit:    100,
+	}
+	for _, bound := range []struct {
+		param string
+		t     *time.Time
+	}{{"since", &f.Since}, {"until", &f.Until}} {
+		if v := q.Get(bound.param); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				http.Error(w, "invalid "+bound.param, http.StatusBadRequest)
+				return
+			}
+			*bound.t = t
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		f.Limit = min(n, 1000)
+	}
+	audits, err := h.Audits.ListAudits(f)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(audits)
+}
@@ -0,0 +1,43 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"example.com/jit-engine/internal/store"
+)
+
+type BreakGlassHandler struct {
+	Audits store.AuditStore
+}
+
+// List returns every break-glass request, granted or not, for post-incident review.
+// Optional query: since/until (RFC3339) and decision.
+func (h *BreakGlassHandler) List(w http.ResponseWriter, r *http.Request) {
+	f := store.AuditFilter{BreakGlass: true}
+	if v := r.URL.Query().Get("since"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, "invalid since", http.StatusBadRequest)
+			return
+		}
+		f.Since = t
+	}
+	if v := r.URL.Query().Get("until"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, "invalid until", http.StatusBadRequest)
+			return
+		}
+		f.Until = t
+	}
+	f.Decision = r.URL.Query().Get("decision")
+	audits, err := h.Audits.ListAudits(f)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(audits)
+}
@@ -0,0 +1,45 @@
+package httpapi
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"net/http"
+
+	"example.com/jit-engine/internal/bundle"
+	"example.com/jit-engine/internal/store"
+)
+
+// BundleHandler serves the current policies as a signed bundle for embedded
+// engines to sync from.
+type BundleHandler struct {
+	Store store.PolicyStore
+	Key   ed25519.PrivateKey
+}
+
+// Get writes the bundle. Its ETag is the contents digest, so clients polling
+// with If-None-Match get a 304 until something changes.
+func (h *BundleHandler) Get(w http.ResponseWriter, r *http.Request) {
+	c, err := bundle.Export(h.Store)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	digest, err := c.Digest()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	etag := `"` + digest + `"`
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	var buf bytes.Buffer
+	if _, err := bundle.Write(&buf, c, digest[:12], h.Key); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("ETag", etag)
+	_, _ = w.Write(buf.Bytes())
+}
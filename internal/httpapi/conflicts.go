@@ -0,0 +1,47 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"example.com/jit-engine/internal/lint"
+	"example.com/jit-engine/internal/model"
+	"example.com/jit-engine/internal/store"
+)
+
+// policyResponse is a written policy with the conflicts it has.
+type policyResponse struct {
+	model.Policy
+	Warnings []lint.Conflict `json:"warnings,omitempty"`
+}
+
+// ConflictHandler lists conflicting policies.
+type ConflictHandler struct {
+	Store store.PolicyStore
+}
+
+// List returns every allow/deny pair that can apply to the same request,
+// optionally only for one provider.
+func (h *ConflictHandler) List(w http.ResponseWriter, r *http.Request) {
+	f := store.PolicyFilter{}
+	if v := r.URL.Query().Get("provider"); v != "" {
+		prov, err := h.Store.ResolveProvider(v)
+		if err != nil {
+			http.Error(w, "invalid provider", http.StatusBadRequest)
+			return
+		}
+		f.Provider = prov.Name
+	}
+	ps, err := h.Store.ListPolicies(f)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	l, err := lint.New(h.Store, nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(l.AllConflicts(ps))
+}
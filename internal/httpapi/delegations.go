@@ -0,0 +1,199 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"example.com/jit-engine/internal/eval"
+	"example.com/jit-engine/internal/lint"
+	"example.com/jit-engine/internal/model"
+	"example.com/jit-engine/internal/policy"
+	"example.com/jit-engine/internal/session"
+	"example.com/jit-engine/internal/store"
+	"github.com/google/uuid"
+)
+
+type DelegationHandler struct {
+	Store store.DelegationStore
+	// Policies are read to check that the delegator's allows cover a
+	// delegated resource pattern.
+	Policies store.PolicyStore
+	Engine   *eval.EvalEngine
+	Audits   store.AuditStore
+	Sessions *session.Registry
+}
+
+// Create stores a delegation. The delegator's attributes are not taken from
+// the request: they are those of the delegator's latest audited request, as
+// sent by the enforcement point, and the delegator must hold every delegated
+// action on the whole resource pattern with them.
+func (h *DelegationHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var d model.Delegation
+	if err := json.NewDecoder(r.Body).Decode(&d); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	switch {
+	case d.Delegator == "" || d.Delegate == "":
+		http.Error(w, "delegator and delegate are required", http.StatusBadRequest)
+		return
+	case d.Delegator == d.Delegate:
+		http.Error(w, "cannot delegate to self", http.StatusBadRequest)
+		return
+	case len(d.Actions) == 0:
+		http.Error(w, "actions must not be empty", http.StatusBadRequest)
+		return
+	case !d.ExpiresAt.After(time.Now()):
+		http.Error(w, "expires_at must be in the future", http.StatusBadRequest)
+		return
+	}
+	if d.Resource == "" {
+		d.Resource = "*"
+	}
+	if err := policy.ValidateResource(policy.MatchGlob, d.Resource, nil); err != nil {
+		http.Error(w, "invalid resource pattern: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	last, err := h.lastRequest(d.Delegator)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if last == nil {
+		http.Error(w, "delegator has no audited requests, so their attributes are unknown", http.StatusForbidden)
+		return
+	}
+	for _, action := range d.Actions {
+		reason, err := h.holds(*last, action, d.Resource)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if reason != "" {
+			http.Error(w, fmt.Sprintf("delegator is not allowed %s on %s: %s", action, d.Resource, reason), http.StatusForbidden)
+			return
+		}
+	}
+	if d.DelegatorSubject, err = json.Marshal(last.Subject); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	d.ID = uuid.Nil
+	d.RevokedAt = nil
+	if err := h.Store.CreateDelegation(&d); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if h.Engine != nil {
+		h.Engine.InvalidateDecisions()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(d)
+}
+
+// holds checks that the delegator, as req, is allowed action on every
+// resource pattern matches and returns why not. A concrete resource is
+// evaluated as it is. A pattern is evaluated at its literal prefix, and one
+// of the allows that matched must cover the whole pattern; denies within it
+// are applied when the delegation is used.
+func (h *DelegationHandler) holds(req eval.Request, action, pattern string) (string, error) {
+	req.Action, req.BreakGlass = action, false
+	scope := model.Policy{MatchKind: policy.MatchExact, Resource: pattern}
+	if policy.IsGlob(pattern) {
+		m, err := policy.CompileMatcher(policy.MatchGlob, pattern, nil)
+		if err != nil {
+			return err.Error(), nil
+		}
+		scope.MatchKind = policy.MatchGlob
+		req.Resource = m.LiteralPrefix()
+	} else {
+		req.Resource = pattern
+	}
+	res, err := h.Engine.Evaluate(req)
+	if err != nil || res.Decision != "allow" {
+		return res.Reason, nil
+	}
+	if scope.MatchKind == policy.MatchExact {
+		return "", nil
+	}
+	for _, t := range res.Trace {
+		if t.Effect != "allow" || t.Result == nil || !*t.Result || t.PolicyID == uuid.Nil {
+			continue
+		}
+		p, err := h.Policies.GetPolicy(t.PolicyID)
+		if errors.Is(err, store.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if lint.ResourceCovers(&p, &scope) {
+			return "", nil
+		}
+	}
+	return "no allow policy covers the whole resource pattern", nil
+}
+
+// lastRequest returns the delegator's latest audited request, or nil when
+// there is none. Its subject attributes and context stand for the delegator.
+func (h *DelegationHandler) lastRequest(delegator string) (*eval.Request, error) {
+	audits, err := h.Audits.ListAudits(store.AuditFilter{Subject: delegator, Limit: 1})
+	if err != nil || len(audits) == 0 {
+		return nil, err
+	}
+	var req eval.Request
+	if err := json.Unmarshal(audits[0].Request, &req); err != nil {
+		return nil, err
+	}
+	if req.Subject == nil {
+		req.Subject = map[string]any{}
+	}
+	req.Subject["id"] = delegator
+	return &req, nil
+}
+
+func (h *DelegationHandler) List(w http.ResponseWriter, r *http.Request) {
+	f := store.DelegationFilter{Delegator: r.URL.Query().Get("delegator"), Delegate: r.URL.Query().Get("delegate")}
+	if r.URL.Query().Get("active") == "true" {
+		f.ActiveAt = time.Now()
+	}
+	ds, err := h.Store.Delegations(f)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(ds)
+}
+
+// Revoke ends a delegation immediately. Delegations the delegate passed on
+// stop working too, because each hop re-checks its delegator at evaluation time.
+func (h *DelegationHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	id, ok := tailUUID(r.URL.Path, "/delegations/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	revoked, err := h.Store.RevokeDelegation(id, time.Now())
+	if err == store.ErrNotFound {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if revoked {
+		if h.Engine != nil {
+			h.Engine.InvalidateDecisions()
+		}
+		if h.Sessions != nil {
+			h.Sessions.Trigger()
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
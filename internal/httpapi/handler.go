@@ -15,12 +15,38 @@ func (h *EvalHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "bad request", http.StatusBadRequest)
 		return
 	}
-	decision, matched, reason, trace, _ := h.Engine.EvaluateAndAudit(req)
+	res, _ := h.Engine.EvaluateAndAudit(req)
 	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(map[string]any{
-		"decision": decision,
-		"matched":  matched,
-		"reason":   reason,
-		"trace":    trace,
-	})
+	_ = json.NewEncoder(w).Encode(res)
+}
+
+type CacheStatsHandler struct{ Engine *eval.EvalEngine }
+
+func (h *CacheStatsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(h.Engine.DecisionCacheStats())
+}
+
+type SnapshotHandler struct{ Engine *eval.EvalEngine }
+
+// Info reports the policy snapshot currently used for evaluation.
+func (h *SnapshotHandler) Info(w http.ResponseWriter, r *http.Request) {
+	info, ok := h.Engine.SnapshotInfo()
+	if !ok {
+		http.Error(w, "policy snapshot not loaded", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(info)
+}
+
+// Reload rebuilds the snapshot from the database immediately.
+func (h *SnapshotHandler) Reload(w http.ResponseWriter, r *http.Request) {
+	info, err := h.Engine.Reload()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(info)
 }
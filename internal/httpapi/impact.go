@@ -0,0 +1,107 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"example.com/jit-engine/internal/impact"
+	"example.com/jit-engine/internal/policyfile"
+	"github.com/google/uuid"
+)
+
+// ImpactHandler runs impact analysis jobs.
+type ImpactHandler struct {
+	Jobs *impact.Manager
+}
+
+// Create starts replaying recorded requests against the posted policy file
+// applied to the current policies. Optional query: days (default 7), limit
+// (default 10000) and samples (default 20). An invalid file is a 422
+// carrying the plan; otherwise the job is returned with 202.
+func (h *ImpactHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var opts impact.Options
+	q := r.URL.Query()
+	for _, p := range []struct {
+		name string
+		set  func(int)
+	}{
+		{"days", func(n int) { opts.Window = time.Duration(n) * 24 * time.Hour }},
+		{"limit", func(n int) { opts.Limit = n }},
+		{"samples", func(n int) { opts.Samples = n }},
+	} {
+		if v := q.Get(p.name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				http.Error(w, "invalid "+p.name, http.StatusBadRequest)
+				return
+			}
+			p.set(n)
+		}
+	}
+	f, ok := readPolicyFile(w, r)
+	if !ok {
+		return
+	}
+	job, plan, err := h.Jobs.Start(f, opts)
+	if err == policyfile.ErrInvalid {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		_ = json.NewEncoder(w).Encode(plan)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/impact/"+job.ID.String())
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(job)
+}
+
+// List returns the jobs, newest first, without their results.
+func (h *ImpactHandler) List(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(h.Jobs.List())
+}
+
+// Get returns a job with its progress and, once done, its result.
+func (h *ImpactHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id, ok := impactID(r.URL.Path)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	job, err := h.Jobs.Get(id)
+	if err == impact.ErrNotFound {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(job)
+}
+
+// Cancel stops a running job.
+func (h *ImpactHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+	id, ok := impactID(r.URL.Path)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if err := h.Jobs.Cancel(id); err == impact.ErrNotFound {
+		http.NotFound(w, r)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func impactID(path string) (uuid.UUID, bool) {
+	s, ok := tailID(path, "/impact/")
+	if !ok {
+		return uuid.Nil, false
+	}
+	id, err := uuid.Parse(s)
+	return id, err == nil
+}
@@ -0,0 +1,114 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"example.com/jit-engine/internal/lint"
+	"example.com/jit-engine/internal/model"
+	"example.com/jit-engine/internal/store"
+)
+
+// LintHandler reports likely mistakes in policies.
+type LintHandler struct {
+	Store store.PolicyStore
+	// SubjectFields are subject attributes declared for every provider.
+	SubjectFields []string
+}
+
+// Stored lints the stored policies, optionally only those of provider.
+func (h *LintHandler) Stored(w http.ResponseWriter, r *http.Request) {
+	min, ok := lintSeverity(w, r)
+	if !ok {
+		return
+	}
+	ps, err := h.Store.ListPolicies(store.PolicyFilter{})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var only map[string]bool
+	if v := r.URL.Query().Get("provider"); v != "" {
+		prov, err := h.Store.ResolveProvider(v)
+		if err != nil {
+			http.Error(w, "invalid provider", http.StatusBadRequest)
+			return
+		}
+		only = map[string]bool{prov.Name: true}
+	}
+	h.write(w, ps, only, min)
+}
+
+// File lints the posted policy file as it would stand once applied: its
+// policies replace the stored ones of the providers it lists. Only findings
+// about those providers are reported.
+func (h *LintHandler) File(w http.ResponseWriter, r *http.Request) {
+	min, ok := lintSeverity(w, r)
+	if !ok {
+		return
+	}
+	f, ok := readPolicyFile(w, r)
+	if !ok {
+		return
+	}
+	if errs := f.Validate(); len(errs) > 0 {
+		http.Error(w, "invalid policy file:\n"+strings.Join(errs, "\n"), http.StatusBadRequest)
+		return
+	}
+	desired := f.ToPolicies()
+	managed := map[string]bool{}
+	for i := range desired {
+		prov, err := h.Store.ResolveProvider(desired[i].Provider)
+		if err != nil {
+			http.Error(w, desired[i].Provider+": unknown provider", http.StatusBadRequest)
+			return
+		}
+		desired[i].Provider = prov.Name
+		managed[prov.Name] = true
+	}
+	stored, err := h.Store.ListPolicies(store.PolicyFilter{})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for _, p := range stored {
+		if !managed[p.Provider] {
+			desired = append(desired, p)
+		}
+	}
+	h.write(w, desired, managed, min)
+}
+
+func (h *LintHandler) write(w http.ResponseWriter, ps []model.Policy, only map[string]bool, min string) {
+	l, err := lint.New(h.Store, h.SubjectFields)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	findings := lint.AtLeast(l.Lint(ps), min)
+	if only != nil {
+		kept := findings[:0]
+		for _, f := range findings {
+			if provider, _, _ := strings.Cut(f.Policy, "/"); only[provider] {
+				kept = append(kept, f)
+			}
+		}
+		findings = kept
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(findings)
+}
+
+// lintSeverity reads the minimum severity to report, info by default.
+func lintSeverity(w http.ResponseWriter, r *http.Request) (string, bool) {
+	v := r.URL.Query().Get("severity")
+	if v == "" {
+		return lint.SeverityInfo, true
+	}
+	if !lint.ValidSeverity(v) {
+		http.Error(w, "severity must be error, warning or info", http.StatusBadRequest)
+		return "", false
+	}
+	return v, true
+}
@@ -4,17 +4,27 @@ import (
 	"encoding/json"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"example.com/jit-engine/internal/eval"
 	"example.com/jit-engine/internal/model"
+	"example.com/jit-engine/internal/policy"
+	"example.com/jit-engine/internal/session"
+	"example.com/jit-engine/internal/store"
 	"github.com/google/uuid"
-	"gorm.io/gorm"
 )
 
 type PolicyHandler struct {
-	DB     *gorm.DB
-	Engine *eval.EvalEngine
+	Store    store.PolicyStore
+	Engine   *eval.EvalEngine
+	Sessions *session.Registry
+	// ConflictMode is gate.ConflictsWarn (the default), ConflictsStrict or
+	// ConflictsOff.
+	ConflictMode string
+	// RequireIfMatch rejects updates, deletes and rollbacks of a policy
+	// that do not name the version they are based on.
+	RequireIfMatch bool
 }
 
 func (h *PolicyHandler) Create(w http.ResponseWriter, r *http.Request) {
@@ -23,48 +33,60 @@ func (h *PolicyHandler) Create(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "bad request", http.StatusBadRequest)
 		return
 	}
-	// Check for provider query parameter and set provider field
+	// Resolve the provider query parameter against the provider registry
 	if provider := r.URL.Query().Get("provider"); provider != "" {
-		switch provider {
-		case "aws", "gcp", "database", "ssh", "rdp", "global":
-			p.Provider = provider
-		default:
+		prov, err := h.Store.ResolveProvider(provider)
+		if err == store.ErrNotFound {
 			http.Error(w, "invalid provider", http.StatusBadRequest)
 			return
 		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		p.Provider = prov.Name
 	} else {
 		p.Provider = "global"
 	}
 	p.ID = uuid.Nil
-	if err := h.DB.Create(&p).Error; err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	change := changeFrom(r)
+	created := func(s store.PolicyStore) ([]model.Policy, error) {
+		if err := s.WithChange(change).CreatePolicy(&p); err != nil {
+			return nil, err
+		}
+		return []model.Policy{p}, nil
+	}
+	conflicts, ok := gatedWrite(w, h.Store, h.Engine, h.ConflictMode, nil, created)
+	if !ok {
 		return
 	}
 	if h.Engine != nil {
-		h.Engine.Invalidate(p.ID)
+		h.Engine.PolicyChanged(p.ID, p.Provider)
+	}
+	if h.Sessions != nil {
+		h.Sessions.Trigger()
 	}
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", policyETag(p))
 	w.WriteHeader(http.StatusCreated)
-	_ = json.NewEncoder(w).Encode(p)
+	_ = json.NewEncoder(w).Encode(policyResponse{Policy: p, Warnings: conflicts})
 }
 
 func (h *PolicyHandler) List(w http.ResponseWriter, r *http.Request) {
-	var ps []model.Policy
-	q := h.DB
-	if v := r.URL.Query().Get("name"); v != "" {
-		q = q.Where("name ILIKE ?", "%"+v+"%")
-	}
-	if v := r.URL.Query().Get("effect"); v != "" {
-		q = q.Where("effect = ?", v)
-	}
-	if v := r.URL.Query().Get("enabled"); v != "" {
-		if v == "true" {
-			q = q.Where("enabled = ?", true)
-		} else if v == "false" {
-			q = q.Where("enabled = ?", false)
+	q := r.URL.Query()
+	f := store.PolicyFilter{Name: q.Get("name"), Effect: q.Get("effect"), Provider: q.Get("provider")}
+	if f.Provider != "" {
+		// Accept aliases as the write endpoints do
+		if prov, err := h.Store.ResolveProvider(f.Provider); err == nil {
+			f.Provider = prov.Name
 		}
 	}
-	if err := q.Order("priority asc, created_at asc").Find(&ps).Error; err != nil {
+	if v := q.Get("enabled"); v == "true" || v == "false" {
+		enabled := v == "true"
+		f.Enabled = &enabled
+	}
+	ps, err := h.Store.ListPolicies(f)
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -73,14 +95,14 @@ func (h *PolicyHandler) List(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *PolicyHandler) Get(w http.ResponseWriter, r *http.Request) {
-	id, ok := tailID(r.URL.Path, "/policies/")
+	id, ok := policyID(r.URL.Path)
 	if !ok {
 		http.NotFound(w, r)
 		return
 	}
-	var p model.Policy
-	if err := h.DB.First(&p, "id = ?", id).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
+	p, err := h.Store.GetPolicy(id)
+	if err != nil {
+		if err == store.ErrNotFound {
 			http.NotFound(w, r)
 			return
 		}
@@ -88,84 +110,177 @@ func (h *PolicyHandler) Get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", policyETag(p))
 	_ = json.NewEncoder(w).Encode(p)
 }
 
 func (h *PolicyHandler) Update(w http.ResponseWriter, r *http.Request) {
-	id, ok := tailID(r.URL.Path, "/policies/")
+	id, ok := policyID(r.URL.Path)
 	if !ok {
 		http.NotFound(w, r)
 		return
 	}
-	var existing model.Policy
-	if err := h.DB.First(&existing, "id = ?", id).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
+	existing, err := h.Store.GetPolicy(id)
+	if err != nil {
+		if err == store.ErrNotFound {
 			http.NotFound(w, r)
 			return
 		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	ifVersion, ok := h.ifMatch(w, r, existing)
+	if !ok {
+		return
+	}
 	body, _ := io.ReadAll(r.Body)
 	var in model.Policy
 	if err := json.Unmarshal(body, &in); err != nil {
 		http.Error(w, "bad request", http.StatusBadRequest)
 		return
 	}
-	// Check for provider query parameter and set provider field
+	// Resolve the provider query parameter against the provider registry
 	if provider := r.URL.Query().Get("provider"); provider != "" {
-		switch provider {
-		case "aws", "gcp", "database", "ssh", "rdp", "global":
-			in.Provider = provider
-		default:
+		prov, err := h.Store.ResolveProvider(provider)
+		if err == store.ErrNotFound {
 			http.Error(w, "invalid provider", http.StatusBadRequest)
 			return
 		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		in.Provider = prov.Name
+	}
+	if in.Provider == "" {
+		in.Provider = existing.Provider
+	}
+	if in.MatchKind == "" {
+		in.MatchKind = policy.MatchGlob
 	}
 	in.ID = existing.ID
 	// Preserve CreatedAt
 	in.CreatedAt = existing.CreatedAt
-	if err := h.DB.Model(&existing).Select("name", "effect", "provider", "resource", "actions", "expr", "metadata", "enabled", "priority", "version").Updates(in).Error; err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
+	// The store refetches the updated policy to get the latest values and
+	// assigns the version
+	change := changeFrom(r)
+	change.IfVersion = ifVersion
+	updated := func(s store.PolicyStore) ([]model.Policy, error) {
+		if err := s.WithChange(change).UpdatePolicy(&in); err != nil {
+			return nil, err
+		}
+		return []model.Policy{in}, nil
 	}
-	// Refetch the updated policy to get the latest values
-	if err := h.DB.First(&existing, "id = ?", existing.ID).Error; err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	conflicts, ok := gatedWrite(w, h.Store, h.Engine, h.ConflictMode, nil, updated)
+	if !ok {
 		return
 	}
 	if h.Engine != nil {
-		h.Engine.Invalidate(existing.ID)
+		h.Engine.PolicyChanged(in.ID, existing.Provider, in.Provider)
+	}
+	if h.Sessions != nil {
+		h.Sessions.Trigger()
 	}
 	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(existing)
+	w.Header().Set("ETag", policyETag(in))
+	_ = json.NewEncoder(w).Encode(policyResponse{Policy: in, Warnings: conflicts})
 }
 
 func (h *PolicyHandler) Delete(w http.ResponseWriter, r *http.Request) {
-	id, ok := tailID(r.URL.Path, "/policies/")
+	id, ok := policyID(r.URL.Path)
 	if !ok {
 		http.NotFound(w, r)
 		return
 	}
-	var p model.Policy
-	if err := h.DB.First(&p, "id = ?", id).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
+	p, err := h.Store.GetPolicy(id)
+	if err != nil {
+		if err == store.ErrNotFound {
 			http.NotFound(w, r)
 			return
 		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	if err := h.DB.Delete(&p).Error; err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	ifVersion, ok := h.ifMatch(w, r, p)
+	if !ok {
+		return
+	}
+	// Test cases attached to the policy go with it and are not run
+	change := changeFrom(r)
+	change.IfVersion = ifVersion
+	deleted := func(s store.PolicyStore) ([]model.Policy, error) {
+		return nil, s.WithChange(change).DeletePolicy(p.ID)
+	}
+	if _, ok := gatedWrite(w, h.Store, h.Engine, h.ConflictMode, []uuid.UUID{p.ID}, deleted); !ok {
 		return
 	}
 	if h.Engine != nil {
-		h.Engine.Invalidate(p.ID)
+		h.Engine.PolicyChanged(p.ID, p.Provider)
+	}
+	if h.Sessions != nil {
+		h.Sessions.Trigger()
 	}
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// policyETag is the entity tag of a policy: its version.
+func policyETag(p model.Policy) string {
+	return `"` + strconv.Itoa(p.Version) + `"`
+}
+
+// ifMatch checks the If-Match header of a write against current and returns
+// the version the store must still find, 0 for any. A missing header is a
+// 428 when RequireIfMatch is set; a stale one is a 412 carrying the current
+// ETag.
+func (h *PolicyHandler) ifMatch(w http.ResponseWriter, r *http.Request, current model.Policy) (int, bool) {
+	matched, ok := matchETag(w, r, policyETag(current), h.RequireIfMatch, "the policy's", "policy was modified; fetch it and retry")
+	if matched {
+		return current.Version, true
+	}
+	return 0, ok
+}
+
+// matchETag checks the If-Match header against etag and reports whether it
+// named etag rather than "*". A missing header is a 428 when required and a
+// stale one a 412 with stale as the message, carrying etag.
+func matchETag(w http.ResponseWriter, r *http.Request, etag string, required bool, whose, stale string) (matched, ok bool) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	switch header {
+	case "":
+		if required {
+			http.Error(w, "If-Match with "+whose+" ETag is required", http.StatusPreconditionRequired)
+			return false, false
+		}
+		return false, true
+	case "*":
+		return false, true
+	}
+	for _, tag := range strings.Split(header, ",") {
+		// Weak tags never match: If-Match uses the strong comparison
+		if strings.TrimSpace(tag) == etag {
+			return true, true
+		}
+	}
+	w.Header().Set("ETag", etag)
+	http.Error(w, stale, http.StatusPreconditionFailed)
+	return false, false
+}
+
+// policyID parses the policy ID from a /policies/{id} path.
+func policyID(path string) (uuid.UUID, bool) {
+	return tailUUID(path, "/policies/")
+}
+
+// tailUUID parses the UUID following prefix in path.
+func tailUUID(path, prefix string) (uuid.UUID, bool) {
+	s, ok := tailID(path, prefix)
+	if !ok {
+		return uuid.Nil, false
+	}
+	id, err := uuid.Parse(s)
+	return id, err == nil
+}
+
 func tailID(path, prefix string) (string, bool) {
 	if !strings.HasPrefix(path, prefix) {
 		return "", false
@@ -177,4 +292,3 @@ func tailID(path, prefix string) (string, bool) {
 	}
 	return id, true
 }
-
@@ -0,0 +1,129 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"example.com/jit-engine/internal/eval"
+	"example.com/jit-engine/internal/lint"
+	"example.com/jit-engine/internal/model"
+	"example.com/jit-engine/internal/policyfile"
+	"example.com/jit-engine/internal/session"
+	"example.com/jit-engine/internal/store"
+	"github.com/google/uuid"
+)
+
+// PolicyFileHandler plans and applies declarative policy files.
+type PolicyFileHandler struct {
+	Store    store.PolicyStore
+	Engine   *eval.EvalEngine
+	Sessions *session.Registry
+	// ConflictMode and RequireIfMatch apply as for PolicyHandler, with the
+	// plan's ETag in If-Match.
+	ConflictMode   string
+	RequireIfMatch bool
+}
+
+// planResponse is an applied plan with the conflicts of the written policies.
+type planResponse struct {
+	*policyfile.Plan
+	Warnings []lint.Conflict `json:"warnings,omitempty"`
+}
+
+// Plan returns the changes applying the posted file would make, with the
+// plan's tag as its ETag.
+func (h *PolicyFileHandler) Plan(w http.ResponseWriter, r *http.Request) {
+	f, ok := readPolicyFile(w, r)
+	if !ok {
+		return
+	}
+	plan, err := policyfile.NewPlan(f, h.Store)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", planETag(plan))
+	_ = json.NewEncoder(w).Encode(plan)
+}
+
+// Apply applies the posted file in one transaction, gated like single policy
+// writes. An invalid file is a 422 carrying the plan and its errors; nothing
+// is written. With If-Match the plan must still be the one the ETag names,
+// and policies changed while applying are a 412.
+func (h *PolicyFileHandler) Apply(w http.ResponseWriter, r *http.Request) {
+	f, ok := readPolicyFile(w, r)
+	if !ok {
+		return
+	}
+	plan, err := policyfile.NewPlan(f, h.Store)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(plan.Errors) > 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		_ = json.NewEncoder(w).Encode(plan)
+		return
+	}
+	if _, ok := matchETag(w, r, planETag(plan), h.RequireIfMatch, "the plan's", "policies changed since the plan; plan again and retry"); !ok {
+		return
+	}
+	// The plan is made again in the transaction; if it differs the gate
+	// would have checked the wrong deletions.
+	tag, change := plan.Tag, changeFrom(r)
+	written := func(s store.PolicyStore) ([]model.Policy, error) {
+		var err error
+		if plan, err = policyfile.NewPlan(f, s); err != nil {
+			return nil, err
+		}
+		if plan.Tag != tag {
+			return nil, store.ErrVersionConflict
+		}
+		return plan.Write(s, change)
+	}
+	conflicts, ok := gatedWrite(w, h.Store, h.Engine, h.ConflictMode, plan.Deleted(), written)
+	if !ok {
+		return
+	}
+	if len(plan.Changes) > 0 {
+		var ids []uuid.UUID
+		var providers []string
+		for _, c := range plan.Changes {
+			ids = append(ids, *c.ID)
+			providers = append(providers, c.Provider)
+			if c.OldProvider != "" {
+				providers = append(providers, c.OldProvider)
+			}
+		}
+		if h.Engine != nil {
+			h.Engine.PoliciesChanged(ids, providers...)
+		}
+		if h.Sessions != nil {
+			h.Sessions.Trigger()
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(planResponse{Plan: plan, Warnings: conflicts})
+}
+
+// planETag is the entity tag of a plan: its tag.
+func planETag(p *policyfile.Plan) string {
+	return `"` + p.Tag + `"`
+}
+
+func readPolicyFile(w http.ResponseWriter, r *http.Request) (*policyfile.File, bool) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return nil, false
+	}
+	f, err := policyfile.Parse(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	return f, true
+}
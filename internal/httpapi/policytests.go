@@ -0,0 +1,162 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"example.com/jit-engine/internal/eval"
+	"example.com/jit-engine/internal/model"
+	"example.com/jit-engine/internal/policytest"
+	"example.com/jit-engine/internal/store"
+	"github.com/google/uuid"
+)
+
+// PolicyTestHandler manages the regression test cases policy writes are
+// checked against.
+type PolicyTestHandler struct {
+	Tests  store.TestCaseStore
+	Store  store.PolicyStore
+	Engine *eval.EvalEngine
+}
+
+func (h *PolicyTestHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var tc model.PolicyTestCase
+	if err := json.NewDecoder(r.Body).Decode(&tc); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if msg := h.validate(&tc); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	tc.ID = uuid.New()
+	if err := h.Tests.CreateTestCase(&tc); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(tc)
+}
+
+// List returns test cases, optionally filtered by policy_id or provider.
+func (h *PolicyTestHandler) List(w http.ResponseWriter, r *http.Request) {
+	var policyID *uuid.UUID
+	if v := r.URL.Query().Get("policy_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			http.Error(w, "invalid policy_id", http.StatusBadRequest)
+			return
+		}
+		policyID = &id
+	}
+	tcs, err := h.Tests.TestCases(policyID, r.URL.Query().Get("provider"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(tcs)
+}
+
+func (h *PolicyTestHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id, ok := tailUUID(r.URL.Path, "/policy-tests/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	var in model.PolicyTestCase
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if msg := h.validate(&in); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	in.ID = id
+	if err := h.Tests.UpdateTestCase(&in); err != nil {
+		if err == store.ErrNotFound {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(in)
+}
+
+func (h *PolicyTestHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, ok := tailUUID(r.URL.Path, "/policy-tests/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if err := h.Tests.DeleteTestCase(id); err != nil {
+		if err == store.ErrNotFound {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Run evaluates every enabled test case against the current policies.
+func (h *PolicyTestHandler) Run(w http.ResponseWriter, r *http.Request) {
+	tcs, err := h.Store.EnabledTestCases()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	suite, err := policytest.StoredSuite(tcs)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rep, err := policytest.Run(&policytest.Local{Engine: h.Engine, Store: h.Store}, []policytest.Suite{suite})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// Every failure counts against an empty baseline.
+	failures := policytest.Regressions(&policytest.Report{}, rep)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"passed":   len(rep.Outcomes) - len(failures),
+		"failed":   len(failures),
+		"failures": failures,
+	})
+}
+
+// validate normalises tc and returns why it cannot be stored, or "".
+func (h *PolicyTestHandler) validate(tc *model.PolicyTestCase) string {
+	if tc.Name == "" {
+		return "name is required"
+	}
+	if tc.PolicyID == nil && tc.Provider == "" {
+		return "policy_id or provider is required"
+	}
+	if tc.ExpectDecision != "allow" && tc.ExpectDecision != "deny" {
+		return "expect_decision must be allow or deny"
+	}
+	var req eval.Request
+	if len(tc.Request) == 0 || json.Unmarshal(tc.Request, &req) != nil {
+		return "request must be an evaluation request"
+	}
+	if tc.PolicyID != nil {
+		if _, err := h.Store.GetPolicy(*tc.PolicyID); err != nil {
+			return "unknown policy_id"
+		}
+	}
+	if tc.Provider != "" {
+		prov, err := h.Store.ResolveProvider(tc.Provider)
+		if err != nil {
+			return "invalid provider"
+		}
+		tc.Provider = prov.Name
+	}
+	return ""
+}
@@ -0,0 +1,187 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+
+	"example.com/jit-engine/internal/eval"
+	"example.com/jit-engine/internal/model"
+	"example.com/jit-engine/internal/policy"
+	"example.com/jit-engine/internal/store"
+)
+
+// ProviderHandler manages the provider registry.
+type ProviderHandler struct {
+	Store  store.ProviderStore
+	Engine *eval.EvalEngine
+}
+
+// reservedProviders are consulted by the engine itself and cannot be removed.
+var reservedProviders = map[string]bool{"global": true, eval.BreakGlassProvider: true}
+
+func (h *ProviderHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var p model.Provider
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if msg := h.validate(&p); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	if err := h.Store.CreateProvider(&p); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.reload()
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(p)
+}
+
+func (h *ProviderHandler) List(w http.ResponseWriter, r *http.Request) {
+	ps, err := h.Store.Providers()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(ps)
+}
+
+func (h *ProviderHandler) Get(w http.ResponseWriter, r *http.Request) {
+	name, ok := tailID(r.URL.Path, "/providers/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	p, err := h.Store.GetProvider(name)
+	if err != nil {
+		if err == store.ErrNotFound {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(p)
+}
+
+// Update replaces a provider's definition; the name is taken from the path.
+func (h *ProviderHandler) Update(w http.ResponseWriter, r *http.Request) {
+	name, ok := tailID(r.URL.Path, "/providers/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	existing, err := h.Store.GetProvider(name)
+	if err != nil {
+		if err == store.ErrNotFound {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var in model.Provider
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	in.Name = existing.Name
+	if msg := h.validate(&in); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	if err := h.Store.UpdateProvider(&in); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.reload()
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(in)
+}
+
+// Delete refuses to remove a reserved provider or one that policies still use.
+func (h *ProviderHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	name, ok := tailID(r.URL.Path, "/providers/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if reservedProviders[name] {
+		http.Error(w, "provider is reserved", http.StatusConflict)
+		return
+	}
+	switch err := h.Store.DeleteProvider(name); err {
+	case nil:
+	case store.ErrInUse:
+		http.Error(w, "provider has policies", http.StatusConflict)
+		return
+	case store.ErrNotFound:
+		http.NotFound(w, r)
+		return
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.reload()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// validate normalises p and returns why it cannot be stored, or "".
+func (h *ProviderHandler) validate(p *model.Provider) string {
+	if p.Name == "" || strings.ContainsAny(p.Name, " /@") {
+		return "name is required and must not contain spaces, '/' or '@'"
+	}
+	switch p.DefaultDecision {
+	case "":
+		p.DefaultDecision = "deny"
+	case "allow", "deny":
+	default:
+		return "default_decision must be allow or deny"
+	}
+	for _, pat := range p.ResourcePatterns {
+		if err := policy.ValidateResource(policy.MatchGlob, pat, nil); err != nil {
+			return err.Error()
+		}
+	}
+	if err := policy.ValidateActions(p.Actions); err != nil {
+		return err.Error()
+	}
+	if len(policy.GroupRefs(p.Actions)) > 0 {
+		return "provider actions cannot reference action groups"
+	}
+	if _, err := policy.ParseSchema(p.RequestSchema); err != nil {
+		return err.Error()
+	}
+	others, err := h.Store.Providers()
+	if err != nil {
+		return err.Error()
+	}
+	for _, a := range p.Aliases {
+		if a == "" || a == p.Name {
+			return "aliases must be non-empty and differ from the name"
+		}
+		for _, o := range others {
+			if o.Name != p.Name && (o.Name == a || slices.Contains(o.Aliases, a)) {
+				return fmt.Sprintf("alias %q is already used by provider %q", a, o.Name)
+			}
+		}
+	}
+	return ""
+}
+
+func (h *ProviderHandler) reload() {
+	if h.Engine == nil {
+		return
+	}
+	if _, err := h.Engine.Reload(); err != nil {
+		log.Printf("policy snapshot reload after provider change: %v", err)
+	}
+}
@@ -0,0 +1,58 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"example.com/jit-engine/internal/eval"
+	"example.com/jit-engine/internal/gate"
+	"example.com/jit-engine/internal/lint"
+	"example.com/jit-engine/internal/model"
+	"example.com/jit-engine/internal/store"
+	"github.com/google/uuid"
+)
+
+// gatedWrite runs write through the gate and reports whether it committed,
+// with the conflicts of the written policies. A write error is a 400, broken
+// cases a 422 listing them, conflicts rejected in strict mode a 409 and a
+// policy modified concurrently a 412.
+func gatedWrite(w http.ResponseWriter, s store.PolicyStore, eng *eval.EvalEngine, conflictMode string, skip []uuid.UUID, write func(store.PolicyStore) ([]model.Policy, error)) ([]lint.Conflict, bool) {
+	conflicts, err := gate.Gate{Engine: eng, ConflictMode: conflictMode}.Write(s, skip, write)
+	var (
+		conflict   *gate.ConflictError
+		regression *gate.RegressionError
+		check      *gate.CheckError
+	)
+	switch {
+	case errors.As(err, &conflict):
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"error":     conflict.Error(),
+			"conflicts": conflict.Conflicts,
+		})
+		return nil, false
+	case errors.As(err, &regression):
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"error":    regression.Error(),
+			"failures": regression.Broken,
+		})
+		return nil, false
+	case errors.As(err, &check):
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	case errors.Is(err, store.ErrNotFound):
+		http.Error(w, "not found", http.StatusNotFound)
+		return nil, false
+	case errors.Is(err, store.ErrVersionConflict):
+		http.Error(w, "policy was modified; fetch it and retry", http.StatusPreconditionFailed)
+		return nil, false
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	return conflicts, true
+}
@@ -0,0 +1,99 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"example.com/jit-engine/internal/coverage"
+	"example.com/jit-engine/internal/store"
+)
+
+// ReportHandler analyses recorded decisions.
+type ReportHandler struct {
+	Audits store.AuditStore
+	Store  store.PolicyStore
+}
+
+// reportPage is how many audits are read at a time.
+const reportPage = 1000
+
+// defaultReportWindow is analysed when since is not given.
+const defaultReportWindow = 30 * 24 * time.Hour
+
+// Coverage reports enabled policies that never matched, allows used by few
+// of the subjects they permit and over-broad resource patterns. Optional
+// query: since/until (RFC3339, default the last 30 days), provider,
+// min_permitted and max_usage.
+func (h *ReportHandler) Coverage(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	until := time.Now().UTC()
+	since := until.Add(-defaultReportWindow)
+	for _, bound := range []struct {
+		param string
+		t     *time.Time
+	}{{"since", &since}, {"until", &until}} {
+		if v := q.Get(bound.param); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				http.Error(w, "invalid "+bound.param, http.StatusBadRequest)
+				return
+			}
+			*bound.t = t
+		}
+	}
+	var opts coverage.Options
+	if v := q.Get("min_permitted"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid min_permitted", http.StatusBadRequest)
+			return
+		}
+		opts.MinPermitted = n
+	}
+	if v := q.Get("max_usage"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f <= 0 || f > 1 {
+			http.Error(w, "invalid max_usage", http.StatusBadRequest)
+			return
+		}
+		opts.MaxUsage = f
+	}
+	enabled := true
+	f := store.PolicyFilter{Enabled: &enabled}
+	if v := q.Get("provider"); v != "" {
+		prov, err := h.Store.ResolveProvider(v)
+		if err != nil {
+			http.Error(w, "invalid provider", http.StatusBadRequest)
+			return
+		}
+		f.Provider = prov.Name
+	}
+	ps, err := h.Store.ListPolicies(f)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	an, err := coverage.NewAnalyzer(ps, opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// The window is fixed, so later audits cannot shift the pages.
+	for af := (store.AuditFilter{Since: since, Until: until, Limit: reportPage}); ; af.Offset += reportPage {
+		page, err := h.Audits.ListAudits(af)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		for i := range page {
+			an.Add(&page[i])
+		}
+		if len(page) < reportPage {
+			break
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(an.Report(since, until))
+}
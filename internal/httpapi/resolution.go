@@ -0,0 +1,152 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"example.com/jit-engine/internal/eval"
+	"example.com/jit-engine/internal/model"
+	"example.com/jit-engine/internal/policy"
+	"example.com/jit-engine/internal/store"
+	"github.com/google/uuid"
+)
+
+// ResolutionRuleHandler manages the rules that map requests to providers.
+type ResolutionRuleHandler struct {
+	Rules  store.ResolutionRuleStore
+	Store  store.PolicyStore
+	Engine *eval.EvalEngine
+}
+
+func (h *ResolutionRuleHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var rule model.ResolutionRule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if msg := h.validate(&rule); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	rule.ID = uuid.Nil
+	if err := h.Rules.CreateResolutionRule(&rule); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.reload()
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(rule)
+}
+
+func (h *ResolutionRuleHandler) List(w http.ResponseWriter, r *http.Request) {
+	rules, err := h.Rules.ResolutionRules()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(rules)
+}
+
+func (h *ResolutionRuleHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id, ok := tailUUID(r.URL.Path, "/resolution-rules/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	var in model.ResolutionRule
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if msg := h.validate(&in); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	in.ID = id
+	if err := h.Rules.UpdateResolutionRule(&in); err != nil {
+		if err == store.ErrNotFound {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.reload()
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(in)
+}
+
+func (h *ResolutionRuleHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, ok := tailUUID(r.URL.Path, "/resolution-rules/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if err := h.Rules.DeleteResolutionRule(id); err != nil {
+		if err == store.ErrNotFound {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.reload()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// validate normalises rule and returns why it cannot be stored, or "".
+// Provider names are stored as given and resolved at evaluation.
+func (h *ResolutionRuleHandler) validate(rule *model.ResolutionRule) string {
+	if rule.Name == "" || len(rule.Providers) == 0 {
+		return "name and providers are required"
+	}
+	switch rule.Combine {
+	case "":
+		rule.Combine = eval.CombineAll
+	case eval.CombineAll, eval.CombineAny, eval.CombineFirst:
+	default:
+		return "combine must be all, any or first"
+	}
+	for field, v := range rule.Match {
+		pattern, ok := v.(string)
+		if !ok {
+			return fmt.Sprintf("match %q must be a string pattern", field)
+		}
+		if err := policy.ValidateResource(policy.MatchGlob, pattern, nil); err != nil {
+			return fmt.Sprintf("match %q: %v", field, err)
+		}
+	}
+	if rule.Expr != "" {
+		if err := policy.ValidateCEL(rule.Expr); err != nil {
+			return "invalid expr: " + err.Error()
+		}
+	}
+	for _, p := range rule.Providers {
+		switch p {
+		case "$cloud", "$protocol", "$platform":
+			continue
+		case "global", eval.BreakGlassProvider:
+			return fmt.Sprintf("provider %q cannot be resolved to", p)
+		}
+		if _, err := h.Store.ResolveProvider(p); err != nil {
+			if err == store.ErrNotFound {
+				return fmt.Sprintf("unknown provider %q", p)
+			}
+			return err.Error()
+		}
+	}
+	return ""
+}
+
+func (h *ResolutionRuleHandler) reload() {
+	if h.Engine == nil {
+		return
+	}
+	if _, err := h.Engine.Reload(); err != nil {
+		log.Printf("policy snapshot reload after resolution rule change: %v", err)
+	}
+}
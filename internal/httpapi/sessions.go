@@ -0,0 +1,111 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"example.com/jit-engine/internal/eval"
+	"example.com/jit-engine/internal/session"
+	"example.com/jit-engine/internal/store"
+)
+
+type SessionHandler struct {
+	Store    store.SessionStore
+	Registry *session.Registry
+}
+
+type registerSessionRequest struct {
+	SessionID string       `json:"session_id"`
+	Request   eval.Request `json:"request"`
+}
+
+// Register records a session after the broker received an allow for it.
+func (h *SessionHandler) Register(w http.ResponseWriter, r *http.Request) {
+	var in registerSessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil || in.SessionID == "" {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	s, res, err := h.Registry.Register(in.SessionID, in.Request)
+	if errors.Is(err, session.ErrNotAllowed) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		_ = json.NewEncoder(w).Encode(res)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(s)
+}
+
+func (h *SessionHandler) List(w http.ResponseWriter, r *http.Request) {
+	ss, err := h.Store.Sessions(store.SessionFilter{Status: r.URL.Query().Get("status"), SubjectID: r.URL.Query().Get("subject_id")})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(ss)
+}
+
+// Close is called by the broker when a session ends normally.
+func (h *SessionHandler) Close(w http.ResponseWriter, r *http.Request) {
+	id, ok := tailID(r.URL.Path, "/sessions/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	closed, err := h.Registry.Close(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !closed {
+		http.NotFound(w, r)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Events streams revocation events as server-sent events until the client disconnects.
+func (h *SessionHandler) Events(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	events, cancel := h.Registry.Subscribe()
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(15 * time.Second)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case ev, ok := <-events:
+			if !ok {
+				return
+			}
+			b, _ := json.Marshal(ev)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, b)
+			flusher.Flush()
+		}
+	}
+}
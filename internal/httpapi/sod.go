@@ -0,0 +1,101 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"example.com/jit-engine/internal/eval"
+	"example.com/jit-engine/internal/model"
+	"example.com/jit-engine/internal/store"
+	"github.com/gobwas/glob"
+	"github.com/google/uuid"
+)
+
+type SoDHandler struct {
+	Store  store.SoDStore
+	Engine *eval.EvalEngine
+}
+
+func (h *SoDHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var c model.SoDConstraint
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if msg := validateSoD(c); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	c.ID = uuid.Nil
+	if err := h.Store.CreateSoDConstraint(&c); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.reload()
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(c)
+}
+
+func validateSoD(c model.SoDConstraint) string {
+	if c.Name == "" {
+		return "name is required"
+	}
+	switch c.Kind {
+	case "static":
+		if c.Attribute == "" || len(c.Values) < 2 {
+			return "static constraints need an attribute and at least two values"
+		}
+	case "dynamic":
+		if c.ScopeKey == "" || len(c.Actions) < 2 {
+			return "dynamic constraints need a scope_key and at least two actions"
+		}
+	default:
+		return "kind must be static or dynamic"
+	}
+	if c.Resource != "" {
+		if _, err := glob.Compile(c.Resource); err != nil {
+			return "invalid resource pattern: " + err.Error()
+		}
+	}
+	return ""
+}
+
+// reload picks up the constraint change; the snapshot invalidates cached decisions itself.
+func (h *SoDHandler) reload() {
+	if h.Engine == nil {
+		return
+	}
+	if _, err := h.Engine.Reload(); err != nil {
+		log.Printf("policy snapshot reload after SoD change: %v", err)
+	}
+}
+
+func (h *SoDHandler) List(w http.ResponseWriter, r *http.Request) {
+	cs, err := h.Store.SoDConstraints(r.URL.Query().Get("kind"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(cs)
+}
+
+func (h *SoDHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, ok := tailUUID(r.URL.Path, "/sod-constraints/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if err := h.Store.DeleteSoDConstraint(id); err != nil {
+		if err == store.ErrNotFound {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.reload()
+	w.WriteHeader(http.StatusNoContent)
+}
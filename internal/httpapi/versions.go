@@ -0,0 +1,276 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"example.com/jit-engine/internal/model"
+	"example.com/jit-engine/internal/store"
+	"github.com/google/uuid"
+)
+
+// Headers naming who makes a policy write and why; both are recorded in the
+// version the write creates.
+const (
+	HeaderActor        = "X-Actor"
+	HeaderChangeReason = "X-Change-Reason"
+)
+
+// changeFrom reads the author and reason of a policy write.
+func changeFrom(r *http.Request) store.Change {
+	return store.Change{
+		Author: strings.TrimSpace(r.Header.Get(HeaderActor)),
+		Reason: strings.TrimSpace(r.Header.Get(HeaderChangeReason)),
+	}
+}
+
+// diffFields are the policy fields a diff compares, in the order reported.
+var diffFields = []string{"name", "effect", "provider", "resource", "match_kind", "exclude_resources", "actions", "expr", "metadata", "enabled", "priority"}
+
+// versionRef identifies one side of a diff.
+type versionRef struct {
+	Version      int       `json:"version"`
+	Operation    string    `json:"operation"`
+	Author       string    `json:"author,omitempty"`
+	Reason       string    `json:"reason,omitempty"`
+	RestoredFrom *int      `json:"restored_from,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// fieldChange is a field that differs between two versions.
+type fieldChange struct {
+	Field string `json:"field"`
+	From  any    `json:"from"`
+	To    any    `json:"to"`
+}
+
+type versionDiff struct {
+	PolicyID uuid.UUID     `json:"policy_id"`
+	From     versionRef    `json:"from"`
+	To       versionRef    `json:"to"`
+	Changes  []fieldChange `json:"changes"`
+}
+
+// Versions returns the recorded versions of a policy, oldest first. The
+// history of a deleted policy stays available.
+func (h *PolicyHandler) Versions(w http.ResponseWriter, r *http.Request) {
+	id, _, ok := policySubpath(r.URL.Path)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	vs, ok := h.versions(w, r, id)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(vs)
+}
+
+// Version returns one recorded version, /policies/{id}/versions/{n}.
+func (h *PolicyHandler) Version(w http.ResponseWriter, r *http.Request) {
+	id, rest, ok := policySubpath(r.URL.Path)
+	n, err := strconv.Atoi(strings.TrimPrefix(rest, "versions/"))
+	if !ok || err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	v, err := h.Store.PolicyVersion(id, n)
+	if err == store.ErrNotFound {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// Diff compares two versions of a policy field by field. Optional query: to
+// (default the latest version) and from (default the version before to).
+func (h *PolicyHandler) Diff(w http.ResponseWriter, r *http.Request) {
+	id, _, ok := policySubpath(r.URL.Path)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	vs, ok := h.versions(w, r, id)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	to, from := len(vs)-1, -1
+	for _, p := range []struct {
+		name string
+		i    *int
+	}{{"to", &to}, {"from", &from}} {
+		v := q.Get(p.name)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "invalid "+p.name, http.StatusBadRequest)
+			return
+		}
+		if *p.i = versionIndex(vs, n); *p.i < 0 {
+			http.Error(w, "unknown version "+v, http.StatusNotFound)
+			return
+		}
+	}
+	if from < 0 {
+		from = max(to-1, 0)
+	}
+	a, err := versionFields(vs[from])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	b, err := versionFields(vs[to])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	d := versionDiff{PolicyID: id, From: refOf(vs[from]), To: refOf(vs[to]), Changes: []fieldChange{}}
+	for _, f := range diffFields {
+		if !reflect.DeepEqual(a[f], b[f]) {
+			d.Changes = append(d.Changes, fieldChange{Field: f, From: a[f], To: b[f]})
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(d)
+}
+
+// Rollback restores the policy as recorded in the posted {"version": n},
+// recreating it if it was deleted. The restore is a new version, validated
+// and gated like any other write, and checks If-Match like Update.
+func (h *PolicyHandler) Rollback(w http.ResponseWriter, r *http.Request) {
+	id, _, ok := policySubpath(r.URL.Path)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	var in struct {
+		Version int `json:"version"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil || in.Version <= 0 {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	v, err := h.Store.PolicyVersion(id, in.Version)
+	if err == store.ErrNotFound {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if v.Operation == model.VersionDelete {
+		http.Error(w, "version "+strconv.Itoa(v.Version)+" records a deletion; roll back to an earlier version", http.StatusBadRequest)
+		return
+	}
+	var p model.Policy
+	if err := json.Unmarshal(v.Policy, &p); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	existing, err := h.Store.GetPolicy(id)
+	deleted := err == store.ErrNotFound
+	if err != nil && !deleted {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var ifVersion int
+	if deleted {
+		// If-Match cannot match a policy that no longer exists
+		if r.Header.Get("If-Match") != "" {
+			http.Error(w, "policy was deleted", http.StatusPreconditionFailed)
+			return
+		}
+		existing.Provider = p.Provider
+	} else if ifVersion, ok = h.ifMatch(w, r, existing); !ok {
+		return
+	}
+	p.ID = id
+	c := changeFrom(r)
+	c.RestoredFrom = v.Version
+	c.IfVersion = ifVersion
+	restored := func(s store.PolicyStore) ([]model.Policy, error) {
+		s = s.WithChange(c)
+		if deleted {
+			if err := s.RestorePolicy(&p); err != nil {
+				return nil, err
+			}
+		} else {
+			p.CreatedAt = existing.CreatedAt
+			if err := s.UpdatePolicy(&p); err != nil {
+				return nil, err
+			}
+		}
+		return []model.Policy{p}, nil
+	}
+	conflicts, ok := gatedWrite(w, h.Store, h.Engine, h.ConflictMode, nil, restored)
+	if !ok {
+		return
+	}
+	if h.Engine != nil {
+		h.Engine.PolicyChanged(p.ID, existing.Provider, p.Provider)
+	}
+	if h.Sessions != nil {
+		h.Sessions.Trigger()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", policyETag(p))
+	_ = json.NewEncoder(w).Encode(policyResponse{Policy: p, Warnings: conflicts})
+}
+
+// versions loads a policy's history, answering 404 when there is none.
+func (h *PolicyHandler) versions(w http.ResponseWriter, r *http.Request, id uuid.UUID) ([]model.PolicyVersion, bool) {
+	vs, err := h.Store.PolicyVersions(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	if len(vs) == 0 {
+		http.NotFound(w, r)
+		return nil, false
+	}
+	return vs, true
+}
+
+func versionIndex(vs []model.PolicyVersion, n int) int {
+	for i, v := range vs {
+		if v.Version == n {
+			return i
+		}
+	}
+	return -1
+}
+
+func versionFields(v model.PolicyVersion) (map[string]any, error) {
+	var fields map[string]any
+	err := json.Unmarshal(v.Policy, &fields)
+	return fields, err
+}
+
+func refOf(v model.PolicyVersion) versionRef {
+	return versionRef{Version: v.Version, Operation: v.Operation, Author: v.Author, Reason: v.Reason, RestoredFrom: v.RestoredFrom, CreatedAt: v.CreatedAt}
+}
+
+// policySubpath splits /policies/{id}/rest.
+func policySubpath(path string) (uuid.UUID, string, bool) {
+	rest, ok := tailID(path, "/policies/")
+	if !ok {
+		return uuid.Nil, "", false
+	}
+	s, sub, _ := strings.Cut(rest, "/")
+	id, err := uuid.Parse(s)
+	return id, sub, err == nil
+}
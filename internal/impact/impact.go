@@ -0,0 +1,374 @@
+// Package impact replays recorded requests against a proposed policy set and
+// reports how decisions would change.
+//
+// Both sides are evaluated by preview engines over in-memory copies of the
+// store taken when the job is created: the baseline holds the current
+// policies, the candidate the same policies with a policy file applied.
+// Neither records audits, so dynamic separation-of-duties checks see no
+// history, and break-glass requests are skipped rather than re-notified.
+package impact
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"example.com/jit-engine/internal/bundle"
+	"example.com/jit-engine/internal/eval"
+	"example.com/jit-engine/internal/model"
+	"example.com/jit-engine/internal/policyfile"
+	"example.com/jit-engine/internal/store"
+)
+
+// Job states.
+const (
+	StatusRunning  = "running"
+	StatusDone     = "done"
+	StatusFailed   = "failed"
+	StatusCanceled = "canceled"
+)
+
+// Options bound a replay.
+type Options struct {
+	// Window is how far back requests are replayed. Default 7 days.
+	Window time.Duration
+	// Limit caps the number of requests, newest first. Default 10000.
+	Limit int
+	// Samples caps the examples kept per kind of change. Default 20.
+	Samples int
+}
+
+func (o *Options) defaults() {
+	if o.Window <= 0 {
+		o.Window = 7 * 24 * time.Hour
+	}
+	if o.Limit <= 0 {
+		o.Limit = 10000
+	}
+	if o.Samples <= 0 {
+		o.Samples = 20
+	}
+}
+
+// Job is one replay. Processed grows while it runs; Result is set once it
+// is done.
+type Job struct {
+	ID         uuid.UUID        `json:"id"`
+	Status     string           `json:"status"`
+	Since      time.Time        `json:"since"`
+	Until      time.Time        `json:"until"`
+	Total      int              `json:"total"`
+	Processed  int              `json:"processed"`
+	Plan       *policyfile.Plan `json:"plan"`
+	Error      string           `json:"error,omitempty"`
+	CreatedAt  time.Time        `json:"created_at"`
+	FinishedAt *time.Time       `json:"finished_at,omitempty"`
+	Result     *Result          `json:"result,omitempty"`
+
+	cancel context.CancelFunc
+}
+
+// Result is the diff of decisions.
+type Result struct {
+	Replayed int `json:"replayed"`
+	// Skipped counts break-glass and unreadable requests.
+	Skipped        int    `json:"skipped"`
+	Unchanged      int    `json:"unchanged"`
+	NewlyDenied    Change `json:"newly_denied"`
+	NewlyAllowed   Change `json:"newly_allowed"`
+	MatchedChanged Change `json:"matched_changed"`
+}
+
+// Change counts one kind of difference and keeps samples of it.
+type Change struct {
+	Count   int      `json:"count"`
+	Samples []Sample `json:"samples"`
+}
+
+// Sample is one request whose outcome differs.
+type Sample struct {
+	AuditID uuid.UUID    `json:"audit_id"`
+	At      time.Time    `json:"at"`
+	Request eval.Request `json:"request"`
+	Before  Outcome      `json:"before"`
+	After   Outcome      `json:"after"`
+}
+
+// Outcome is what one side decided.
+type Outcome struct {
+	Decision string     `json:"decision"`
+	Matched  *uuid.UUID `json:"matched,omitempty"`
+	Policy   string     `json:"policy,omitempty"`
+	Reason   string     `json:"reason"`
+}
+
+// ErrNotFound is returned for unknown job IDs.
+var ErrNotFound = errors.New("impact job not found")
+
+// maxJobs bounds the jobs kept; the oldest finished ones are forgotten first.
+const maxJobs = 50
+
+// Manager runs jobs in the background and keeps their state in memory, so
+// jobs do not survive a restart.
+type Manager struct {
+	Audits store.AuditStore
+	Store  store.PolicyStore
+	Engine *eval.EvalEngine
+
+	mu   sync.Mutex
+	jobs []*Job
+}
+
+// Start validates f against the current policies and starts replaying
+// requests against the result. An invalid file returns the plan and
+// policyfile.ErrInvalid without starting a job.
+func (m *Manager) Start(f *policyfile.File, opts Options) (*Job, *policyfile.Plan, error) {
+	opts.defaults()
+	contents, err := bundle.Export(m.Store)
+	if err != nil {
+		return nil, nil, err
+	}
+	before, err := contents.Memory()
+	if err != nil {
+		return nil, nil, err
+	}
+	after, err := contents.Memory()
+	if err != nil {
+		return nil, nil, err
+	}
+	plan, err := policyfile.Apply(f, after, store.Change{})
+	if err != nil {
+		return nil, plan, err
+	}
+	baseline, err := m.Engine.Preview(withDelegations{before, m.Store})
+	if err != nil {
+		return nil, nil, err
+	}
+	candidate, err := m.Engine.Preview(withDelegations{after, m.Store})
+	if err != nil {
+		return nil, nil, err
+	}
+	names := map[uuid.UUID]string{}
+	for _, s := range []*store.Memory{before, after} {
+		ps, _ := s.ListPolicies(store.PolicyFilter{})
+		for _, p := range ps {
+			names[p.ID] = p.Provider + "/" + p.Name
+		}
+	}
+
+	now := time.Now().UTC()
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &Job{ID: uuid.New(), Status: StatusRunning, Since: now.Add(-opts.Window), Until: now, Plan: plan, CreatedAt: now, cancel: cancel}
+	m.add(job)
+	r := &replay{m: m, job: job, baseline: baseline, candidate: candidate, names: names, opts: opts}
+	go r.run(ctx)
+	return m.snapshot(job), plan, nil
+}
+
+// Get returns a copy of the job.
+func (m *Manager) Get(id uuid.UUID) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, j := range m.jobs {
+		if j.ID == id {
+			return m.copyLocked(j), nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// List returns copies of the jobs without their results, newest first.
+func (m *Manager) List() []*Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]*Job, 0, len(m.jobs))
+	for i := len(m.jobs) - 1; i >= 0; i-- {
+		j := m.copyLocked(m.jobs[i])
+		j.Result = nil
+		out = append(out, j)
+	}
+	return out
+}
+
+// Cancel stops a running job.
+func (m *Manager) Cancel(id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, j := range m.jobs {
+		if j.ID == id {
+			j.cancel()
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (m *Manager) add(job *Job) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.jobs = append(m.jobs, job)
+	// Forget the oldest finished jobs beyond maxJobs
+	for excess := len(m.jobs) - maxJobs; excess > 0; excess-- {
+		i := 0
+		for i < len(m.jobs) && m.jobs[i].Status == StatusRunning {
+			i++
+		}
+		if i == len(m.jobs) {
+			break
+		}
+		m.jobs = append(m.jobs[:i], m.jobs[i+1:]...)
+	}
+}
+
+func (m *Manager) update(job *Job, fn func(*Job)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	fn(job)
+}
+
+func (m *Manager) snapshot(job *Job) *Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.copyLocked(job)
+}
+
+func (m *Manager) copyLocked(job *Job) *Job {
+	c := *job
+	c.cancel = nil
+	if job.Result != nil {
+		res := *job.Result
+		c.Result = &res
+	}
+	return &c
+}
+
+// withDelegations answers delegation lookups from the live store, as the
+// store copies do not hold delegations.
+type withDelegations struct {
+	*store.Memory
+	live store.PolicyStore
+}
+
+func (w withDelegations) ActiveDelegations(delegate, action string, at time.Time) ([]model.Delegation, error) {
+	return w.live.ActiveDelegations(delegate, action, at)
+}
+
+// replay is the work of one job.
+type replay struct {
+	m         *Manager
+	job       *Job
+	baseline  *eval.EvalEngine
+	candidate *eval.EvalEngine
+	names     map[uuid.UUID]string
+	opts      Options
+}
+
+// pageSize is how many requests are read, and replayed between progress
+// updates, at a time.
+const pageSize = 500
+
+func (r *replay) run(ctx context.Context) {
+	res, err := r.replay(ctx)
+	now := time.Now().UTC()
+	r.m.update(r.job, func(j *Job) {
+		j.FinishedAt = &now
+		switch {
+		case ctx.Err() != nil:
+			j.Status = StatusCanceled
+		case err != nil:
+			j.Status, j.Error = StatusFailed, err.Error()
+		default:
+			j.Status, j.Result = StatusDone, res
+		}
+	})
+}
+
+func (r *replay) replay(ctx context.Context) (*Result, error) {
+	window := store.AuditFilter{Since: r.job.Since, Until: r.job.Until}
+	total, err := r.m.Audits.CountAudits(window)
+	if err != nil {
+		return nil, err
+	}
+	r.m.update(r.job, func(j *Job) { j.Total = min(total, r.opts.Limit) })
+
+	res := &Result{NewlyDenied: Change{Samples: []Sample{}}, NewlyAllowed: Change{Samples: []Sample{}}, MatchedChanged: Change{Samples: []Sample{}}}
+	// Pages are read whole so no connection is held while evaluating, which
+	// may query the store for delegations.
+	n := 0
+	for n < r.opts.Limit {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		f := window
+		f.Offset, f.Limit = n, min(pageSize, r.opts.Limit-n)
+		page, err := r.m.Audits.ListAudits(f)
+		if err != nil {
+			return nil, err
+		}
+		for i := range page {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			r.compare(&page[i], res)
+		}
+		n += len(page)
+		r.m.update(r.job, func(j *Job) { j.Processed = n })
+		if len(page) < pageSize {
+			break
+		}
+	}
+	return res, nil
+}
+
+// compare evaluates one recorded request on both sides and files the
+// difference.
+func (r *replay) compare(a *model.PolicyAudit, res *Result) {
+	var req eval.Request
+	if a.BreakGlass || json.Unmarshal(a.Request, &req) != nil || req.BreakGlass {
+		res.Skipped++
+		return
+	}
+	res.Replayed++
+	// Evaluation errors still yield the fail-open or fail-closed decision,
+	// which is what the diff is about.
+	before, _ := r.baseline.Evaluate(req)
+	after, _ := r.candidate.Evaluate(req)
+	var change *Change
+	switch {
+	case before.Decision == "allow" && after.Decision != "allow":
+		change = &res.NewlyDenied
+	case before.Decision != "allow" && after.Decision == "allow":
+		change = &res.NewlyAllowed
+	case !sameMatch(before.Matched, after.Matched):
+		change = &res.MatchedChanged
+	default:
+		res.Unchanged++
+		return
+	}
+	change.Count++
+	if len(change.Samples) < r.opts.Samples {
+		change.Samples = append(change.Samples, Sample{
+			AuditID: a.ID, At: a.CreatedAt, Request: req,
+			Before: r.outcome(before), After: r.outcome(after),
+		})
+	}
+}
+
+func (r *replay) outcome(res eval.Result) Outcome {
+	o := Outcome{Decision: res.Decision, Matched: res.Matched, Reason: res.Reason}
+	if res.Matched != nil {
+		o.Policy = r.names[*res.Matched]
+	}
+	return o
+}
+
+func sameMatch(a, b *uuid.UUID) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,165 @@
+package lint
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"example.com/jit-engine/internal/model"
+	"example.com/jit-engine/internal/policy"
+)
+
+// Conflict is an allow and a deny of the same provider that can apply to the
+// same request. Deny wins wherever both conditions hold.
+type Conflict struct {
+	Provider string    `json:"provider"`
+	Allow    PolicyRef `json:"allow"`
+	Deny     PolicyRef `json:"deny"`
+	// SamePriority marks pairs whose author may have expected the allow to
+	// take precedence.
+	SamePriority bool   `json:"same_priority"`
+	Message      string `json:"message"`
+}
+
+// PolicyRef identifies a policy in a conflict.
+type PolicyRef struct {
+	ID       uuid.UUID `json:"id"`
+	Name     string    `json:"name"`
+	Resource string    `json:"resource"`
+	Priority int       `json:"priority"`
+}
+
+// Conflicts lists the enabled policies among others that conflict with p.
+// It returns nothing for a disabled p.
+func (l *Linter) Conflicts(p *model.Policy, others []model.Policy) []Conflict {
+	out := []Conflict{}
+	if !p.Enabled {
+		return out
+	}
+	for i := range others {
+		o := &others[i]
+		if o.ID != p.ID && o.Enabled {
+			if c, ok := l.conflict(p, o); ok {
+				out = append(out, c)
+			}
+		}
+	}
+	return out
+}
+
+// AllConflicts lists every conflicting pair among the enabled policies in ps,
+// by provider then allow name.
+func (l *Linter) AllConflicts(ps []model.Policy) []Conflict {
+	out := []Conflict{}
+	for i := range ps {
+		if ps[i].Effect != "allow" || !ps[i].Enabled {
+			continue
+		}
+		for j := range ps {
+			if ps[j].Enabled {
+				if c, ok := l.conflict(&ps[i], &ps[j]); ok {
+					out = append(out, c)
+				}
+			}
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Provider != out[j].Provider {
+			return out[i].Provider < out[j].Provider
+		}
+		if out[i].Allow.Name != out[j].Allow.Name {
+			return out[i].Allow.Name < out[j].Allow.Name
+		}
+		return out[i].Deny.Name < out[j].Deny.Name
+	})
+	return out
+}
+
+func (l *Linter) conflict(a, b *model.Policy) (Conflict, bool) {
+	if a.Provider != b.Provider || a.Effect == b.Effect || !resourcesIntersect(a, b) || !l.actionsOverlap(a, b) {
+		return Conflict{}, false
+	}
+	allow, deny := a, b
+	if a.Effect == "deny" {
+		allow, deny = b, a
+	}
+	c := Conflict{
+		Provider:     a.Provider,
+		Allow:        ref(allow),
+		Deny:         ref(deny),
+		SamePriority: a.Priority == b.Priority,
+	}
+	c.Message = fmt.Sprintf("allow %q and deny %q overlap on resources and actions; the deny wins wherever both match", allow.Name, deny.Name)
+	if c.SamePriority {
+		c.Message += fmt.Sprintf(" (both priority %d)", a.Priority)
+	}
+	return c, true
+}
+
+func ref(p *model.Policy) PolicyRef {
+	return PolicyRef{ID: p.ID, Name: p.Name, Resource: p.Resource, Priority: p.Priority}
+}
+
+// resourcesIntersect reports whether some resource could match both
+// policies. Globs made of literals, * and ? are intersected exactly; other
+// patterns are compared by literal prefix. Exclusions are ignored.
+func resourcesIntersect(a, b *model.Policy) bool {
+	ga, okA := asGlob(a)
+	gb, okB := asGlob(b)
+	if okA && okB {
+		return globsIntersect(ga, gb)
+	}
+	pa, pb := literalPrefix(a), literalPrefix(b)
+	return strings.HasPrefix(pa, pb) || strings.HasPrefix(pb, pa)
+}
+
+// asGlob returns p's resource as a glob of literals, * and ?, if it is one.
+func asGlob(p *model.Policy) (string, bool) {
+	switch p.MatchKind {
+	case "", policy.MatchGlob:
+		if p.Resource == "" {
+			return "*", true
+		}
+		return p.Resource, !strings.ContainsAny(p.Resource, `[]{}\!`)
+	case policy.MatchExact, policy.MatchPrefix:
+		// Literal * and ? would read as wildcards.
+		if strings.ContainsAny(p.Resource, `*?[]{}\!`) {
+			return "", false
+		}
+		if p.MatchKind == policy.MatchPrefix {
+			return p.Resource + "*", true
+		}
+		return p.Resource, true
+	}
+	return "", false
+}
+
+// globsIntersect reports whether some string matches both patterns, where *
+// matches any run of characters and ? any one.
+func globsIntersect(a, b string) bool {
+	ra, rb := []rune(a), []rune(b)
+	seen := map[[2]int]bool{}
+	var walk func(i, j int) bool
+	walk = func(i, j int) bool {
+		key := [2]int{i, j}
+		if done, ok := seen[key]; ok {
+			return done
+		}
+		var ok bool
+		switch {
+		case i == len(ra) && j == len(rb):
+			ok = true
+		case i < len(ra) && ra[i] == '*':
+			ok = walk(i+1, j) || (j < len(rb) && walk(i, j+1))
+		case j < len(rb) && rb[j] == '*':
+			ok = walk(i, j+1) || (i < len(ra) && walk(i+1, j))
+		case i < len(ra) && j < len(rb):
+			ok = (ra[i] == rb[j] || ra[i] == '?' || rb[j] == '?') && walk(i+1, j+1)
+		}
+		seen[key] = ok
+		return ok
+	}
+	return walk(0, 0)
+}
@@ -0,0 +1,106 @@
+package lint
+
+import (
+	"sort"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/types"
+
+	"example.com/jit-engine/internal/policy"
+)
+
+// exprChecker inspects CEL expressions with the engine's declarations.
+type exprChecker struct {
+	env *cel.Env
+}
+
+// exprInfo is what an expression reveals without a request.
+type exprInfo struct {
+	// constant is set when the expression yields the same boolean for every
+	// request.
+	constant *bool
+	// subjectFields are the subject attributes it reads, sorted.
+	subjectFields []string
+}
+
+func newExprChecker() (*exprChecker, error) {
+	env, err := policy.NewEnv()
+	if err != nil {
+		return nil, err
+	}
+	return &exprChecker{env: env}, nil
+}
+
+// inspect reports what it can about expr. Expressions that do not compile
+// reveal nothing; write-time validation reports them.
+func (c *exprChecker) inspect(expr string) exprInfo {
+	var info exprInfo
+	checked, iss := c.env.Compile(expr)
+	if iss != nil && iss.Err() != nil {
+		return info
+	}
+	info.constant = c.constant(checked)
+	info.subjectFields = subjectFields(checked.NativeRep())
+	return info
+}
+
+// constant evaluates expr with every request variable unknown. A boolean
+// result is one no request can change, e.g. "true || subject.admin".
+func (c *exprChecker) constant(checked *cel.Ast) *bool {
+	prg, err := c.env.Program(checked, cel.EvalOptions(cel.OptPartialEval))
+	if err != nil {
+		return nil
+	}
+	unknowns := make([]*cel.AttributePatternType, len(policy.RequestVars))
+	for i, v := range policy.RequestVars {
+		unknowns[i] = cel.AttributePattern(v)
+	}
+	vars, err := cel.PartialVars(map[string]any{}, unknowns...)
+	if err != nil {
+		return nil
+	}
+	out, _, err := prg.Eval(vars)
+	if err != nil || types.IsUnknownOrError(out) {
+		return nil
+	}
+	b, ok := out.Value().(bool)
+	if !ok {
+		return nil
+	}
+	return &b
+}
+
+// subjectFields lists the attributes read as subject.name or
+// subject["name"].
+func subjectFields(a *ast.AST) []string {
+	seen := map[string]bool{}
+	isSubject := func(e ast.Expr) bool {
+		return e.Kind() == ast.IdentKind && e.AsIdent() == "subject"
+	}
+	for _, e := range ast.MatchDescendants(ast.NavigateAST(a), ast.AllMatcher()) {
+		switch e.Kind() {
+		case ast.SelectKind:
+			// has(subject.name) is how expressions guard optional attributes.
+			if sel := e.AsSelect(); isSubject(sel.Operand()) && !sel.IsTestOnly() {
+				seen[sel.FieldName()] = true
+			}
+		case ast.CallKind:
+			call := e.AsCall()
+			if call.FunctionName() != "_[_]" || len(call.Args()) != 2 || !isSubject(call.Args()[0]) {
+				continue
+			}
+			if key := call.Args()[1]; key.Kind() == ast.LiteralKind {
+				if s, ok := key.AsLiteral().Value().(string); ok {
+					seen[s] = true
+				}
+			}
+		}
+	}
+	out := make([]string, 0, len(seen))
+	for f := range seen {
+		out = append(out, f)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,379 @@
+// Package lint finds policies that compile but are unlikely to do what their
+// author meant: conditions that are always true or false, allows no request
+// can reach, duplicates, ambiguous ordering, resources outside a provider's
+// naming convention and subject attributes no request declares.
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"example.com/jit-engine/internal/model"
+	"example.com/jit-engine/internal/policy"
+	"example.com/jit-engine/internal/store"
+)
+
+// Severities, from most to least serious.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+	SeverityInfo    = "info"
+)
+
+// Rules reported in findings.
+const (
+	RuleConstantExpr      = "constant-expr"
+	RuleShadowedAllow     = "shadowed-allow"
+	RuleDuplicate         = "duplicate"
+	RulePriorityCollision = "priority-collision"
+	RuleResourcePattern   = "resource-convention"
+	RuleUndeclaredSubject = "undeclared-subject-field"
+)
+
+// Finding is one problem with a policy. Related names the other policy
+// involved, for rules comparing two.
+type Finding struct {
+	Rule      string     `json:"rule"`
+	Severity  string     `json:"severity"`
+	PolicyID  *uuid.UUID `json:"policy_id,omitempty"`
+	Policy    string     `json:"policy"`
+	RelatedID *uuid.UUID `json:"related_id,omitempty"`
+	Related   string     `json:"related,omitempty"`
+	Message   string     `json:"message"`
+}
+
+// Rank orders severities: error is 0, unknown severities rank last.
+func Rank(severity string) int {
+	switch severity {
+	case SeverityError:
+		return 0
+	case SeverityWarning:
+		return 1
+	case SeverityInfo:
+		return 2
+	}
+	return 3
+}
+
+// ValidSeverity reports whether s names a severity.
+func ValidSeverity(s string) bool { return Rank(s) < 3 }
+
+// AtLeast keeps the findings at least as serious as min.
+func AtLeast(fs []Finding, min string) []Finding {
+	out := []Finding{}
+	for _, f := range fs {
+		if Rank(f.Severity) <= Rank(min) {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// Linter checks policies against the providers and action catalog of a store.
+type Linter struct {
+	providers map[string]model.Provider
+	catalog   *policy.ActionCatalog
+	// subject maps a provider to the subject attributes its requests
+	// declare; a provider with none is not checked.
+	subject map[string]map[string]bool
+	exprs   *exprChecker
+}
+
+// New loads what linting needs from s. subjectFields are subject attributes
+// declared for every provider, on top of those in provider request schemas.
+func New(s store.PolicyStore, subjectFields []string) (*Linter, error) {
+	provs, err := s.Providers()
+	if err != nil {
+		return nil, err
+	}
+	groups, err := s.ActionGroups()
+	if err != nil {
+		return nil, err
+	}
+	hierarchies, err := s.ActionHierarchies()
+	if err != nil {
+		return nil, err
+	}
+	exprs, err := newExprChecker()
+	if err != nil {
+		return nil, err
+	}
+	l := &Linter{
+		providers: map[string]model.Provider{},
+		catalog:   &policy.ActionCatalog{Groups: map[string][]string{}, Implies: map[string]map[string][]string{}},
+		subject:   map[string]map[string]bool{},
+		exprs:     exprs,
+	}
+	for _, g := range groups {
+		l.catalog.Groups[g.Name] = g.Actions
+	}
+	for _, h := range hierarchies {
+		if l.catalog.Implies[h.Provider] == nil {
+			l.catalog.Implies[h.Provider] = map[string][]string{}
+		}
+		l.catalog.Implies[h.Provider][h.Action] = h.Implies
+	}
+	// Global policies see requests of every provider.
+	global := map[string]bool{}
+	for _, p := range provs {
+		l.providers[p.Name] = p
+		fields := map[string]bool{}
+		for _, f := range schemaSubjectFields(p.RequestSchema) {
+			fields[f] = true
+			global[f] = true
+		}
+		l.subject[p.Name] = fields
+	}
+	for name, fields := range l.subject {
+		for _, f := range subjectFields {
+			fields[f] = true
+		}
+		if len(fields) == 0 {
+			delete(l.subject, name)
+		}
+	}
+	for _, f := range subjectFields {
+		global[f] = true
+	}
+	if len(global) > 0 {
+		l.subject["global"] = global
+	}
+	return l, nil
+}
+
+// schemaSubjectFields lists the properties a request schema declares for
+// subject. Invalid schemas declare nothing.
+func schemaSubjectFields(raw []byte) []string {
+	s, err := policy.ParseSchema(raw)
+	if err != nil || s == nil || s.Properties["subject"] == nil {
+		return nil
+	}
+	var out []string
+	for name := range s.Properties["subject"].Properties {
+		out = append(out, name)
+	}
+	return out
+}
+
+// Lint checks ps. Disabled policies get the per-policy checks only, as they
+// take no part in evaluation. Findings are sorted by severity, then policy.
+func (l *Linter) Lint(ps []model.Policy) []Finding {
+	out := []Finding{}
+	var enabled []model.Policy
+	for i := range ps {
+		out = append(out, l.lintPolicy(&ps[i])...)
+		if ps[i].Enabled {
+			enabled = append(enabled, ps[i])
+		}
+	}
+	out = append(out, l.lintPairs(enabled)...)
+	sort.SliceStable(out, func(i, j int) bool {
+		if ri, rj := Rank(out[i].Severity), Rank(out[j].Severity); ri != rj {
+			return ri < rj
+		}
+		return out[i].Policy < out[j].Policy
+	})
+	return out
+}
+
+func (l *Linter) lintPolicy(p *model.Policy) []Finding {
+	var out []Finding
+	add := func(rule, severity, format string, args ...any) {
+		out = append(out, newFinding(rule, severity, p, fmt.Sprintf(format, args...)))
+	}
+
+	info := l.exprs.inspect(p.Expr)
+	if info.constant != nil {
+		if *info.constant {
+			outcome := map[string]string{"allow": "allowed", "deny": "denied"}[p.Effect]
+			add(RuleConstantExpr, SeverityInfo, "expr is always true: every request the resource and actions cover is %s", outcome)
+		} else {
+			add(RuleConstantExpr, SeverityWarning, "expr is always false: the policy never applies")
+		}
+	}
+	if declared, ok := l.subject[p.Provider]; ok {
+		for _, f := range info.subjectFields {
+			if !declared[f] {
+				add(RuleUndeclaredSubject, SeverityWarning, "subject.%s is not declared for provider %q; requests without it fail evaluation", f, p.Provider)
+			}
+		}
+	}
+
+	if prov, ok := l.providers[p.Provider]; ok && len(prov.ResourcePatterns) > 0 {
+		if m, err := policy.CompileMatcher(p.MatchKind, p.Resource, nil); err == nil && !policy.FollowsConvention(m.LiteralPrefix(), prov.ResourcePatterns) {
+			add(RuleResourcePattern, SeverityError, "resource %q can never match a %s resource (%s)", p.Resource, prov.Name, strings.Join(prov.ResourcePatterns, ", "))
+		}
+	}
+	return out
+}
+
+// lintPairs compares enabled policies with each other.
+func (l *Linter) lintPairs(ps []model.Policy) []Finding {
+	var out []Finding
+	for i := range ps {
+		a := &ps[i]
+		for j := range ps {
+			b := &ps[j]
+			if i == j {
+				continue
+			}
+			if a.Provider == b.Provider && i < j {
+				if sameRule(a, b) {
+					out = append(out, related(newFinding(RuleDuplicate, SeverityWarning, b,
+						fmt.Sprintf("duplicates %s/%s: same effect, resource, actions and expr", a.Provider, a.Name)), a))
+				} else if a.Effect == b.Effect && a.Priority == b.Priority && policy.Specificity(a.Resource) == policy.Specificity(b.Resource) &&
+					resourcesIntersect(a, b) && l.actionsOverlap(a, b) {
+					out = append(out, related(newFinding(RulePriorityCollision, SeverityInfo, b,
+						fmt.Sprintf("has the same priority (%d) and specificity as %s/%s for overlapping resources; creation time decides their order", a.Priority, a.Provider, a.Name)), a))
+				}
+			}
+			if l.shadows(b, a) {
+				out = append(out, related(newFinding(RuleShadowedAllow, SeverityWarning, a,
+					fmt.Sprintf("can never allow: deny %s/%s always applies to every resource and action it covers", b.Provider, b.Name)), b))
+			}
+		}
+	}
+	return out
+}
+
+// shadows reports whether deny d always applies wherever allow a does. The
+// engine is deny-overrides, so priority does not matter.
+func (l *Linter) shadows(d, a *model.Policy) bool {
+	if a.Effect != "allow" || d.Effect != "deny" {
+		return false
+	}
+	if d.Provider != "global" && d.Provider != a.Provider {
+		return false
+	}
+	if c := l.exprs.inspect(d.Expr).constant; c == nil || !*c {
+		return false
+	}
+	return ResourceCovers(d, a) && l.actionsCover(d, a)
+}
+
+// ResourceCovers reports whether every resource a matches is matched by
+// outer. It only recognises the common shapes and answers false otherwise.
+func ResourceCovers(outer, a *model.Policy) bool {
+	if len(outer.ExcludeResources) > 0 {
+		return false
+	}
+	if outer.MatchKind == a.MatchKind && outer.Resource == a.Resource {
+		return true
+	}
+	switch outer.MatchKind {
+	case "", policy.MatchGlob:
+		if outer.Resource == "" || outer.Resource == "*" {
+			return true
+		}
+		// A trailing * after a literal prefix covers anything with that prefix.
+		prefix, ok := strings.CutSuffix(outer.Resource, "*")
+		if !ok || policy.IsGlob(prefix) {
+			return a.MatchKind == policy.MatchExact && globMatches(outer.Resource, a.Resource)
+		}
+		return strings.HasPrefix(literalPrefix(a), prefix)
+	case policy.MatchPrefix:
+		return strings.HasPrefix(literalPrefix(a), outer.Resource)
+	}
+	return false
+}
+
+func globMatches(pattern, resource string) bool {
+	m, err := policy.CompileMatcher(policy.MatchGlob, pattern, nil)
+	return err == nil && m.Match(resource)
+}
+
+func literalPrefix(p *model.Policy) string {
+	m, err := policy.CompileMatcher(p.MatchKind, p.Resource, nil)
+	if err != nil {
+		return ""
+	}
+	return m.LiteralPrefix()
+}
+
+// actionsCover reports whether outer covers every action a does.
+func (l *Linter) actionsCover(outer, a *model.Policy) bool {
+	outerSet := l.catalog.Expand(outer.Provider, outer.Actions)
+	if outerSet.Any {
+		return true
+	}
+	as := l.catalog.Expand(a.Provider, a.Actions)
+	if as.Any {
+		return false
+	}
+	for _, g := range as.Globs {
+		found := false
+		for _, og := range outerSet.Globs {
+			if og.Pattern == g.Pattern || og.Pattern == "*" {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	for act := range as.Exact {
+		if _, ok := outerSet.Match(act); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// actionsOverlap reports whether some action could be covered by both.
+func (l *Linter) actionsOverlap(a, b *model.Policy) bool {
+	as, bs := l.catalog.Expand(a.Provider, a.Actions), l.catalog.Expand(b.Provider, b.Actions)
+	if as.Any || bs.Any || (len(as.Globs) > 0 && len(bs.Globs) > 0) {
+		return true
+	}
+	for act := range as.Exact {
+		if _, ok := bs.Match(act); ok {
+			return true
+		}
+	}
+	for act := range bs.Exact {
+		if _, ok := as.Match(act); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// sameRule reports whether a and b decide identically, ignoring name,
+// priority and metadata.
+func sameRule(a, b *model.Policy) bool {
+	return a.Effect == b.Effect && a.MatchKind == b.MatchKind && a.Resource == b.Resource &&
+		sameSet(a.ExcludeResources, b.ExcludeResources) && sameSet(a.Actions, b.Actions) &&
+		strings.Join(strings.Fields(a.Expr), " ") == strings.Join(strings.Fields(b.Expr), " ")
+}
+
+func sameSet(a, b []string) bool {
+	x, y := append([]string(nil), a...), append([]string(nil), b...)
+	sort.Strings(x)
+	sort.Strings(y)
+	ax, _ := json.Marshal(x)
+	ay, _ := json.Marshal(y)
+	return string(ax) == string(ay)
+}
+
+func newFinding(rule, severity string, p *model.Policy, msg string) Finding {
+	f := Finding{Rule: rule, Severity: severity, Policy: p.Provider + "/" + p.Name, Message: msg}
+	if p.ID != uuid.Nil {
+		id := p.ID
+		f.PolicyID = &id
+	}
+	return f
+}
+
+func related(f Finding, p *model.Policy) Finding {
+	f.Related = p.Provider + "/" + p.Name
+	if p.ID != uuid.Nil {
+		id := p.ID
+		f.RelatedID = &id
+	}
+	return f
+}
@@ -0,0 +1,173 @@
+package lint
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"example.com/jit-engine/internal/model"
+	"example.com/jit-engine/internal/policy"
+	"example.com/jit-engine/internal/store"
+)
+
+func TestGlobsIntersect(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"*", "", true},
+		{"*", "anything", true},
+		{"", "", true},
+		{"?", "", false},
+		{"?", "*", true},
+		{"?", "??", false},
+		{"??", "a*", true},
+		{"a*", "*b", true},
+		{"a*", "b*", false},
+		{"ssh:*:host", "ssh:unix:*", true},
+		{"ssh:unix:*", "rdp:*", false},
+		{"a?c", "ab", false},
+		{"a?c", "abc", true},
+		{"*a*", "*b*", true},
+		{"x*y", "x*z", false},
+	}
+	for _, tt := range tests {
+		if got := globsIntersect(tt.a, tt.b); got != tt.want {
+			t.Errorf("globsIntersect(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+		if got := globsIntersect(tt.b, tt.a); got != tt.want {
+			t.Errorf("globsIntersect(%q, %q) = %v, want %v", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
+
+func pattern(kind, resource string, exclude ...string) *model.Policy {
+	return &model.Policy{MatchKind: kind, Resource: resource, ExcludeResources: exclude}
+}
+
+func TestResourceCovers(t *testing.T) {
+	tests := []struct {
+		name     string
+		outer, a *model.Policy
+		want     bool
+	}{
+		{"star covers everything", pattern("", "*"), pattern(policy.MatchRegex, "db:.*"), true},
+		{"empty glob covers everything", pattern("", ""), pattern(policy.MatchGlob, "ssh:*"), true},
+		{"exclusions are not analysed", pattern("", "*", "ssh:prod*"), pattern(policy.MatchExact, "ssh:dev"), false},
+		{"same pattern", pattern(policy.MatchExact, "a"), pattern(policy.MatchExact, "a"), true},
+		{"trailing star covers a longer prefix", pattern("", "ssh:*"), pattern("", "ssh:unix:*"), true},
+		{"trailing star misses a shorter prefix", pattern("", "ssh:unix:*"), pattern("", "ssh:*"), false},
+		{"trailing star misses an empty resource", pattern("", "ssh:*"), pattern("", ""), false},
+		{"trailing star covers a question mark", pattern("", "a*"), pattern("", "a?"), true},
+		{"inner wildcard covers a matching exact", pattern("", "ssh:*:host"), pattern(policy.MatchExact, "ssh:a:host"), true},
+		{"inner wildcard and a glob is unknown", pattern("", "ssh:*:host"), pattern("", "ssh:a:host"), false},
+		{"question mark covers a matching exact", pattern("", "ssh:?"), pattern(policy.MatchExact, "ssh:a"), true},
+		{"question mark misses a longer exact", pattern("", "ssh:?"), pattern(policy.MatchExact, "ssh:ab"), false},
+		{"prefix covers an exact", pattern(policy.MatchPrefix, "db:"), pattern(policy.MatchExact, "db:x"), true},
+		{"prefix covers a regex by its literal prefix", pattern(policy.MatchPrefix, "db:"), pattern(policy.MatchRegex, "db:x.*"), true},
+		{"prefix misses another prefix", pattern(policy.MatchPrefix, "db:x"), pattern(policy.MatchPrefix, "db:"), false},
+		{"exact covers nothing else", pattern(policy.MatchExact, "a"), pattern("", "a"), false},
+		{"regex is not analysed", pattern(policy.MatchRegex, ".*"), pattern(policy.MatchExact, "a"), false},
+	}
+	for _, tt := range tests {
+		if got := ResourceCovers(tt.outer, tt.a); got != tt.want {
+			t.Errorf("%s: ResourceCovers(%q, %q) = %v, want %v", tt.name, tt.outer.Resource, tt.a.Resource, got, tt.want)
+		}
+	}
+}
+
+func TestResourcesIntersect(t *testing.T) {
+	tests := []struct {
+		a, b *model.Policy
+		want bool
+	}{
+		{pattern("", ""), pattern(policy.MatchExact, "x"), true},
+		{pattern(policy.MatchPrefix, "ssh:"), pattern("", "*:unix"), true},
+		{pattern(policy.MatchPrefix, "ssh:"), pattern("", "rdp:*"), false},
+		{pattern(policy.MatchExact, "a*"), pattern("", "a"), true},
+		{pattern(policy.MatchRegex, "db:x.*"), pattern("", "db:y*"), false},
+		{pattern("", "ssh:*", "ssh:prod*"), pattern(policy.MatchExact, "ssh:prod1"), true},
+	}
+	for _, tt := range tests {
+		if got := resourcesIntersect(tt.a, tt.b); got != tt.want {
+			t.Errorf("resourcesIntersect(%q, %q) = %v, want %v", tt.a.Resource, tt.b.Resource, got, tt.want)
+		}
+	}
+}
+
+func TestInspect(t *testing.T) {
+	c, err := newExprChecker()
+	if err != nil {
+		t.Fatal(err)
+	}
+	yes, no := true, false
+	tests := []struct {
+		expr     string
+		constant *bool
+		subject  []string
+	}{
+		{"true", &yes, nil},
+		{"false", &no, nil},
+		{"1 == 1", &yes, nil},
+		{"[1, 2].size() == 2", &yes, nil},
+		{"true || subject.admin", &yes, []string{"admin"}},
+		{"subject.admin || true", &yes, []string{"admin"}},
+		{"false && subject.admin", &no, []string{"admin"}},
+		{"subject.admin", nil, []string{"admin"}},
+		{"resource.startsWith('ssh:')", nil, nil},
+		{"subject.team == 'a' && subject['role'] == 'b' && has(subject.opt)", nil, []string{"role", "team"}},
+		{"1", nil, nil},
+		{"subject.(", nil, nil},
+	}
+	for _, tt := range tests {
+		info := c.inspect(tt.expr)
+		if (info.constant == nil) != (tt.constant == nil) || info.constant != nil && *info.constant != *tt.constant {
+			t.Errorf("%q: constant = %v, want %v", tt.expr, show(info.constant), show(tt.constant))
+		}
+		if !slices.Equal(info.subjectFields, tt.subject) && len(info.subjectFields)+len(tt.subject) > 0 {
+			t.Errorf("%q: subject fields = %v, want %v", tt.expr, info.subjectFields, tt.subject)
+		}
+	}
+}
+
+func show(b *bool) any {
+	if b == nil {
+		return "unknown"
+	}
+	return *b
+}
+
+func TestShadowedAllow(t *testing.T) {
+	l, err := New(store.NewMemory(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policyOf := func(provider, effect, expr, resource string, priority int, actions ...string) model.Policy {
+		return model.Policy{ID: uuid.New(), Name: provider + " " + effect + " " + resource, Provider: provider, Effect: effect, Expr: expr,
+			Resource: resource, Actions: actions, Priority: priority, Enabled: true}
+	}
+	allow := policyOf("ssh", "allow", "true", "ssh:unix:*", 100, "login")
+	tests := []struct {
+		name string
+		deny model.Policy
+		want bool
+	}{
+		{"same provider", policyOf("ssh", "deny", "true", "ssh:*", 100, "login"), true},
+		{"lower priority still overrides", policyOf("ssh", "deny", "true", "ssh:*", 500, "login"), true},
+		{"global", policyOf("global", "deny", "true", "*", 1), true},
+		{"other provider", policyOf("rdp", "deny", "true", "*", 1), false},
+		{"conditional", policyOf("ssh", "deny", "subject.contractor", "ssh:*", 1, "login"), false},
+		{"narrower resource", policyOf("ssh", "deny", "true", "ssh:unix:prod*", 1, "login"), false},
+		{"other action", policyOf("ssh", "deny", "true", "ssh:*", 1, "sudo"), false},
+	}
+	for _, tt := range tests {
+		var got bool
+		for _, f := range l.Lint([]model.Policy{allow, tt.deny}) {
+			got = got || f.Rule == RuleShadowedAllow && f.Policy == "ssh/"+allow.Name
+		}
+		if got != tt.want {
+			t.Errorf("%s: shadowed = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
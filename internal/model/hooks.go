@@ -1,17 +1,152 @@
 package model
 
 import (
+	"errors"
+	"fmt"
+	"strings"
+
 	"example.com/jit-engine/internal/policy"
 	"gorm.io/gorm"
 )
 
-func (p *Policy) BeforeCreate(tx *gorm.DB) (err error) {
+// PolicyRefs looks up the records a policy refers to while it is validated.
+type PolicyRefs interface {
+	// KnownActionGroups returns which of names are existing action groups.
+	KnownActionGroups(names []string) ([]string, error)
+	// ProviderByName returns the provider registered as name, or nil.
+	ProviderByName(name string) (*Provider, error)
+}
+
+// ValidatePolicy checks everything a policy write is checked for: resource
+// pattern, actions, provider conventions and the CEL expression. An empty
+// MatchKind is set to glob.
+func ValidatePolicy(p *Policy, refs PolicyRefs) error {
+	if p.MatchKind == "" {
+		p.MatchKind = policy.MatchGlob
+	}
+	if err := policy.ValidateResource(p.MatchKind, p.Resource, p.ExcludeResources); err != nil {
+		return err
+	}
+	if err := validateActions(refs, p.Actions); err != nil {
+		return err
+	}
+	if err := validateProvider(refs, p); err != nil {
+		return err
+	}
 	return policy.ValidateCEL(p.Expr)
 }
 
+func (p *Policy) BeforeCreate(tx *gorm.DB) (err error) {
+	return ValidatePolicy(p, gormRefs{tx})
+}
+
 func (p *Policy) BeforeUpdate(tx *gorm.DB) (err error) {
+	// Updates(struct) runs hooks on the model being updated; the new values
+	// live in the statement's destination.
+	next := p
+	switch d := tx.Statement.Dest.(type) {
+	case Policy:
+		next = &d
+	case *Policy:
+		next = d
+	}
+	refs := gormRefs{tx}
+	if tx.Statement.Changed("Resource", "MatchKind", "ExcludeResources") {
+		if err := policy.ValidateResource(next.MatchKind, next.Resource, next.ExcludeResources); err != nil {
+			return err
+		}
+	}
+	if tx.Statement.Changed("Actions") {
+		if err := validateActions(refs, next.Actions); err != nil {
+			return err
+		}
+	}
+	if tx.Statement.Changed("Provider", "Resource", "MatchKind", "Actions") {
+		if err := validateProvider(refs, next); err != nil {
+			return err
+		}
+	}
 	if tx.Statement.Changed("Expr") {
-		return policy.ValidateCEL(p.Expr)
+		return policy.ValidateCEL(next.Expr)
+	}
+	return nil
+}
+
+// gormRefs answers PolicyRefs from the database the hook runs against.
+type gormRefs struct{ tx *gorm.DB }
+
+func (r gormRefs) KnownActionGroups(names []string) ([]string, error) {
+	var found []string
+	err := r.tx.Session(&gorm.Session{NewDB: true}).Model(&ActionGroup{}).Where("name IN ?", names).Pluck("name", &found).Error
+	return found, err
+}
+
+func (r gormRefs) ProviderByName(name string) (*Provider, error) {
+	var prov Provider
+	err := r.tx.Session(&gorm.Session{NewDB: true}).First(&prov, "name = ?", name).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &prov, nil
+}
+
+// validateActions checks action syntax and that every referenced group exists.
+func validateActions(refs PolicyRefs, actions []string) error {
+	if err := policy.ValidateActions(actions); err != nil {
+		return err
+	}
+	names := policy.GroupRefs(actions)
+	if len(names) == 0 {
+		return nil
+	}
+	found, err := refs.KnownActionGroups(names)
+	if err != nil {
+		return err
+	}
+	known := map[string]bool{}
+	for _, n := range found {
+		known[n] = true
+	}
+	for _, n := range names {
+		if !known[n] {
+			return fmt.Errorf("unknown action group %q", n)
+		}
+	}
+	return nil
+}
+
+// validateProvider checks that the policy's provider is registered and that
+// its resource pattern and literal actions follow the provider's conventions.
+func validateProvider(refs PolicyRefs, p *Policy) error {
+	prov, err := refs.ProviderByName(p.Provider)
+	if err != nil {
+		return err
+	}
+	if prov == nil {
+		return fmt.Errorf("unknown provider %q", p.Provider)
+	}
+	if len(prov.ResourcePatterns) > 0 {
+		m, err := policy.CompileMatcher(p.MatchKind, p.Resource, nil)
+		if err != nil {
+			return err
+		}
+		if !policy.FollowsConvention(m.LiteralPrefix(), prov.ResourcePatterns) {
+			return fmt.Errorf("resource %q does not follow the conventions of provider %q (%s)", p.Resource, prov.Name, strings.Join(prov.ResourcePatterns, ", "))
+		}
+	}
+	if len(prov.Actions) > 0 {
+		allowed := (*policy.ActionCatalog)(nil).Expand(prov.Name, prov.Actions)
+		for _, a := range p.Actions {
+			if strings.HasPrefix(a, policy.GroupPrefix) || policy.IsGlob(a) {
+				continue
+			}
+			if _, ok := allowed.Match(a); !ok {
+				return fmt.Errorf("action %q is not supported by provider %q", a, prov.Name)
+			}
+		}
 	}
 	return nil
 }
@@ -9,27 +9,193 @@ import (
 )
 
 type Policy struct {
+	ID       uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	Name     string    `gorm:"not null" json:"name"`
+	Effect   string    `gorm:"not null" json:"effect"`
+	Provider string    `gorm:"not null;default:'global'" json:"provider"`
+	Resource string    `gorm:"not null" json:"resource"`
+	// MatchKind selects how Resource is matched: glob (default), exact, prefix or regex.
+	MatchKind string `gorm:"not null;default:'glob'" json:"match_kind"`
+	// ExcludeResources are globs carved out of Resource.
+	ExcludeResources pq.StringArray `gorm:"type:text[]" json:"exclude_resources"`
+	Actions          pq.StringArray `gorm:"type:text[]" json:"actions"`
+	Condition        datatypes.JSON `gorm:"type:jsonb" json:"condition"`
+	Expr             string         `gorm:"type:text" json:"expr"`
+	Metadata         datatypes.JSON `gorm:"type:jsonb" json:"metadata"`
+	Enabled          bool           `gorm:"default:true" json:"enabled"`
+	Priority         int            `gorm:"default:100" json:"priority"`
+	Version          int            `gorm:"default:1" json:"version"`
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+// Policy version operations.
+const (
+	VersionCreate = "create"
+	VersionUpdate = "update"
+	VersionDelete = "delete"
+	// VersionRestore is a write made by a rollback; RestoredFrom names the
+	// version it restores.
+	VersionRestore = "restore"
+)
+
+// PolicyVersion is an immutable record of one write to a policy. Policy holds
+// the policy as written, or as it was before a delete. RestoredFrom is set on
+// writes made by a rollback.
+type PolicyVersion struct {
+	ID           uuid.UUID      `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	PolicyID     uuid.UUID      `gorm:"type:uuid;not null;uniqueIndex:idx_policy_versions_policy_version" json:"policy_id"`
+	Version      int            `gorm:"not null;uniqueIndex:idx_policy_versions_policy_version" json:"version"`
+	Operation    string         `gorm:"not null" json:"operation"`
+	Policy       datatypes.JSON `gorm:"type:jsonb;not null" json:"policy"`
+	Author       string         `json:"author,omitempty"`
+	Reason       string         `json:"reason,omitempty"`
+	RestoredFrom *int           `json:"restored_from,omitempty"`
+	CreatedAt    time.Time      `json:"created_at"`
+}
+
+type PolicyAudit struct {
+	ID         uuid.UUID      `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	Request    datatypes.JSON `gorm:"type:jsonb"`
+	Decision   string
+	MatchedID  *uuid.UUID
+	Trace      datatypes.JSON `gorm:"type:jsonb"`
+	Severity   string         `gorm:"not null;default:'info'"`
+	BreakGlass bool           `gorm:"not null;default:false"`
+	// Providers are the provider policy sets the request was resolved to.
+	Providers pq.StringArray `gorm:"type:text[]"`
+	CreatedAt time.Time
+}
+
+// Delegation lets Delegator lend a subset of their own actions on Resource to
+// Delegate until ExpiresAt. DelegatorSubject is the delegator's subject
+// attributes, taken from their latest audited request when the delegation is
+// created and used to re-check the delegator's own access at evaluation time.
+type Delegation struct {
+	ID               uuid.UUID      `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	Delegator        string         `gorm:"not null;index" json:"delegator"`
+	DelegatorSubject datatypes.JSON `gorm:"type:jsonb" json:"delegator_subject"`
+	Delegate         string         `gorm:"not null;index" json:"delegate"`
+	Resource         string         `gorm:"not null" json:"resource"`
+	Actions          pq.StringArray `gorm:"type:text[]" json:"actions"`
+	Reason           string         `json:"reason"`
+	ExpiresAt        time.Time      `gorm:"not null" json:"expires_at"`
+	RevokedAt        *time.Time     `json:"revoked_at,omitempty"`
+	CreatedAt        time.Time      `json:"created_at"`
+}
+
+// SoDConstraint is a separation-of-duties rule enforced with the global layer.
+//
+// A "static" constraint denies subjects whose Attribute holds two or more of
+// Values at once. A "dynamic" constraint denies one of Actions when the same
+// subject was already allowed another of Actions within the same unit of work,
+// identified by metadata[ScopeKey] (e.g. a ticket ID). Resource optionally
+// limits where the constraint applies.
+type SoDConstraint struct {
 	ID        uuid.UUID      `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
 	Name      string         `gorm:"not null" json:"name"`
-	Effect    string         `gorm:"not null" json:"effect"`
-	Provider  string         `gorm:"not null;default:'global'" json:"provider"`
-	Resource  string         `gorm:"not null" json:"resource"`
-	Actions   pq.StringArray `gorm:"type:text[]" json:"actions"`
-	Condition datatypes.JSON `gorm:"type:jsonb" json:"condition"`
-	Expr      string         `gorm:"type:text" json:"expr"`
-	Metadata  datatypes.JSON `gorm:"type:jsonb" json:"metadata"`
+	Kind      string         `gorm:"not null" json:"kind"`
+	Attribute string         `json:"attribute,omitempty"`
+	Values    pq.StringArray `gorm:"type:text[]" json:"values,omitempty"`
+	Actions   pq.StringArray `gorm:"type:text[]" json:"actions,omitempty"`
+	ScopeKey  string         `json:"scope_key,omitempty"`
+	Resource  string         `json:"resource,omitempty"`
+	Message   string         `json:"message,omitempty"`
 	Enabled   bool           `gorm:"default:true" json:"enabled"`
-	Priority  int            `gorm:"default:100" json:"priority"`
-	Version   int            `gorm:"default:1" json:"version"`
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
 }
 
-type PolicyAudit struct {
-	ID        uuid.UUID      `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
-	Request   datatypes.JSON `gorm:"type:jsonb"`
-	Decision  string
-	MatchedID *uuid.UUID
-	Trace     datatypes.JSON `gorm:"type:jsonb"`
-	CreatedAt time.Time
+func (SoDConstraint) TableName() string { return "sod_constraints" }
+
+// Session is a long-lived connection (SSH, RDP, ...) that was allowed at connect
+// time and is re-evaluated whenever policies change.
+type Session struct {
+	ID        string         `gorm:"primaryKey" json:"id"`
+	SubjectID string         `gorm:"index" json:"subject_id"`
+	Resource  string         `gorm:"not null" json:"resource"`
+	Action    string         `gorm:"not null" json:"action"`
+	Request   datatypes.JSON `gorm:"type:jsonb;not null" json:"request"`
+	Status    string         `gorm:"not null;default:'active';index" json:"status"`
+	Reason    string         `json:"reason,omitempty"`
+	RevokedAt *time.Time     `json:"revoked_at,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+// ActionGroup is a named set of actions that policies reference as "@name".
+type ActionGroup struct {
+	Name        string         `gorm:"primaryKey" json:"name"`
+	Actions     pq.StringArray `gorm:"type:text[];not null" json:"actions"`
+	Description string         `json:"description,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+}
+
+// ActionHierarchy declares that, for Provider, holding Action implies Implies
+// (e.g. admin implies write). Implications are transitive.
+type ActionHierarchy struct {
+	ID        uuid.UUID      `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	Provider  string         `gorm:"not null;uniqueIndex:idx_action_hierarchy" json:"provider"`
+	Action    string         `gorm:"not null;uniqueIndex:idx_action_hierarchy" json:"action"`
+	Implies   pq.StringArray `gorm:"type:text[];not null" json:"implies"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+// Provider is a registered policy provider. Requests name it (or one of its
+// Aliases) in cloud or protocol; policies are filed under Name.
+type Provider struct {
+	Name    string         `gorm:"primaryKey" json:"name"`
+	Aliases pq.StringArray `gorm:"type:text[]" json:"aliases"`
+	// ResourcePatterns are the globs resources of this provider follow,
+	// e.g. "ssh:unix:host/*". Empty accepts any resource.
+	ResourcePatterns pq.StringArray `gorm:"type:text[]" json:"resource_patterns"`
+	// Actions lists the actions (literals or globs) the provider supports.
+	// Empty accepts any action.
+	Actions         pq.StringArray `gorm:"type:text[]" json:"actions"`
+	DefaultDecision string         `gorm:"not null;default:'deny'" json:"default_decision"`
+	// FailClosed overrides the engine's FAIL_CLOSED setting when set.
+	FailClosed    *bool          `json:"fail_closed,omitempty"`
+	RequestSchema datatypes.JSON `gorm:"type:jsonb" json:"request_schema,omitempty"`
+	Description   string         `json:"description,omitempty"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+}
+
+// ResolutionRule routes matching requests to one or more providers. Rules are
+// tried in Priority order and the first whose Match globs and Expr both hold
+// decides; Match maps request fields ("cloud", "metadata.env") to globs.
+// Providers may name "$cloud", "$protocol" or "$platform" to use that request
+// field. Combine is how the providers' decisions are joined: all, any or first.
+type ResolutionRule struct {
+	ID        uuid.UUID         `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	Name      string            `gorm:"not null" json:"name"`
+	Priority  int               `gorm:"default:100" json:"priority"`
+	Match     datatypes.JSONMap `gorm:"type:jsonb" json:"match,omitempty"`
+	Expr      string            `gorm:"type:text" json:"expr,omitempty"`
+	Providers pq.StringArray    `gorm:"type:text[];not null" json:"providers"`
+	Combine   string            `gorm:"not null;default:'all'" json:"combine"`
+	Enabled   bool              `gorm:"default:true" json:"enabled"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// PolicyTestCase is a stored regression test: Request must keep yielding
+// ExpectDecision (and, when set, the ExpectMatched policy and a reason
+// containing ExpectReason). Policy writes that break a passing case are
+// rejected. A case belongs to a policy, and is deleted with it, or to a
+// provider.
+type PolicyTestCase struct {
+	ID             uuid.UUID      `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	Name           string         `gorm:"not null" json:"name"`
+	PolicyID       *uuid.UUID     `gorm:"type:uuid;index" json:"policy_id,omitempty"`
+	Provider       string         `gorm:"index" json:"provider,omitempty"`
+	Request        datatypes.JSON `gorm:"type:jsonb;not null" json:"request"`
+	ExpectDecision string         `gorm:"not null" json:"expect_decision"`
+	ExpectMatched  string         `json:"expect_matched,omitempty"`
+	ExpectReason   string         `json:"expect_reason,omitempty"`
+	Enabled        bool           `gorm:"default:true" json:"enabled"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
 }
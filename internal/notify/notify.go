@@ -0,0 +1,101 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Event is a security-relevant occurrence that should reach humans quickly,
+// such as a break-glass access request.
+type Event struct {
+	Kind     string         `json:"kind"`
+	Severity string         `json:"severity"`
+	AuditID  uuid.UUID      `json:"audit_id"`
+	Time     time.Time      `json:"time"`
+	Subject  map[string]any `json:"subject"`
+	Resource string         `json:"resource"`
+	Action   string         `json:"action"`
+	Decision string         `json:"decision"`
+	Reason   string         `json:"reason"`
+	Details  any            `json:"details,omitempty"`
+}
+
+type Notifier interface {
+	Notify(ctx context.Context, ev Event) error
+}
+
+// Webhook POSTs each event as JSON to URL.
+type Webhook struct {
+	URL    string
+	Client *http.Client
+}
+
+func (w *Webhook) Notify(ctx context.Context, ev Event) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s returned %s", w.URL, resp.Status)
+	}
+	return nil
+}
+
+// FileSink appends each event as a JSON line to Path.
+type FileSink struct {
+	Path string
+	mu   sync.Mutex
+}
+
+func (f *FileSink) Notify(_ context.Context, ev Event) error {
+	line, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fh, err := os.OpenFile(f.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+	_, err = fh.Write(append(line, '\n'))
+	return err
+}
+
+// Dispatch delivers ev to every notifier in the background so callers on the
+// request path are never blocked by a slow sink. Failures are logged.
+func Dispatch(notifiers []Notifier, ev Event) {
+	for _, n := range notifiers {
+		go func(n Notifier) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if err := n.Notify(ctx, ev); err != nil {
+				log.Printf("notify %s event %s: %v", ev.Kind, ev.AuditID, err)
+			}
+		}(n)
+	}
+}
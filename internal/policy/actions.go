@@ -0,0 +1,145 @@
+package policy
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gobwas/glob"
+)
+
+// GroupPrefix marks a policy action entry that refers to a named action group, e.g. "@readonly".
+const GroupPrefix = "@"
+
+// ActionCatalog holds the named action groups and per-provider action
+// hierarchies used to expand policy action entries.
+type ActionCatalog struct {
+	// Groups maps a group name to its member actions (literals or globs).
+	Groups map[string][]string
+	// Implies maps provider -> action -> actions it directly implies,
+	// e.g. ssh: admin -> [write], write -> [read].
+	Implies map[string]map[string][]string
+}
+
+// ActionSet is the expansion of a policy's action entries.
+type ActionSet struct {
+	// Any is true when the policy lists no actions and so applies to all.
+	Any bool
+	// Exact maps each concrete action to the entry that granted it.
+	Exact map[string]string
+	Globs []ActionGlob
+}
+
+type ActionGlob struct {
+	Pattern string
+	Via     string
+	g       glob.Glob
+}
+
+// IsGlob reports whether an action entry is a pattern rather than a literal.
+func IsGlob(s string) bool { return strings.ContainsAny(s, `*?[]{}\!`) }
+
+// ValidateActions checks the syntax of policy action entries.
+func ValidateActions(entries []string) error {
+	for _, a := range entries {
+		switch {
+		case a == "":
+			return fmt.Errorf("action must not be empty")
+		case strings.HasPrefix(a, GroupPrefix):
+			if len(a) == len(GroupPrefix) {
+				return fmt.Errorf("action group reference %q has no name", a)
+			}
+		case IsGlob(a):
+			if _, err := glob.Compile(a); err != nil {
+				return fmt.Errorf("invalid action pattern %q: %w", a, err)
+			}
+		}
+	}
+	return nil
+}
+
+// GroupRefs returns the group names referenced by entries.
+func GroupRefs(entries []string) []string {
+	var out []string
+	for _, a := range entries {
+		if strings.HasPrefix(a, GroupPrefix) {
+			out = append(out, strings.TrimPrefix(a, GroupPrefix))
+		}
+	}
+	return out
+}
+
+// Expand resolves entries for a policy of provider: groups are replaced by
+// their members, and every concrete action pulls in what it implies under the
+// provider's hierarchy. Unknown groups expand to nothing.
+func (c *ActionCatalog) Expand(provider string, entries []string) ActionSet {
+	set := ActionSet{Any: len(entries) == 0, Exact: map[string]string{}}
+	add := func(action, via string) {
+		if IsGlob(action) {
+			if g, err := glob.Compile(action); err == nil {
+				set.Globs = append(set.Globs, ActionGlob{Pattern: action, Via: via, g: g})
+			}
+			return
+		}
+		c.addImplied(&set, provider, action, via)
+	}
+	for _, e := range entries {
+		if name, ok := strings.CutPrefix(e, GroupPrefix); ok {
+			if c != nil {
+				for _, m := range c.Groups[name] {
+					add(m, e)
+				}
+			}
+			continue
+		}
+		add(e, e)
+	}
+	return set
+}
+
+// addImplied records action and everything it implies, breadth first, keeping
+// the first (shortest) path as the explanation.
+func (c *ActionCatalog) addImplied(set *ActionSet, provider, action, via string) {
+	type step struct{ action, via string }
+	queue := []step{{action, via}}
+	for len(queue) > 0 {
+		s := queue[0]
+		queue = queue[1:]
+		if _, seen := set.Exact[s.action]; seen {
+			continue
+		}
+		set.Exact[s.action] = s.via
+		if c == nil {
+			continue
+		}
+		for _, next := range c.Implies[provider][s.action] {
+			queue = append(queue, step{next, s.via + " -> " + next})
+		}
+	}
+}
+
+// Match reports whether action is covered, and by which entry.
+func (s ActionSet) Match(action string) (via string, ok bool) {
+	if s.Any || action == "" {
+		return "", true
+	}
+	if via, ok := s.Exact[action]; ok {
+		return via, true
+	}
+	for _, g := range s.Globs {
+		if g.g.Match(action) {
+			return g.Via, true
+		}
+	}
+	return "", false
+}
+
+// Actions lists the concrete actions in the set, sorted.
+func (s ActionSet) Actions() []string {
+	out := make([]string, 0, len(s.Exact))
+	for a := range s.Exact {
+		out = append(out, a)
+	}
+	sort.Strings(out)
+	return out
+}
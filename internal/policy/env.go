@@ -0,0 +1,25 @@
+package policy
+
+import (
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker/decls"
+)
+
+// RequestVars are the variables policy expressions are evaluated with.
+var RequestVars = []string{"subject", "resource", "action", "metadata", "protocol", "platform", "cloud"}
+
+// NewEnv returns a CEL environment declaring RequestVars, for analysing
+// policy expressions outside the engine.
+func NewEnv() (*cel.Env, error) {
+	return cel.NewEnv(
+		cel.Declarations(
+			decls.NewVar("subject", decls.NewMapType(decls.String, decls.Dyn)),
+			decls.NewVar("resource", decls.String),
+			decls.NewVar("action", decls.String),
+			decls.NewVar("metadata", decls.NewMapType(decls.String, decls.Dyn)),
+			decls.NewVar("protocol", decls.String),
+			decls.NewVar("platform", decls.String),
+			decls.NewVar("cloud", decls.String),
+		),
+	)
+}
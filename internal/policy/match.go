@@ -0,0 +1,197 @@
+package policy
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/gobwas/glob"
+)
+
+// Resource matcher kinds selectable per policy.
+const (
+	MatchGlob   = "glob"
+	MatchExact  = "exact"
+	MatchPrefix = "prefix"
+	MatchRegex  = "regex"
+)
+
+// Matcher decides whether a policy's resource pattern covers a resource.
+type Matcher interface {
+	Match(resource string) bool
+	// LiteralPrefix is a prefix every matching resource starts with; empty
+	// when nothing useful is known. Used to index patterns.
+	LiteralPrefix() string
+}
+
+// matcherCacheSize bounds the matchers CompileMatcher keeps. Patterns come
+// from stored policies, delegations and SoD constraints, which churn, so
+// the cache starts over when it fills rather than growing with every
+// pattern ever seen.
+const matcherCacheSize = 4096
+
+var matcherCache = struct {
+	sync.Mutex
+	m map[string]Matcher
+}{m: map[string]Matcher{}}
+
+// CompileMatcher builds the matcher for pattern of the given kind ("" means
+// glob). Resources matching any exclude glob are rejected. Results are cached.
+func CompileMatcher(kind, pattern string, exclude []string) (Matcher, error) {
+	key := kind + "\x00" + pattern + "\x00" + strings.Join(exclude, "\x00")
+	matcherCache.Lock()
+	m, ok := matcherCache.m[key]
+	matcherCache.Unlock()
+	if ok {
+		return m, nil
+	}
+	m, err := compileMatcher(kind, pattern, exclude)
+	if err != nil {
+		return nil, err
+	}
+	matcherCache.Lock()
+	if len(matcherCache.m) >= matcherCacheSize {
+		clear(matcherCache.m)
+	}
+	matcherCache.m[key] = m
+	matcherCache.Unlock()
+	return m, nil
+}
+
+// ValidateResource reports whether the resource pattern can be compiled.
+func ValidateResource(kind, pattern string, exclude []string) error {
+	_, err := compileMatcher(kind, pattern, exclude)
+	return err
+}
+
+func compileMatcher(kind, pattern string, exclude []string) (Matcher, error) {
+	var base Matcher
+	switch kind {
+	case "", MatchGlob:
+		if pattern == "" || pattern == "*" {
+			base = anyMatcher{}
+			break
+		}
+		g, err := glob.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid glob resource pattern %q: %w", pattern, err)
+		}
+		base = globMatcher{g: g, prefix: globLiteralPrefix(pattern)}
+	case MatchExact:
+		if pattern == "" {
+			return nil, fmt.Errorf("exact resource pattern must not be empty")
+		}
+		base = exactMatcher(pattern)
+	case MatchPrefix:
+		base = prefixMatcher(pattern)
+	case MatchRegex:
+		re, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex resource pattern %q: %w", pattern, err)
+		}
+		prefix, _ := re.LiteralPrefix()
+		base = regexMatcher{re: re, prefix: prefix}
+	default:
+		return nil, fmt.Errorf("unknown match kind %q (want glob, exact, prefix or regex)", kind)
+	}
+	if len(exclude) == 0 {
+		return base, nil
+	}
+	ex := make([]glob.Glob, 0, len(exclude))
+	for _, p := range exclude {
+		g, err := glob.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exclude pattern %q: %w", p, err)
+		}
+		ex = append(ex, g)
+	}
+	return excludingMatcher{Matcher: base, exclude: ex}, nil
+}
+
+// globLiteralPrefix returns the part of a glob pattern before the first meta character.
+func globLiteralPrefix(pattern string) string {
+	if i := strings.IndexAny(pattern, `*?[]{}\!`); i >= 0 {
+		return pattern[:i]
+	}
+	return pattern
+}
+
+type anyMatcher struct{}
+
+func (anyMatcher) Match(string) bool     { return true }
+func (anyMatcher) LiteralPrefix() string { return "" }
+
+type globMatcher struct {
+	g      glob.Glob
+	prefix string
+}
+
+func (m globMatcher) Match(r string) bool   { return m.g.Match(r) }
+func (m globMatcher) LiteralPrefix() string { return m.prefix }
+
+type exactMatcher string
+
+func (m exactMatcher) Match(r string) bool   { return string(m) == r }
+func (m exactMatcher) LiteralPrefix() string { return string(m) }
+
+type prefixMatcher string
+
+func (m prefixMatcher) Match(r string) bool   { return strings.HasPrefix(r, string(m)) }
+func (m prefixMatcher) LiteralPrefix() string { return string(m) }
+
+type regexMatcher struct {
+	re     *regexp.Regexp
+	prefix string
+}
+
+func (m regexMatcher) Match(r string) bool   { return m.re.MatchString(r) }
+func (m regexMatcher) LiteralPrefix() string { return m.prefix }
+
+type excludingMatcher struct {
+	Matcher
+	exclude []glob.Glob
+}
+
+func (m excludingMatcher) Match(r string) bool {
+	if !m.Matcher.Match(r) {
+		return false
+	}
+	for _, g := range m.exclude {
+		if g.Match(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// Specificity ranks resource patterns for evaluation order: longer patterns
+// with fewer wildcards are more specific.
+func Specificity(pattern string) int {
+	if pattern == "" {
+		return 0
+	}
+	wildcards := 0
+	for _, r := range pattern {
+		if r == '*' || r == '?' {
+			wildcards++
+		}
+	}
+	return len(pattern) - (wildcards * 10)
+}
+
+// FollowsConvention reports whether resources starting with prefix can match
+// one of a provider's resource patterns, comparing literal prefixes.
+func FollowsConvention(prefix string, patterns []string) bool {
+	for _, pat := range patterns {
+		m, err := CompileMatcher(MatchGlob, pat, nil)
+		if err != nil {
+			continue
+		}
+		cp := m.LiteralPrefix()
+		if strings.HasPrefix(prefix, cp) || strings.HasPrefix(cp, prefix) {
+			return true
+		}
+	}
+	return false
+}
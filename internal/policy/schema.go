@@ -0,0 +1,141 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Schema is the subset of JSON Schema providers use to describe the requests
+// they accept: type, required, properties, enum and items.
+type Schema struct {
+	Type       string             `json:"type,omitempty"`
+	Required   []string           `json:"required,omitempty"`
+	Properties map[string]*Schema `json:"properties,omitempty"`
+	Enum       []any              `json:"enum,omitempty"`
+	Items      *Schema            `json:"items,omitempty"`
+}
+
+// ParseSchema decodes and checks a request schema. Empty input yields nil,
+// which accepts every request.
+func ParseSchema(raw []byte) (*Schema, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var s Schema
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return nil, fmt.Errorf("invalid request schema: %w", err)
+	}
+	if err := s.check(""); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (s *Schema) check(path string) error {
+	switch s.Type {
+	case "", "object", "string", "number", "integer", "boolean", "array":
+	default:
+		return fmt.Errorf("invalid request schema: %s: unknown type %q", pathOrRoot(path), s.Type)
+	}
+	for name, p := range s.Properties {
+		if p == nil {
+			return fmt.Errorf("invalid request schema: %s.%s: empty schema", pathOrRoot(path), name)
+		}
+		if err := p.check(path + "." + name); err != nil {
+			return err
+		}
+	}
+	if s.Items != nil {
+		return s.Items.check(path + "[]")
+	}
+	return nil
+}
+
+// Validate checks v, a value decoded from JSON, against s and describes the
+// first violation found. A nil schema accepts everything.
+func (s *Schema) Validate(v any) error {
+	if s == nil {
+		return nil
+	}
+	return s.validate("", v)
+}
+
+func (s *Schema) validate(path string, v any) error {
+	if s.Type != "" && !hasType(s.Type, v) {
+		return fmt.Errorf("%s must be of type %s", pathOrRoot(path), s.Type)
+	}
+	if len(s.Enum) > 0 {
+		ok := false
+		for _, e := range s.Enum {
+			if fmt.Sprint(e) == fmt.Sprint(v) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return fmt.Errorf("%s must be one of %v", pathOrRoot(path), s.Enum)
+		}
+	}
+	switch x := v.(type) {
+	case map[string]any:
+		for _, name := range s.Required {
+			if val, ok := x[name]; !ok || val == nil || val == "" {
+				return fmt.Errorf("%s is required", strings.TrimPrefix(path+"."+name, "."))
+			}
+		}
+		names := make([]string, 0, len(s.Properties))
+		for name := range s.Properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if val, ok := x[name]; ok && val != nil {
+				if err := s.Properties[name].validate(strings.TrimPrefix(path+"."+name, "."), val); err != nil {
+					return err
+				}
+			}
+		}
+	case []any:
+		if s.Items != nil {
+			for i, item := range x {
+				if err := s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func hasType(t string, v any) bool {
+	switch t {
+	case "object":
+		_, ok := v.(map[string]any)
+		return ok
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "number":
+		_, ok := v.(float64)
+		return ok
+	case "integer":
+		f, ok := v.(float64)
+		return ok && f == float64(int64(f))
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "array":
+		_, ok := v.([]any)
+		return ok
+	}
+	return true
+}
+
+func pathOrRoot(path string) string {
+	if path == "" {
+		return "request"
+	}
+	return strings.TrimPrefix(path, ".")
+}
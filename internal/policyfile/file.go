@@ -0,0 +1,182 @@
+// Package policyfile reads declarative policy files and syncs them to a store.
+//
+// A file groups policies by provider and identifies each by a name that is
+// unique within the file:
+//
+//	policies:
+//	  ssh:
+//	    - name: ops-ssh
+//	      effect: allow
+//	      resource: "ssh:unix:host/*"
+//	      actions: [login]
+//	      expr: subject.group == "ops"
+//
+// JSON files use the same keys.
+package policyfile
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+
+	"example.com/jit-engine/internal/model"
+	"example.com/jit-engine/internal/policy"
+)
+
+// File is a parsed policy file.
+type File struct {
+	// Policies maps provider name to the policies it holds.
+	Policies map[string][]Policy `yaml:"policies" json:"policies"`
+}
+
+// Policy is one policy as written in a file. Omitted Enabled and Priority
+// take the API defaults (true and 100).
+type Policy struct {
+	Name             string         `yaml:"name" json:"name"`
+	Effect           string         `yaml:"effect" json:"effect"`
+	Resource         string         `yaml:"resource" json:"resource"`
+	MatchKind        string         `yaml:"match_kind,omitempty" json:"match_kind,omitempty"`
+	ExcludeResources []string       `yaml:"exclude_resources,omitempty" json:"exclude_resources,omitempty"`
+	Actions          []string       `yaml:"actions,omitempty" json:"actions,omitempty"`
+	Expr             string         `yaml:"expr" json:"expr"`
+	Metadata         map[string]any `yaml:"metadata,omitempty" json:"metadata,omitempty"`
+	Enabled          *bool          `yaml:"enabled,omitempty" json:"enabled,omitempty"`
+	Priority         *int           `yaml:"priority,omitempty" json:"priority,omitempty"`
+}
+
+// Parse reads a YAML or JSON policy file.
+func Parse(data []byte) (*File, error) {
+	var f File
+	// JSON is valid YAML, so one decoder serves both.
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("policy file: %w", err)
+	}
+	return &f, nil
+}
+
+// Providers returns the providers the file declares, sorted.
+func (f *File) Providers() []string {
+	out := make([]string, 0, len(f.Policies))
+	for p := range f.Policies {
+		out = append(out, p)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Validate reports every problem found in the file: missing or duplicate
+// names, bad effects, resource patterns and actions, and CEL expressions
+// rejected by policy.ValidateCEL.
+func (f *File) Validate() []string {
+	var errs []string
+	seen := map[string]string{}
+	for _, provider := range f.Providers() {
+		for i, p := range f.Policies[provider] {
+			where := fmt.Sprintf("%s[%d]", provider, i)
+			if p.Name == "" {
+				errs = append(errs, where+": name is required")
+			} else {
+				where = fmt.Sprintf("%s/%s", provider, p.Name)
+				if prev, dup := seen[p.Name]; dup {
+					errs = append(errs, fmt.Sprintf("%s: name already used by %s/%s", where, prev, p.Name))
+				}
+				seen[p.Name] = provider
+			}
+			if p.Effect != "allow" && p.Effect != "deny" {
+				errs = append(errs, where+": effect must be allow or deny")
+			}
+			kind := p.MatchKind
+			if kind == "" {
+				kind = policy.MatchGlob
+			}
+			if err := policy.ValidateResource(kind, p.Resource, p.ExcludeResources); err != nil {
+				errs = append(errs, fmt.Sprintf("%s: resource: %v", where, err))
+			}
+			if err := policy.ValidateActions(p.Actions); err != nil {
+				errs = append(errs, fmt.Sprintf("%s: actions: %v", where, err))
+			}
+			if err := policy.ValidateCEL(p.Expr); err != nil {
+				errs = append(errs, fmt.Sprintf("%s: expr: %v", where, err))
+			}
+		}
+	}
+	return errs
+}
+
+// model converts p, filed under provider, to the stored form.
+func (p Policy) model(provider string) model.Policy {
+	m := model.Policy{
+		Name:             p.Name,
+		Effect:           p.Effect,
+		Provider:         provider,
+		Resource:         p.Resource,
+		MatchKind:        p.MatchKind,
+		ExcludeResources: p.ExcludeResources,
+		Actions:          p.Actions,
+		Expr:             p.Expr,
+		Enabled:          true,
+		Priority:         100,
+	}
+	if m.MatchKind == "" {
+		m.MatchKind = policy.MatchGlob
+	}
+	if p.Enabled != nil {
+		m.Enabled = *p.Enabled
+	}
+	if p.Priority != nil {
+		m.Priority = *p.Priority
+	}
+	if len(p.Metadata) > 0 {
+		m.Metadata, _ = json.Marshal(p.Metadata)
+	}
+	return m
+}
+
+// FromPolicies builds the file describing ps, omitting values equal to the
+// defaults.
+func FromPolicies(ps []model.Policy) *File {
+	f := &File{Policies: map[string][]Policy{}}
+	for _, m := range ps {
+		p := Policy{
+			Name:             m.Name,
+			Effect:           m.Effect,
+			Resource:         m.Resource,
+			ExcludeResources: m.ExcludeResources,
+			Actions:          m.Actions,
+			Expr:             m.Expr,
+		}
+		if m.MatchKind != policy.MatchGlob {
+			p.MatchKind = m.MatchKind
+		}
+		if !m.Enabled {
+			enabled := false
+			p.Enabled = &enabled
+		}
+		if m.Priority != 100 {
+			priority := m.Priority
+			p.Priority = &priority
+		}
+		if len(m.Metadata) > 0 {
+			_ = json.Unmarshal(m.Metadata, &p.Metadata)
+		}
+		f.Policies[m.Provider] = append(f.Policies[m.Provider], p)
+	}
+	for _, list := range f.Policies {
+		sort.SliceStable(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	}
+	return f
+}
+
+// ToPolicies returns the file's policies in stored form, filed under the
+// provider names as written.
+func (f *File) ToPolicies() []model.Policy {
+	var out []model.Policy
+	for _, provider := range f.Providers() {
+		for _, p := range f.Policies[provider] {
+			out = append(out, p.model(provider))
+		}
+	}
+	return out
+}
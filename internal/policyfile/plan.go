@@ -0,0 +1,262 @@
+package policyfile
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"example.com/jit-engine/internal/model"
+	"example.com/jit-engine/internal/store"
+)
+
+// Change operations.
+const (
+	OpCreate = "create"
+	OpUpdate = "update"
+	OpDelete = "delete"
+)
+
+// Change is one step of a plan.
+type Change struct {
+	Op       string     `json:"op"`
+	Name     string     `json:"name"`
+	Provider string     `json:"provider"`
+	ID       *uuid.UUID `json:"id,omitempty"`
+	// OldProvider is set when an update moves a policy between providers.
+	OldProvider string `json:"old_provider,omitempty"`
+	// Fields lists what an update changes.
+	Fields []string `json:"fields,omitempty"`
+	// Version is the stored version an update or delete is based on. The
+	// write fails if the policy is no longer at it.
+	Version int `json:"version,omitempty"`
+
+	desired model.Policy
+}
+
+// Plan is the diff between a policy file and a store. Errors make it
+// impossible to apply.
+type Plan struct {
+	Changes []Change `json:"changes"`
+	Errors  []string `json:"errors,omitempty"`
+	// Tag identifies the changes and the versions they are based on, so an
+	// apply can check that it makes the plan that was reviewed.
+	Tag string `json:"tag"`
+}
+
+// ErrInvalid is returned by Apply when the plan has errors.
+var ErrInvalid = errors.New("policy file is invalid")
+
+// NewPlan compares f with the policies in s by name. The file is
+// authoritative for the providers it lists: their policies missing from the
+// file are deleted. Policies of other providers are left alone unless the file
+// moves one of them by name.
+func NewPlan(f *File, s store.PolicyStore) (*Plan, error) {
+	plan := &Plan{Changes: []Change{}, Errors: f.Validate()}
+	existing, err := s.ListPolicies(store.PolicyFilter{})
+	if err != nil {
+		return nil, err
+	}
+	byName := map[string]model.Policy{}
+	dup := map[string]bool{}
+	for _, p := range existing {
+		if _, ok := byName[p.Name]; ok {
+			dup[p.Name] = true
+		}
+		byName[p.Name] = p
+	}
+	managed := map[string]bool{}
+	wanted := map[string]bool{}
+	for _, provider := range f.Providers() {
+		prov, err := s.ResolveProvider(provider)
+		if errors.Is(err, store.ErrNotFound) {
+			plan.Errors = append(plan.Errors, fmt.Sprintf("%s: unknown provider", provider))
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		managed[prov.Name] = true
+		for _, fp := range f.Policies[provider] {
+			wanted[fp.Name] = true
+			desired := fp.model(prov.Name)
+			if dup[fp.Name] {
+				plan.Errors = append(plan.Errors, fmt.Sprintf("%s/%s: several stored policies have this name", provider, fp.Name))
+				continue
+			}
+			cur, ok := byName[fp.Name]
+			if !ok {
+				plan.Changes = append(plan.Changes, Change{Op: OpCreate, Name: fp.Name, Provider: prov.Name, desired: desired})
+				continue
+			}
+			fields := diff(cur, desired)
+			if len(fields) == 0 {
+				continue
+			}
+			desired.ID, desired.Version, desired.Condition = cur.ID, cur.Version, cur.Condition
+			c := Change{Op: OpUpdate, Name: fp.Name, Provider: prov.Name, ID: &cur.ID, Fields: fields, Version: cur.Version, desired: desired}
+			if cur.Provider != prov.Name {
+				c.OldProvider = cur.Provider
+			}
+			plan.Changes = append(plan.Changes, c)
+		}
+	}
+	for _, p := range existing {
+		if managed[p.Provider] && !wanted[p.Name] {
+			id := p.ID
+			plan.Changes = append(plan.Changes, Change{Op: OpDelete, Name: p.Name, Provider: p.Provider, ID: &id, Version: p.Version})
+		}
+	}
+	sort.SliceStable(plan.Changes, func(i, j int) bool {
+		a, b := plan.Changes[i], plan.Changes[j]
+		if a.Provider != b.Provider {
+			return a.Provider < b.Provider
+		}
+		return a.Name < b.Name
+	})
+	plan.Tag = plan.tag()
+	return plan, nil
+}
+
+// tag hashes the changes as planned.
+func (p *Plan) tag() string {
+	data, _ := json.Marshal(p.Changes)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:16])
+}
+
+// Deleted returns the IDs of the policies the plan deletes.
+func (p *Plan) Deleted() []uuid.UUID {
+	var ids []uuid.UUID
+	for _, c := range p.Changes {
+		if c.Op == OpDelete {
+			ids = append(ids, *c.ID)
+		}
+	}
+	return ids
+}
+
+// diff lists the fields of cur that differ from desired.
+func diff(cur, desired model.Policy) []string {
+	var out []string
+	add := func(field string, changed bool) {
+		if changed {
+			out = append(out, field)
+		}
+	}
+	add("provider", cur.Provider != desired.Provider)
+	add("effect", cur.Effect != desired.Effect)
+	add("resource", cur.Resource != desired.Resource)
+	add("match_kind", cur.MatchKind != desired.MatchKind)
+	add("exclude_resources", !sameStrings(cur.ExcludeResources, desired.ExcludeResources))
+	add("actions", !sameStrings(cur.Actions, desired.Actions))
+	add("expr", cur.Expr != desired.Expr)
+	add("metadata", !sameJSON(cur.Metadata, desired.Metadata))
+	add("enabled", cur.Enabled != desired.Enabled)
+	add("priority", cur.Priority != desired.Priority)
+	return out
+}
+
+func sameStrings(a, b []string) bool {
+	if len(a) == 0 && len(b) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func sameJSON(a, b []byte) bool {
+	var x, y any
+	_ = json.Unmarshal(a, &x)
+	_ = json.Unmarshal(b, &y)
+	return reflect.DeepEqual(x, y)
+}
+
+// Apply plans f against s and applies the plan in one transaction, recording
+// change. Nothing is written when the plan has errors or any write fails.
+func Apply(f *File, s store.PolicyStore, change store.Change) (*Plan, error) {
+	tx, ok := s.(store.Transactor)
+	if !ok {
+		return nil, errors.New("store does not support transactions")
+	}
+	var plan *Plan
+	err := tx.Transaction(func(s store.PolicyStore) error {
+		var err error
+		if plan, err = NewPlan(f, s); err != nil {
+			return err
+		}
+		_, err = plan.Write(s, change)
+		return err
+	})
+	return plan, err
+}
+
+// Write makes the plan's changes in s, recording change, and returns the
+// policies created or updated as stored. Updates and deletes fail with
+// store.ErrVersionConflict if the policy is no longer at the version the
+// plan read. Call it in a transaction: a failed write leaves the earlier
+// ones in place.
+func (p *Plan) Write(s store.PolicyStore, change store.Change) ([]model.Policy, error) {
+	if len(p.Errors) > 0 {
+		return nil, ErrInvalid
+	}
+	var written []model.Policy
+	for i := range p.Changes {
+		c := &p.Changes[i]
+		w, err := write(s, c, change)
+		if err != nil {
+			return nil, fmt.Errorf("%s %s/%s: %w", c.Op, c.Provider, c.Name, err)
+		}
+		if c.Op != OpDelete {
+			written = append(written, w)
+		}
+	}
+	return written, nil
+}
+
+func write(s store.PolicyStore, c *Change, change store.Change) (model.Policy, error) {
+	change.IfVersion = c.Version
+	s = s.WithChange(change)
+	p := c.desired
+	switch c.Op {
+	case OpCreate:
+		err := store.CreatePolicyAsIs(s, &p)
+		if p.ID != uuid.Nil {
+			c.ID = &p.ID
+		}
+		return p, err
+	case OpUpdate:
+		return p, s.UpdatePolicy(&p)
+	default:
+		return p, s.DeletePolicy(*c.ID)
+	}
+}
+
+// String renders the plan for people.
+func (p *Plan) String() string {
+	var b strings.Builder
+	for _, e := range p.Errors {
+		fmt.Fprintf(&b, "! %s\n", e)
+	}
+	for _, c := range p.Changes {
+		switch c.Op {
+		case OpCreate:
+			fmt.Fprintf(&b, "+ create %s/%s\n", c.Provider, c.Name)
+		case OpUpdate:
+			fmt.Fprintf(&b, "~ update %s/%s (%s)\n", c.Provider, c.Name, strings.Join(c.Fields, ", "))
+		case OpDelete:
+			fmt.Fprintf(&b, "- delete %s/%s\n", c.Provider, c.Name)
+		}
+	}
+	if len(p.Changes) == 0 && len(p.Errors) == 0 {
+		b.WriteString("no changes\n")
+	} else if len(p.Errors) == 0 && p.Tag != "" {
+		fmt.Fprintf(&b, "plan %s\n", p.Tag)
+	}
+	return b.String()
+}
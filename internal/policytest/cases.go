@@ -0,0 +1,219 @@
+// Package policytest checks that policies produce expected decisions.
+//
+// A test file lists requests and what they must yield:
+//
+//	tests:
+//	  - name: ops may log in
+//	    request:
+//	      subject: {id: alice, group: ops}
+//	      resource: ssh:unix:host/web1
+//	      action: login
+//	      protocol: ssh
+//	    expect:
+//	      decision: allow
+//	      matched: ssh/ops-ssh   # provider/name, name or policy ID
+//	      reason: ops-ssh        # substring of the reason
+//
+// Files are YAML or JSON. In a directory, test files are those named
+// *_test.yaml, *_test.yml or *_test.json; everything else is a policy file.
+package policytest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+
+	"example.com/jit-engine/internal/eval"
+	"example.com/jit-engine/internal/policyfile"
+	"example.com/jit-engine/internal/store"
+)
+
+// Suite is one test file.
+type Suite struct {
+	Path  string `yaml:"-" json:"-"`
+	Tests []Case `yaml:"tests" json:"tests"`
+}
+
+// Case is one request and its expected outcome.
+type Case struct {
+	// ID is set for cases stored in the database.
+	ID      *uuid.UUID   `yaml:"-" json:"id,omitempty"`
+	Name    string       `yaml:"name" json:"name"`
+	Request eval.Request `yaml:"request" json:"request"`
+	Expect  Expectation  `yaml:"expect" json:"expect"`
+}
+
+// UnmarshalYAML reads the request with its JSON field names, so a request
+// body sent to /evaluate can be pasted as is.
+func (c *Case) UnmarshalYAML(n *yaml.Node) error {
+	var raw struct {
+		Name    string         `yaml:"name"`
+		Request map[string]any `yaml:"request"`
+		Expect  Expectation    `yaml:"expect"`
+	}
+	if err := n.Decode(&raw); err != nil {
+		return err
+	}
+	b, err := json.Marshal(raw.Request)
+	if err != nil {
+		return fmt.Errorf("%s: request: %w", raw.Name, err)
+	}
+	c.Name, c.Expect, c.Request = raw.Name, raw.Expect, eval.Request{}
+	if err := json.Unmarshal(b, &c.Request); err != nil {
+		return fmt.Errorf("%s: request: %w", raw.Name, err)
+	}
+	return nil
+}
+
+// Expectation is what a case asserts. Empty Matched and Reason are not
+// checked; Matched "none" asserts that no policy matched.
+type Expectation struct {
+	Decision string `yaml:"decision" json:"decision"`
+	Matched  string `yaml:"matched,omitempty" json:"matched,omitempty"`
+	Reason   string `yaml:"reason,omitempty" json:"reason,omitempty"`
+}
+
+// IsTestFile reports whether name follows the test file naming convention.
+func IsTestFile(name string) bool {
+	for _, ext := range []string{".yaml", ".yml", ".json"} {
+		if strings.HasSuffix(name, "_test"+ext) {
+			return true
+		}
+	}
+	return false
+}
+
+func isPolicyFile(name string) bool {
+	switch filepath.Ext(name) {
+	case ".yaml", ".yml", ".json":
+		return !IsTestFile(name)
+	}
+	return false
+}
+
+// LoadSuites reads the test files at paths; directories are searched
+// recursively for test files.
+func LoadSuites(paths ...string) ([]Suite, error) {
+	files, err := collect(paths, IsTestFile)
+	if err != nil {
+		return nil, err
+	}
+	var suites []Suite
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		s := Suite{Path: path}
+		// JSON is valid YAML, so one decoder serves both.
+		if err := yaml.Unmarshal(data, &s); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if err := s.validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		suites = append(suites, s)
+	}
+	if len(suites) == 0 {
+		return nil, errors.New("no test files found")
+	}
+	return suites, nil
+}
+
+func (s *Suite) validate() error {
+	seen := map[string]bool{}
+	for i, c := range s.Tests {
+		if c.Name == "" {
+			return fmt.Errorf("tests[%d]: name is required", i)
+		}
+		if seen[c.Name] {
+			return fmt.Errorf("%s: duplicate test name", c.Name)
+		}
+		seen[c.Name] = true
+		if c.Expect.Decision != "allow" && c.Expect.Decision != "deny" {
+			return fmt.Errorf("%s: expect.decision must be allow or deny", c.Name)
+		}
+	}
+	return nil
+}
+
+// LoadPolicies reads every policy file under paths into one file. A policy
+// name may appear only once across files.
+func LoadPolicies(paths ...string) (*policyfile.File, error) {
+	files, err := collect(paths, isPolicyFile)
+	if err != nil {
+		return nil, err
+	}
+	merged := &policyfile.File{Policies: map[string][]policyfile.Policy{}}
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		f, err := policyfile.Parse(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		for provider, ps := range f.Policies {
+			merged.Policies[provider] = append(merged.Policies[provider], ps...)
+		}
+	}
+	if errs := merged.Validate(); len(errs) > 0 {
+		return nil, fmt.Errorf("invalid policies:\n  %s", strings.Join(errs, "\n  "))
+	}
+	return merged, nil
+}
+
+// PolicyStore returns an in-memory store holding the policies under paths.
+func PolicyStore(paths ...string) (*store.Memory, error) {
+	f, err := LoadPolicies(paths...)
+	if err != nil {
+		return nil, err
+	}
+	m := store.NewMemory()
+	plan, err := policyfile.Apply(f, m, store.Change{})
+	if err == policyfile.ErrInvalid {
+		return nil, fmt.Errorf("invalid policies:\n%s", plan.String())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// collect expands paths to the files keep accepts, sorted. Files named
+// explicitly are always kept.
+func collect(paths []string, keep func(string) bool) ([]string, error) {
+	var out []string
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			out = append(out, p)
+			continue
+		}
+		err = filepath.WalkDir(p, func(path string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && keep(d.Name()) {
+				out = append(out, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(out)
+	return out, nil
+}
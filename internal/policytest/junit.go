@@ -0,0 +1,88 @@
+package policytest
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Errors   int          `xml:"errors,attr"`
+	Time     string       `xml:"time,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Errors   int         `xml:"errors,attr"`
+	Time     string      `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitProblem `xml:"failure,omitempty"`
+	Error     *junitProblem `xml:"error,omitempty"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Body    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the report as JUnit XML, one testsuite per test file.
+// Evaluation errors are reported as errors, unmet expectations as failures.
+func (r *Report) WriteJUnit(w io.Writer) error {
+	out := junitSuites{Time: seconds(r.Duration.Seconds())}
+	index := map[string]int{}
+	var totals []float64
+	for i := range r.Outcomes {
+		o := &r.Outcomes[i]
+		si, ok := index[o.Suite]
+		if !ok {
+			si = len(out.Suites)
+			index[o.Suite] = si
+			out.Suites = append(out.Suites, junitSuite{Name: o.Suite})
+			totals = append(totals, 0)
+		}
+		s := &out.Suites[si]
+		jc := junitCase{Name: o.Case.Name, ClassName: o.Suite, Time: seconds(o.Duration.Seconds())}
+		switch {
+		case o.Err != nil:
+			jc.Error = &junitProblem{Message: o.Err.Error(), Body: r.Detail(o)}
+			s.Errors++
+			out.Errors++
+		case len(o.Failures) > 0:
+			jc.Failure = &junitProblem{Message: strings.Join(o.Failures, "; "), Body: r.Detail(o)}
+			s.Failures++
+			out.Failures++
+		}
+		s.Tests++
+		out.Tests++
+		totals[si] += o.Duration.Seconds()
+		s.Cases = append(s.Cases, jc)
+	}
+	for i := range out.Suites {
+		out.Suites[i].Time = seconds(totals[i])
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func seconds(s float64) string { return fmt.Sprintf("%.3f", s) }
@@ -0,0 +1,256 @@
+package policytest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"example.com/jit-engine/internal/eval"
+	"example.com/jit-engine/internal/model"
+	"example.com/jit-engine/internal/store"
+)
+
+// Evaluator decides requests for the runner.
+type Evaluator interface {
+	Evaluate(req eval.Request) (eval.Result, error)
+	// Policies lists the policies decisions may refer to, for naming them.
+	Policies() ([]model.Policy, error)
+}
+
+// Local evaluates with an engine over s without recording audits.
+type Local struct {
+	Engine *eval.EvalEngine
+	Store  store.PolicyStore
+}
+
+// NewLocal returns an evaluator over the policy files under paths.
+func NewLocal(paths ...string) (*Local, error) {
+	m, err := PolicyStore(paths...)
+	if err != nil {
+		return nil, err
+	}
+	eng, err := eval.NewEngine(m, m, true)
+	if err != nil {
+		return nil, err
+	}
+	return &Local{Engine: eng, Store: m}, nil
+}
+
+func (l *Local) Evaluate(req eval.Request) (eval.Result, error) { return l.Engine.Evaluate(req) }
+
+func (l *Local) Policies() ([]model.Policy, error) {
+	return l.Store.ListPolicies(store.PolicyFilter{})
+}
+
+// Remote evaluates against a running server's /evaluate. Those decisions are
+// audited like any other.
+type Remote struct {
+	URL   string
+	Token string
+	HTTP  *http.Client
+}
+
+func (r *Remote) Evaluate(req eval.Request) (eval.Result, error) {
+	var res eval.Result
+	err := r.call(http.MethodPost, "/evaluate", req, &res)
+	return res, err
+}
+
+func (r *Remote) Policies() ([]model.Policy, error) {
+	var ps []model.Policy
+	err := r.call(http.MethodGet, "/policies", nil, &ps)
+	return ps, err
+}
+
+func (r *Remote) call(method, path string, body, out any) error {
+	var rd io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		rd = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, strings.TrimSuffix(r.URL, "/")+path, rd)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if r.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.Token)
+	}
+	client := r.HTTP
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// Outcome is the result of one case.
+type Outcome struct {
+	Suite    string
+	Case     Case
+	Result   eval.Result
+	Err      error
+	Failures []string
+	Duration time.Duration
+}
+
+// Passed reports whether the case met its expectation.
+func (o *Outcome) Passed() bool { return o.Err == nil && len(o.Failures) == 0 }
+
+// Report is the outcome of a run.
+type Report struct {
+	Outcomes []Outcome
+	Duration time.Duration
+	names    map[string]string
+}
+
+// Failed counts cases that did not pass.
+func (r *Report) Failed() int {
+	n := 0
+	for i := range r.Outcomes {
+		if !r.Outcomes[i].Passed() {
+			n++
+		}
+	}
+	return n
+}
+
+// Run evaluates every case with ev.
+func Run(ev Evaluator, suites []Suite) (*Report, error) {
+	ps, err := ev.Policies()
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]string, len(ps))
+	for _, p := range ps {
+		names[p.ID.String()] = p.Provider + "/" + p.Name
+	}
+	rep := &Report{names: names}
+	start := time.Now()
+	for _, s := range suites {
+		for _, c := range s.Tests {
+			t := time.Now()
+			o := Outcome{Suite: s.Path, Case: c}
+			o.Result, o.Err = ev.Evaluate(c.Request)
+			if o.Err == nil {
+				o.Failures = check(c.Expect, o.Result, names)
+			}
+			o.Duration = time.Since(t)
+			rep.Outcomes = append(rep.Outcomes, o)
+		}
+	}
+	rep.Duration = time.Since(start)
+	return rep, nil
+}
+
+// check compares res with want.
+func check(want Expectation, res eval.Result, names map[string]string) []string {
+	var out []string
+	if res.Decision != want.Decision {
+		out = append(out, fmt.Sprintf("decision: want %s, got %s", want.Decision, res.Decision))
+	}
+	if want.Matched != "" {
+		got := "none"
+		if res.Matched != nil {
+			got = res.Matched.String()
+		}
+		if !matchesPolicy(want.Matched, got, names) {
+			out = append(out, fmt.Sprintf("matched: want %s, got %s", want.Matched, label(got, names)))
+		}
+	}
+	if want.Reason != "" && !strings.Contains(res.Reason, want.Reason) {
+		out = append(out, fmt.Sprintf("reason: want it to contain %q, got %q", want.Reason, res.Reason))
+	}
+	return out
+}
+
+// matchesPolicy reports whether the matched policy id is the one want names
+// by ID, provider/name or name.
+func matchesPolicy(want, id string, names map[string]string) bool {
+	if want == id {
+		return true
+	}
+	full, ok := names[id]
+	if !ok {
+		return false
+	}
+	if want == full {
+		return true
+	}
+	_, name, _ := strings.Cut(full, "/")
+	return want == name
+}
+
+func label(id string, names map[string]string) string {
+	if n, ok := names[id]; ok {
+		return n
+	}
+	return id
+}
+
+// Detail describes a failed outcome with the evaluation trace.
+func (r *Report) Detail(o *Outcome) string {
+	var b strings.Builder
+	if o.Err != nil {
+		fmt.Fprintf(&b, "error: %v\n", o.Err)
+		return b.String()
+	}
+	for _, f := range o.Failures {
+		fmt.Fprintf(&b, "%s\n", f)
+	}
+	fmt.Fprintf(&b, "reason: %s\n", o.Result.Reason)
+	if len(o.Result.Providers) > 0 {
+		fmt.Fprintf(&b, "providers: %s\n", strings.Join(o.Result.Providers, ", "))
+	}
+	if len(o.Result.Trace) > 0 {
+		b.WriteString("trace:\n")
+	}
+	for _, t := range o.Result.Trace {
+		res := "-"
+		if t.Result != nil {
+			res = fmt.Sprint(*t.Result)
+		}
+		fmt.Fprintf(&b, "  %s %s %s: %s", t.Effect, label(t.PolicyID.String(), r.names), res, t.Reason)
+		if t.Error != "" {
+			fmt.Fprintf(&b, " (error: %s)", t.Error)
+		}
+		if t.MatchedAction != "" {
+			fmt.Fprintf(&b, " (action via %s)", t.MatchedAction)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// String summarises the run for people: one line per failure with details,
+// then the totals.
+func (r *Report) String() string {
+	var b strings.Builder
+	for i := range r.Outcomes {
+		o := &r.Outcomes[i]
+		if o.Passed() {
+			continue
+		}
+		fmt.Fprintf(&b, "FAIL %s: %s\n", o.Suite, o.Case.Name)
+		for _, line := range strings.Split(strings.TrimRight(r.Detail(o), "\n"), "\n") {
+			fmt.Fprintf(&b, "    %s\n", line)
+		}
+	}
+	fmt.Fprintf(&b, "%d passed, %d failed (%s)\n", len(r.Outcomes)-r.Failed(), r.Failed(), r.Duration.Round(time.Millisecond))
+	return b.String()
+}
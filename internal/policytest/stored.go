@@ -0,0 +1,60 @@
+package policytest
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/google/uuid"
+
+	"example.com/jit-engine/internal/eval"
+	"example.com/jit-engine/internal/model"
+)
+
+// StoredSuiteName is the Path of the suite built from stored test cases.
+const StoredSuiteName = "stored"
+
+// StoredSuite converts test cases kept in the database to a suite, leaving
+// out those attached to the policies in skip.
+func StoredSuite(tcs []model.PolicyTestCase, skip ...uuid.UUID) (Suite, error) {
+	s := Suite{Path: StoredSuiteName}
+	for _, tc := range tcs {
+		if tc.PolicyID != nil && slices.Contains(skip, *tc.PolicyID) {
+			continue
+		}
+		id := tc.ID
+		c := Case{ID: &id, Name: tc.Name, Expect: Expectation{Decision: tc.ExpectDecision, Matched: tc.ExpectMatched, Reason: tc.ExpectReason}}
+		if err := json.Unmarshal(tc.Request, &c.Request); err != nil {
+			return s, fmt.Errorf("test case %s: request: %w", tc.ID, err)
+		}
+		s.Tests = append(s.Tests, c)
+	}
+	return s, nil
+}
+
+// Regression is a case that passed before a change and fails after it.
+type Regression struct {
+	ID       *uuid.UUID  `json:"id,omitempty"`
+	Name     string      `json:"name"`
+	Failures []string    `json:"failures"`
+	Result   eval.Result `json:"result"`
+}
+
+// Regressions compares two runs of the same suites and returns the cases
+// that passed in before but not in after. Cases already failing in before
+// are not the change's doing and are ignored.
+func Regressions(before, after *Report) []Regression {
+	out := []Regression{}
+	for i := range after.Outcomes {
+		a := &after.Outcomes[i]
+		if a.Passed() || (i < len(before.Outcomes) && !before.Outcomes[i].Passed()) {
+			continue
+		}
+		failures := a.Failures
+		if a.Err != nil {
+			failures = []string{"error: " + a.Err.Error()}
+		}
+		out = append(out, Regression{ID: a.Case.ID, Name: a.Case.Name, Failures: failures, Result: a.Result})
+	}
+	return out
+}
@@ -0,0 +1,176 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"example.com/jit-engine/internal/eval"
+	"example.com/jit-engine/internal/model"
+	"example.com/jit-engine/internal/store"
+)
+
+// ErrNotAllowed is returned by Register when the request is not currently allowed.
+var ErrNotAllowed = errors.New("session request is not allowed")
+
+// Event is published to subscribers when a session loses its authorization.
+type Event struct {
+	Type      string    `json:"type"`
+	SessionID string    `json:"session_id"`
+	SubjectID string    `json:"subject_id"`
+	Resource  string    `json:"resource"`
+	Action    string    `json:"action"`
+	Reason    string    `json:"reason"`
+	Time      time.Time `json:"time"`
+}
+
+// Registry tracks active sessions and revokes them when a re-evaluation no
+// longer allows them.
+type Registry struct {
+	store   store.SessionStore
+	engine  *eval.EvalEngine
+	trigger chan struct{}
+	// broadcast is set while a change feed delivers revocations to every
+	// replica, this one included, so they are not published locally.
+	broadcast atomic.Bool
+
+	mu   sync.Mutex
+	subs map[chan Event]struct{}
+}
+
+func NewRegistry(sessions store.SessionStore, engine *eval.EvalEngine) *Registry {
+	return &Registry{
+		store:   sessions,
+		engine:  engine,
+		trigger: make(chan struct{}, 1),
+		subs:    map[chan Event]struct{}{},
+	}
+}
+
+// Register records an active session for req. The request is evaluated again
+// so only sessions that are allowed right now can be registered.
+func (r *Registry) Register(id string, req eval.Request) (model.Session, eval.Result, error) {
+	res, err := r.engine.Evaluate(req)
+	if err != nil {
+		return model.Session{}, res, err
+	}
+	if res.Decision != "allow" {
+		return model.Session{}, res, ErrNotAllowed
+	}
+	rb, _ := json.Marshal(req)
+	s := model.Session{
+		ID:        id,
+		SubjectID: eval.SubjectID(req),
+		Resource:  req.Resource,
+		Action:    req.Action,
+		Request:   rb,
+		Status:    "active",
+	}
+	return s, res, r.store.CreateSession(&s)
+}
+
+// Close marks a session as ended by the broker.
+func (r *Registry) Close(id string) (bool, error) {
+	return r.store.CloseSession(id)
+}
+
+// Trigger schedules a re-evaluation of every active session. Calls made while
+// one is pending are coalesced, so it is cheap to call after every write.
+func (r *Registry) Trigger() {
+	select {
+	case r.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Run re-evaluates sessions whenever Trigger is called until ctx is done.
+func (r *Registry) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.trigger:
+			if err := r.reevaluate(); err != nil {
+				log.Printf("session re-evaluation: %v", err)
+			}
+		}
+	}
+}
+
+func (r *Registry) reevaluate() error {
+	sessions, err := r.store.Sessions(store.SessionFilter{Status: "active"})
+	if err != nil {
+		return err
+	}
+	for _, s := range sessions {
+		var req eval.Request
+		if err := json.Unmarshal(s.Request, &req); err != nil {
+			log.Printf("session %s: decode request: %v", s.ID, err)
+			continue
+		}
+		res, err := r.engine.Evaluate(req)
+		if err != nil {
+			// Keep the session; a transient error is not a policy decision.
+			log.Printf("session %s: evaluate: %v", s.ID, err)
+			continue
+		}
+		if res.Decision == "allow" {
+			continue
+		}
+		now := time.Now()
+		revoked, err := r.store.RevokeSession(s.ID, res.Reason, now)
+		if err != nil {
+			log.Printf("session %s: revoke: %v", s.ID, err)
+			continue
+		}
+		if !revoked {
+			continue
+		}
+		if r.broadcast.Load() {
+			continue
+		}
+		r.publish(Event{Type: "revoked", SessionID: s.ID, SubjectID: s.SubjectID, Resource: s.Resource, Action: s.Action, Reason: res.Reason, Time: now.UTC()})
+	}
+	return nil
+}
+
+// Broadcast reports whether revocations are announced through a change feed,
+// which then passes every replica's revocations to Deliver. Replicas share
+// the sessions table, so the one that wins a revocation is not necessarily
+// the one the subscriber is connected to.
+func (r *Registry) Broadcast(on bool) { r.broadcast.Store(on) }
+
+// Deliver publishes a revocation announced by the change feed.
+func (r *Registry) Deliver(ev Event) { r.publish(ev) }
+
+// Subscribe returns a channel of revocation events and a function to stop the subscription.
+func (r *Registry) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, 64)
+	r.mu.Lock()
+	r.subs[ch] = struct{}{}
+	r.mu.Unlock()
+	return ch, func() {
+		r.mu.Lock()
+		if _, ok := r.subs[ch]; ok {
+			delete(r.subs, ch)
+			close(ch)
+		}
+		r.mu.Unlock()
+	}
+}
+
+func (r *Registry) publish(ev Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for ch := range r.subs {
+		select {
+		case ch <- ev:
+		default:
+			log.Printf("session event for %s dropped: subscriber is not keeping up", ev.SessionID)
+		}
+	}
+}
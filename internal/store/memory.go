@@ -0,0 +1,945 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"example.com/jit-engine/internal/model"
+	"example.com/jit-engine/internal/policy"
+)
+
+// Memory keeps everything in process memory. It suits embedding and CI; the
+// contents are lost when the process exits.
+type Memory struct {
+	// txMu serialises transactions; mu guards the data.
+	txMu        sync.Mutex
+	mu          sync.RWMutex
+	policies    map[uuid.UUID]model.Policy
+	sod         []model.SoDConstraint
+	groups      map[string]model.ActionGroup
+	hierarchies []model.ActionHierarchy
+	providers   map[string]model.Provider
+	rules       []model.ResolutionRule
+	delegations []model.Delegation
+	tests       []model.PolicyTestCase
+	versions    []model.PolicyVersion
+	audits      []model.PolicyAudit
+	sessions    []model.Session
+}
+
+// NewMemory returns an empty store with the default providers registered.
+func NewMemory() *Memory {
+	m := &Memory{
+		policies:  map[uuid.UUID]model.Policy{},
+		groups:    map[string]model.ActionGroup{},
+		providers: map[string]model.Provider{},
+	}
+	for _, p := range DefaultProviders() {
+		m.PutProvider(p)
+	}
+	return m
+}
+
+// Transaction restores the policies, their versions and test cases as they
+// were if fn fails. Writes made outside transactions while fn runs are not
+// isolated from it.
+func (m *Memory) Transaction(fn func(PolicyStore) error) error {
+	return m.transaction(m, fn)
+}
+
+func (m *Memory) transaction(s PolicyStore, fn func(PolicyStore) error) error {
+	m.txMu.Lock()
+	defer m.txMu.Unlock()
+	m.mu.RLock()
+	saved := make(map[uuid.UUID]model.Policy, len(m.policies))
+	for id, p := range m.policies {
+		saved[id] = p
+	}
+	savedTests := append([]model.PolicyTestCase(nil), m.tests...)
+	savedVersions := len(m.versions)
+	m.mu.RUnlock()
+	if err := fn(s); err != nil {
+		m.mu.Lock()
+		m.policies = saved
+		m.tests = savedTests
+		m.versions = m.versions[:savedVersions]
+		m.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (m *Memory) WithChange(c Change) PolicyStore { return memoryChange{m, c} }
+
+// memoryChange is the view of a Memory returned by WithChange.
+type memoryChange struct {
+	*Memory
+	change Change
+}
+
+func (w memoryChange) Transaction(fn func(PolicyStore) error) error {
+	return w.transaction(w, fn)
+}
+
+func (w memoryChange) WithChange(c Change) PolicyStore { return memoryChange{w.Memory, c} }
+
+func (w memoryChange) CreatePolicy(p *model.Policy) error { return w.createPolicy(p, w.change) }
+
+func (w memoryChange) UpdatePolicy(p *model.Policy) error { return w.updatePolicy(p, w.change) }
+
+func (w memoryChange) DeletePolicy(id uuid.UUID) error { return w.deletePolicy(id, w.change) }
+
+func (w memoryChange) RestorePolicy(p *model.Policy) error { return w.restorePolicy(p, w.change) }
+
+func (m *Memory) ListPolicies(f PolicyFilter) ([]model.Policy, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var out []model.Policy
+	for _, p := range m.policies {
+		switch {
+		case f.Name != "" && !strings.Contains(strings.ToLower(p.Name), strings.ToLower(f.Name)),
+			f.Effect != "" && p.Effect != f.Effect,
+			f.Provider != "" && p.Provider != f.Provider,
+			f.Enabled != nil && p.Enabled != *f.Enabled:
+			continue
+		}
+		out = append(out, p)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Priority != out[j].Priority {
+			return out[i].Priority < out[j].Priority
+		}
+		return out[i].CreatedAt.Before(out[j].CreatedAt)
+	})
+	return out, nil
+}
+
+func (m *Memory) GetPolicy(id uuid.UUID) (model.Policy, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	p, ok := m.policies[id]
+	if !ok {
+		return model.Policy{}, ErrNotFound
+	}
+	return p, nil
+}
+
+// CreatePolicy applies the column defaults the SQL stores do; like them it
+// stores a zero Enabled as true.
+func (m *Memory) CreatePolicy(p *model.Policy) error { return m.createPolicy(p, Change{}) }
+
+func (m *Memory) createPolicy(p *model.Policy, c Change) error {
+	// The same defaults as the SQL columns, which apply to zero values
+	if p.Provider == "" {
+		p.Provider = "global"
+	}
+	if !p.Enabled {
+		p.Enabled = true
+	}
+	if p.Priority == 0 {
+		p.Priority = 100
+	}
+	if err := model.ValidatePolicy(p, m); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if p.ID == uuid.Nil {
+		p.ID = uuid.New()
+	}
+	if _, ok := m.policies[p.ID]; ok {
+		return fmt.Errorf("policy %s already exists", p.ID)
+	}
+	now := time.Now()
+	p.CreatedAt, p.UpdatedAt = now, now
+	// A restored policy continues its history
+	p.Version = nextVersion(0, m.latestVersionLocked(p.ID))
+	if err := m.recordVersionLocked(p.ID, p.Version, model.VersionCreate, *p, c); err != nil {
+		return err
+	}
+	m.policies[p.ID] = *p
+	return nil
+}
+
+func (m *Memory) RestorePolicy(p *model.Policy) error { return m.restorePolicy(p, Change{}) }
+
+func (m *Memory) restorePolicy(p *model.Policy, c Change) error {
+	if err := model.ValidatePolicy(p, m); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.policies[p.ID]; ok {
+		return fmt.Errorf("policy %s already exists", p.ID)
+	}
+	p.UpdatedAt = time.Now()
+	if p.CreatedAt.IsZero() {
+		p.CreatedAt = p.UpdatedAt
+	}
+	p.Version = nextVersion(0, m.latestVersionLocked(p.ID))
+	if err := m.recordVersionLocked(p.ID, p.Version, model.VersionCreate, *p, c); err != nil {
+		return err
+	}
+	m.policies[p.ID] = *p
+	return nil
+}
+
+func (m *Memory) UpdatePolicy(p *model.Policy) error { return m.updatePolicy(p, Change{}) }
+
+func (m *Memory) updatePolicy(p *model.Policy, c Change) error {
+	if err := model.ValidatePolicy(p, m); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	existing, ok := m.policies[p.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	if err := c.checkVersion(existing.Version); err != nil {
+		return err
+	}
+	if sameContent(existing, *p) {
+		*p = existing
+		return nil
+	}
+	p.Condition = existing.Condition
+	p.CreatedAt = existing.CreatedAt
+	p.UpdatedAt = time.Now()
+	p.Version = nextVersion(existing.Version, m.latestVersionLocked(p.ID))
+	if err := m.recordVersionLocked(p.ID, p.Version, model.VersionUpdate, *p, c); err != nil {
+		return err
+	}
+	m.policies[p.ID] = *p
+	return nil
+}
+
+func (m *Memory) DeletePolicy(id uuid.UUID) error { return m.deletePolicy(id, Change{}) }
+
+func (m *Memory) deletePolicy(id uuid.UUID, c Change) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	existing, ok := m.policies[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if err := c.checkVersion(existing.Version); err != nil {
+		return err
+	}
+	if err := m.recordVersionLocked(id, nextVersion(existing.Version, m.latestVersionLocked(id)), model.VersionDelete, existing, c); err != nil {
+		return err
+	}
+	delete(m.policies, id)
+	kept := m.tests[:0]
+	for _, tc := range m.tests {
+		if tc.PolicyID == nil || *tc.PolicyID != id {
+			kept = append(kept, tc)
+		}
+	}
+	m.tests = kept
+	return nil
+}
+
+func (m *Memory) PolicyVersions(id uuid.UUID) ([]model.PolicyVersion, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var out []model.PolicyVersion
+	for _, v := range m.versions {
+		if v.PolicyID == id {
+			out = append(out, v)
+		}
+	}
+	return out, nil
+}
+
+func (m *Memory) PolicyVersion(id uuid.UUID, n int) (model.PolicyVersion, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, v := range m.versions {
+		if v.PolicyID == id && v.Version == n {
+			return v, nil
+		}
+	}
+	return model.PolicyVersion{}, ErrNotFound
+}
+
+func (m *Memory) latestVersionLocked(id uuid.UUID) int {
+	latest := 0
+	for _, v := range m.versions {
+		if v.PolicyID == id {
+			latest = max(latest, v.Version)
+		}
+	}
+	return latest
+}
+
+func (m *Memory) recordVersionLocked(id uuid.UUID, n int, op string, p model.Policy, c Change) error {
+	v, err := newVersion(id, n, op, p, c)
+	if err != nil {
+		return err
+	}
+	v.ID, v.CreatedAt = uuid.New(), time.Now()
+	m.versions = append(m.versions, v)
+	return nil
+}
+
+func (m *Memory) ResolveProvider(name string) (model.Provider, error) {
+	ps, _ := m.Providers()
+	return resolveProvider(ps, name)
+}
+
+// KnownActionGroups implements model.PolicyRefs.
+func (m *Memory) KnownActionGroups(names []string) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var found []string
+	for _, n := range names {
+		if _, ok := m.groups[n]; ok {
+			found = append(found, n)
+		}
+	}
+	return found, nil
+}
+
+// ProviderByName implements model.PolicyRefs.
+func (m *Memory) ProviderByName(name string) (*model.Provider, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	p, ok := m.providers[name]
+	if !ok {
+		return nil, nil
+	}
+	return &p, nil
+}
+
+func (m *Memory) EnabledPolicies() ([]model.Policy, error) {
+	t := true
+	return m.ListPolicies(PolicyFilter{Enabled: &t})
+}
+
+func (m *Memory) EnabledSoDConstraints() ([]model.SoDConstraint, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var out []model.SoDConstraint
+	for _, c := range m.sod {
+		if c.Enabled {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+func (m *Memory) ActionGroups() ([]model.ActionGroup, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make([]model.ActionGroup, 0, len(m.groups))
+	for _, g := range m.groups {
+		out = append(out, g)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+func (m *Memory) ActionHierarchies() ([]model.ActionHierarchy, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := append([]model.ActionHierarchy(nil), m.hierarchies...)
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Provider != out[j].Provider {
+			return out[i].Provider < out[j].Provider
+		}
+		return out[i].Action < out[j].Action
+	})
+	return out, nil
+}
+
+func (m *Memory) Providers() ([]model.Provider, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make([]model.Provider, 0, len(m.providers))
+	for _, p := range m.providers {
+		out = append(out, p)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+func (m *Memory) EnabledResolutionRules() ([]model.ResolutionRule, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var out []model.ResolutionRule
+	for _, r := range m.rules {
+		if r.Enabled {
+			out = append(out, r)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Priority < out[j].Priority })
+	return out, nil
+}
+
+func (m *Memory) ActiveDelegations(delegate, action string, at time.Time) ([]model.Delegation, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var out []model.Delegation
+	for _, d := range m.delegations {
+		if d.Delegate == delegate && d.RevokedAt == nil && d.ExpiresAt.After(at) {
+			out = append(out, d)
+		}
+	}
+	return delegationsFor(out, action), nil
+}
+
+func (m *Memory) EnabledTestCases() ([]model.PolicyTestCase, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var out []model.PolicyTestCase
+	for _, tc := range m.tests {
+		if tc.Enabled {
+			out = append(out, tc)
+		}
+	}
+	return out, nil
+}
+
+func (m *Memory) RecordAudit(a *model.PolicyAudit) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	if a.Severity == "" {
+		a.Severity = "info"
+	}
+	a.CreatedAt = time.Now()
+	m.audits = append(m.audits, *a)
+	return nil
+}
+
+func (m *Memory) ListAudits(f AuditFilter) ([]model.PolicyAudit, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var out []model.PolicyAudit
+	skip := f.Offset
+	for i := len(m.audits) - 1; i >= 0 && (f.Limit == 0 || len(out) < f.Limit); i-- {
+		if !auditMatches(&m.audits[i], f) {
+			continue
+		}
+		if skip > 0 {
+			skip--
+			continue
+		}
+		out = append(out, m.audits[i])
+	}
+	return out, nil
+}
+
+func (m *Memory) CountAudits(f AuditFilter) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	n := 0
+	for i := range m.audits {
+		if auditMatches(&m.audits[i], f) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// auditMatches reports whether a meets the conditions of f.
+func auditMatches(a *model.PolicyAudit, f AuditFilter) bool {
+	switch {
+	case f.Decision != "" && a.Decision != f.Decision,
+		f.Provider != "" && !contains(a.Providers, f.Provider),
+		!f.Since.IsZero() && a.CreatedAt.Before(f.Since),
+		!f.Until.IsZero() && !a.CreatedAt.Before(f.Until),
+		f.BreakGlass && !a.BreakGlass:
+		return false
+	}
+	if f.Subject == "" && f.Action == "" {
+		return true
+	}
+	var req struct {
+		Subject map[string]any `json:"subject"`
+		Action  string         `json:"action"`
+	}
+	if err := json.Unmarshal(a.Request, &req); err != nil {
+		return false
+	}
+	return (f.Subject == "" || fmt.Sprint(req.Subject["id"]) == f.Subject) && (f.Action == "" || req.Action == f.Action)
+}
+
+func (m *Memory) LastAllow(subject, scopeKey, scope string, actions []string) (*model.PolicyAudit, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for i := len(m.audits) - 1; i >= 0; i-- {
+		a := m.audits[i]
+		if a.Decision != "allow" {
+			continue
+		}
+		var req struct {
+			Subject  map[string]any `json:"subject"`
+			Action   string         `json:"action"`
+			Metadata map[string]any `json:"metadata"`
+		}
+		if err := json.Unmarshal(a.Request, &req); err != nil {
+			continue
+		}
+		if fmt.Sprint(req.Subject["id"]) != subject || !contains(actions, req.Action) {
+			continue
+		}
+		if v, ok := req.Metadata[scopeKey]; ok && v != nil && fmt.Sprint(v) == scope {
+			return &a, nil
+		}
+	}
+	return nil, nil
+}
+
+// Audits returns the recorded audit entries, oldest first.
+func (m *Memory) Audits() []model.PolicyAudit {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]model.PolicyAudit(nil), m.audits...)
+}
+
+// PutPolicy validates and stores p as given, keeping its ID, Enabled flag and
+// timestamps. It is for loading policies exported from another store.
+func (m *Memory) PutPolicy(p model.Policy) error {
+	if p.ID == uuid.Nil {
+		return fmt.Errorf("policy %q has no id", p.Name)
+	}
+	if err := model.ValidatePolicy(&p, m); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.policies[p.ID] = p
+	return nil
+}
+
+// PutProvider registers or replaces a provider.
+func (m *Memory) PutProvider(p model.Provider) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if p.DefaultDecision == "" {
+		p.DefaultDecision = "deny"
+	}
+	now := time.Now()
+	if old, ok := m.providers[p.Name]; ok {
+		p.CreatedAt = old.CreatedAt
+	} else {
+		p.CreatedAt = now
+	}
+	p.UpdatedAt = now
+	m.providers[p.Name] = p
+}
+
+// PutActionGroup registers or replaces an action group.
+func (m *Memory) PutActionGroup(g model.ActionGroup) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	g.UpdatedAt = time.Now()
+	m.groups[g.Name] = g
+}
+
+// AddActionHierarchy declares what an action implies for a provider.
+func (m *Memory) AddActionHierarchy(h model.ActionHierarchy) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if h.ID == uuid.Nil {
+		h.ID = uuid.New()
+	}
+	h.UpdatedAt = time.Now()
+	for i, x := range m.hierarchies {
+		if x.Provider == h.Provider && x.Action == h.Action {
+			m.hierarchies[i] = h
+			return
+		}
+	}
+	m.hierarchies = append(m.hierarchies, h)
+}
+
+// AddSoDConstraint appends an enabled separation-of-duties constraint.
+func (m *Memory) AddSoDConstraint(c model.SoDConstraint) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c.Enabled = true
+	if c.ID == uuid.Nil {
+		c.ID = uuid.New()
+	}
+	c.CreatedAt, c.UpdatedAt = time.Now(), time.Now()
+	m.sod = append(m.sod, c)
+}
+
+// AddResolutionRule appends an enabled provider resolution rule.
+func (m *Memory) AddResolutionRule(r model.ResolutionRule) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r.Enabled = true
+	if r.Priority == 0 {
+		r.Priority = 100
+	}
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	if r.Combine == "" {
+		r.Combine = "all"
+	}
+	r.CreatedAt, r.UpdatedAt = time.Now(), time.Now()
+	m.rules = append(m.rules, r)
+}
+
+// AddDelegation appends a delegation.
+func (m *Memory) AddDelegation(d model.Delegation) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if d.ID == uuid.Nil {
+		d.ID = uuid.New()
+	}
+	d.CreatedAt = time.Now()
+	m.delegations = append(m.delegations, d)
+}
+
+// AddTestCase appends an enabled regression test case.
+func (m *Memory) AddTestCase(tc model.PolicyTestCase) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	tc.Enabled = true
+	if tc.ID == uuid.Nil {
+		tc.ID = uuid.New()
+	}
+	tc.CreatedAt, tc.UpdatedAt = time.Now(), time.Now()
+	m.tests = append(m.tests, tc)
+}
+
+func (m *Memory) GetProvider(name string) (model.Provider, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	p, ok := m.providers[name]
+	if !ok {
+		return model.Provider{}, ErrNotFound
+	}
+	return p, nil
+}
+
+func (m *Memory) CreateProvider(p *model.Provider) error {
+	m.mu.RLock()
+	_, exists := m.providers[p.Name]
+	m.mu.RUnlock()
+	if exists {
+		return fmt.Errorf("provider %q already exists", p.Name)
+	}
+	m.PutProvider(*p)
+	*p, _ = m.GetProvider(p.Name)
+	return nil
+}
+
+func (m *Memory) UpdateProvider(p *model.Provider) error {
+	if _, err := m.GetProvider(p.Name); err != nil {
+		return err
+	}
+	m.PutProvider(*p)
+	*p, _ = m.GetProvider(p.Name)
+	return nil
+}
+
+func (m *Memory) DeleteProvider(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.providers[name]; !ok {
+		return ErrNotFound
+	}
+	for _, p := range m.policies {
+		if p.Provider == name {
+			return ErrInUse
+		}
+	}
+	delete(m.providers, name)
+	return nil
+}
+
+func (m *Memory) SaveActionGroup(g *model.ActionGroup) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	g.CreatedAt = now
+	if old, ok := m.groups[g.Name]; ok {
+		g.CreatedAt = old.CreatedAt
+	}
+	g.UpdatedAt = now
+	m.groups[g.Name] = *g
+	return nil
+}
+
+func (m *Memory) DeleteActionGroup(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.groups[name]; !ok {
+		return ErrNotFound
+	}
+	for _, p := range m.policies {
+		if contains(p.Actions, policy.GroupPrefix+name) {
+			return ErrInUse
+		}
+	}
+	delete(m.groups, name)
+	return nil
+}
+
+func (m *Memory) SaveActionHierarchy(h *model.ActionHierarchy) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for i, x := range m.hierarchies {
+		if x.Provider == h.Provider && x.Action == h.Action {
+			x.Implies, x.UpdatedAt = h.Implies, now
+			m.hierarchies[i] = x
+			*h = x
+			return nil
+		}
+	}
+	if h.ID == uuid.Nil {
+		h.ID = uuid.New()
+	}
+	h.CreatedAt, h.UpdatedAt = now, now
+	m.hierarchies = append(m.hierarchies, *h)
+	return nil
+}
+
+func (m *Memory) DeleteActionHierarchy(id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, h := range m.hierarchies {
+		if h.ID == id {
+			m.hierarchies = append(m.hierarchies[:i], m.hierarchies[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (m *Memory) ResolutionRules() ([]model.ResolutionRule, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := append([]model.ResolutionRule(nil), m.rules...)
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Priority < out[j].Priority })
+	return out, nil
+}
+
+// CreateResolutionRule applies the column defaults the SQL stores do.
+func (m *Memory) CreateResolutionRule(r *model.ResolutionRule) error {
+	m.AddResolutionRule(*r)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	*r = m.rules[len(m.rules)-1]
+	return nil
+}
+
+func (m *Memory) UpdateResolutionRule(r *model.ResolutionRule) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, x := range m.rules {
+		if x.ID == r.ID {
+			r.CreatedAt, r.UpdatedAt = x.CreatedAt, time.Now()
+			m.rules[i] = *r
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (m *Memory) DeleteResolutionRule(id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, r := range m.rules {
+		if r.ID == id {
+			m.rules = append(m.rules[:i], m.rules[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (m *Memory) SoDConstraints(kind string) ([]model.SoDConstraint, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var out []model.SoDConstraint
+	for _, c := range m.sod {
+		if kind == "" || c.Kind == kind {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+func (m *Memory) CreateSoDConstraint(c *model.SoDConstraint) error {
+	m.AddSoDConstraint(*c)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	*c = m.sod[len(m.sod)-1]
+	return nil
+}
+
+func (m *Memory) DeleteSoDConstraint(id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, c := range m.sod {
+		if c.ID == id {
+			m.sod = append(m.sod[:i], m.sod[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (m *Memory) TestCases(policyID *uuid.UUID, provider string) ([]model.PolicyTestCase, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var out []model.PolicyTestCase
+	for _, tc := range m.tests {
+		if policyID != nil && (tc.PolicyID == nil || *tc.PolicyID != *policyID) || provider != "" && tc.Provider != provider {
+			continue
+		}
+		out = append(out, tc)
+	}
+	return out, nil
+}
+
+func (m *Memory) CreateTestCase(tc *model.PolicyTestCase) error {
+	m.AddTestCase(*tc)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	*tc = m.tests[len(m.tests)-1]
+	return nil
+}
+
+func (m *Memory) UpdateTestCase(tc *model.PolicyTestCase) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, x := range m.tests {
+		if x.ID == tc.ID {
+			tc.CreatedAt, tc.UpdatedAt = x.CreatedAt, time.Now()
+			m.tests[i] = *tc
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (m *Memory) DeleteTestCase(id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, tc := range m.tests {
+		if tc.ID == id {
+			m.tests = append(m.tests[:i], m.tests[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (m *Memory) Delegations(f DelegationFilter) ([]model.Delegation, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var out []model.Delegation
+	for _, d := range m.delegations {
+		switch {
+		case f.Delegator != "" && d.Delegator != f.Delegator,
+			f.Delegate != "" && d.Delegate != f.Delegate,
+			!f.ActiveAt.IsZero() && (d.RevokedAt != nil || !d.ExpiresAt.After(f.ActiveAt)):
+			continue
+		}
+		out = append(out, d)
+	}
+	return out, nil
+}
+
+func (m *Memory) CreateDelegation(d *model.Delegation) error {
+	m.AddDelegation(*d)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	*d = m.delegations[len(m.delegations)-1]
+	return nil
+}
+
+func (m *Memory) RevokeDelegation(id uuid.UUID, at time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, d := range m.delegations {
+		if d.ID != id {
+			continue
+		}
+		if d.RevokedAt != nil {
+			return false, nil
+		}
+		m.delegations[i].RevokedAt = &at
+		return true, nil
+	}
+	return false, ErrNotFound
+}
+
+func (m *Memory) Sessions(f SessionFilter) ([]model.Session, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var out []model.Session
+	for i := len(m.sessions) - 1; i >= 0; i-- {
+		s := m.sessions[i]
+		if f.Status != "" && s.Status != f.Status || f.SubjectID != "" && s.SubjectID != f.SubjectID {
+			continue
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+func (m *Memory) CreateSession(s *model.Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, x := range m.sessions {
+		if x.ID == s.ID {
+			return fmt.Errorf("session %q already exists", s.ID)
+		}
+	}
+	if s.Status == "" {
+		s.Status = "active"
+	}
+	s.CreatedAt, s.UpdatedAt = time.Now(), time.Now()
+	m.sessions = append(m.sessions, *s)
+	return nil
+}
+
+func (m *Memory) CloseSession(id string) (bool, error) {
+	return m.endSession(id, func(s *model.Session) { s.Status = "closed" })
+}
+
+func (m *Memory) RevokeSession(id, reason string, at time.Time) (bool, error) {
+	return m.endSession(id, func(s *model.Session) { s.Status, s.Reason, s.RevokedAt = "revoked", reason, &at })
+}
+
+// endSession applies end to the session with id if it is active.
+func (m *Memory) endSession(id string, end func(*model.Session)) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.sessions {
+		if s := &m.sessions[i]; s.ID == id && s.Status == "active" {
+			end(s)
+			s.UpdatedAt = time.Now()
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
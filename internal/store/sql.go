@@ -0,0 +1,627 @@
+package store
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"example.com/jit-engine/internal/model"
+	"example.com/jit-engine/internal/policy"
+)
+
+// SQL stores everything in a gorm database. Postgres and SQLite share it and
+// differ only where a query needs Postgres array or jsonb operators.
+type SQL struct {
+	db     *gorm.DB
+	sqlite bool
+	change Change
+}
+
+// NewPostgres uses db, migrated with cmd/migrate.
+func NewPostgres(db *gorm.DB) *SQL { return &SQL{db: db} }
+
+// DB exposes the underlying database for the handlers not yet behind a store.
+func (s *SQL) DB() *gorm.DB { return s.db }
+
+func (s *SQL) Transaction(fn func(PolicyStore) error) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&SQL{db: tx, sqlite: s.sqlite, change: s.change})
+	})
+}
+
+func (s *SQL) WithChange(c Change) PolicyStore {
+	return &SQL{db: s.db, sqlite: s.sqlite, change: c}
+}
+
+// policyColumns are the fields UpdatePolicy writes.
+var policyColumns = []string{"name", "effect", "provider", "resource", "match_kind", "exclude_resources", "actions", "expr", "metadata", "enabled", "priority", "version"}
+
+func (s *SQL) ListPolicies(f PolicyFilter) ([]model.Policy, error) {
+	q := s.db
+	if f.Name != "" {
+		if s.sqlite {
+			q = q.Where("name LIKE ?", "%"+f.Name+"%")
+		} else {
+			q = q.Where("name ILIKE ?", "%"+f.Name+"%")
+		}
+	}
+	if f.Effect != "" {
+		q = q.Where("effect = ?", f.Effect)
+	}
+	if f.Provider != "" {
+		q = q.Where("provider = ?", f.Provider)
+	}
+	if f.Enabled != nil {
+		q = q.Where("enabled = ?", *f.Enabled)
+	}
+	var ps []model.Policy
+	err := q.Order("priority asc, created_at asc").Find(&ps).Error
+	return ps, err
+}
+
+func (s *SQL) GetPolicy(id uuid.UUID) (model.Policy, error) {
+	var p model.Policy
+	err := s.db.First(&p, "id = ?", id).Error
+	return p, notFound(err)
+}
+
+func (s *SQL) CreatePolicy(p *model.Policy) error {
+	if s.sqlite && p.ID == uuid.Nil {
+		p.ID = uuid.New()
+	}
+	// A restored policy continues its history
+	latest, err := s.latestVersion(p.ID)
+	if err != nil {
+		return err
+	}
+	p.Version = nextVersion(0, latest)
+	if err := s.db.Create(p).Error; err != nil {
+		return err
+	}
+	return s.recordVersion(p.ID, p.Version, model.VersionCreate, *p)
+}
+
+func (s *SQL) RestorePolicy(p *model.Policy) error {
+	latest, err := s.latestVersion(p.ID)
+	if err != nil {
+		return err
+	}
+	p.Version = nextVersion(0, latest)
+	// The snapshot notices changes by UpdatedAt
+	p.UpdatedAt = time.Now()
+	if p.CreatedAt.IsZero() {
+		p.CreatedAt = p.UpdatedAt
+	}
+	enabled, priority := p.Enabled, p.Priority
+	if err := s.db.Create(p).Error; err != nil {
+		return err
+	}
+	// Create gives a false Enabled and a zero Priority the column defaults
+	if p.Enabled != enabled || p.Priority != priority {
+		p.Enabled, p.Priority = enabled, priority
+		if err := s.db.Model(p).UpdateColumns(map[string]any{"enabled": enabled, "priority": priority}).Error; err != nil {
+			return err
+		}
+	}
+	return s.recordVersion(p.ID, p.Version, model.VersionCreate, *p)
+}
+
+func (s *SQL) UpdatePolicy(p *model.Policy) error {
+	var existing model.Policy
+	if err := s.db.First(&existing, "id = ?", p.ID).Error; err != nil {
+		return notFound(err)
+	}
+	if err := s.change.checkVersion(existing.Version); err != nil {
+		return err
+	}
+	if sameContent(existing, *p) {
+		*p = existing
+		return nil
+	}
+	latest, err := s.latestVersion(p.ID)
+	if err != nil {
+		return err
+	}
+	p.Version = nextVersion(existing.Version, latest)
+	// The version read above guards the write against concurrent ones
+	res := s.db.Model(&existing).Where("version = ?", existing.Version).Select(policyColumns).Updates(p)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrVersionConflict
+	}
+	if err := s.db.First(p, "id = ?", p.ID).Error; err != nil {
+		return err
+	}
+	return s.recordVersion(p.ID, p.Version, model.VersionUpdate, *p)
+}
+
+func (s *SQL) DeletePolicy(id uuid.UUID) error {
+	var existing model.Policy
+	if err := s.db.First(&existing, "id = ?", id).Error; err != nil {
+		return notFound(err)
+	}
+	if err := s.change.checkVersion(existing.Version); err != nil {
+		return err
+	}
+	res := s.db.Delete(&model.Policy{}, "id = ? AND version = ?", id, existing.Version)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrVersionConflict
+	}
+	// Postgres cascades; SQLite is opened without foreign key enforcement.
+	if s.sqlite {
+		if err := s.db.Delete(&model.PolicyTestCase{}, "policy_id = ?", id).Error; err != nil {
+			return err
+		}
+	}
+	latest, err := s.latestVersion(id)
+	if err != nil {
+		return err
+	}
+	return s.recordVersion(id, nextVersion(existing.Version, latest), model.VersionDelete, existing)
+}
+
+func (s *SQL) PolicyVersions(id uuid.UUID) ([]model.PolicyVersion, error) {
+	var vs []model.PolicyVersion
+	err := s.db.Where("policy_id = ?", id).Order("version asc").Find(&vs).Error
+	return vs, err
+}
+
+func (s *SQL) PolicyVersion(id uuid.UUID, n int) (model.PolicyVersion, error) {
+	var v model.PolicyVersion
+	err := s.db.First(&v, "policy_id = ? AND version = ?", id, n).Error
+	return v, notFound(err)
+}
+
+func (s *SQL) latestVersion(id uuid.UUID) (int, error) {
+	var latest int
+	err := s.db.Model(&model.PolicyVersion{}).Where("policy_id = ?", id).
+		Select("COALESCE(MAX(version), 0)").Scan(&latest).Error
+	return latest, err
+}
+
+func (s *SQL) recordVersion(id uuid.UUID, n int, op string, p model.Policy) error {
+	v, err := newVersion(id, n, op, p, s.change)
+	if err != nil {
+		return err
+	}
+	if s.sqlite {
+		v.ID = uuid.New()
+	}
+	return s.db.Create(&v).Error
+}
+
+func (s *SQL) ResolveProvider(name string) (model.Provider, error) {
+	if s.sqlite {
+		// Aliases are a text-encoded array in SQLite; the registry is small.
+		ps, err := s.Providers()
+		if err != nil {
+			return model.Provider{}, err
+		}
+		return resolveProvider(ps, name)
+	}
+	var p model.Provider
+	err := s.db.Where("name = ? OR ? = ANY(aliases)", name, name).Order("name asc").First(&p).Error
+	return p, notFound(err)
+}
+
+func (s *SQL) EnabledPolicies() ([]model.Policy, error) {
+	var ps []model.Policy
+	err := s.db.Where("enabled = ?", true).Find(&ps).Error
+	return ps, err
+}
+
+func (s *SQL) EnabledSoDConstraints() ([]model.SoDConstraint, error) {
+	var cs []model.SoDConstraint
+	err := s.db.Where("enabled = ?", true).Order("created_at asc").Find(&cs).Error
+	return cs, err
+}
+
+func (s *SQL) ActionGroups() ([]model.ActionGroup, error) {
+	var gs []model.ActionGroup
+	err := s.db.Order("name asc").Find(&gs).Error
+	return gs, err
+}
+
+func (s *SQL) ActionHierarchies() ([]model.ActionHierarchy, error) {
+	var hs []model.ActionHierarchy
+	err := s.db.Order("provider asc, action asc").Find(&hs).Error
+	return hs, err
+}
+
+func (s *SQL) Providers() ([]model.Provider, error) {
+	var ps []model.Provider
+	err := s.db.Order("name asc").Find(&ps).Error
+	return ps, err
+}
+
+func (s *SQL) EnabledResolutionRules() ([]model.ResolutionRule, error) {
+	var rs []model.ResolutionRule
+	err := s.db.Where("enabled = ?", true).Order("priority asc, created_at asc").Find(&rs).Error
+	return rs, err
+}
+
+func (s *SQL) ActiveDelegations(delegate, action string, at time.Time) ([]model.Delegation, error) {
+	q := s.db.Where("delegate = ? AND revoked_at IS NULL AND expires_at > ?", delegate, at)
+	if !s.sqlite {
+		q = q.Where("? = ANY(actions)", action)
+	}
+	var ds []model.Delegation
+	if err := q.Order("created_at asc").Find(&ds).Error; err != nil {
+		return nil, err
+	}
+	if s.sqlite {
+		ds = delegationsFor(ds, action)
+	}
+	return ds, nil
+}
+
+func (s *SQL) EnabledTestCases() ([]model.PolicyTestCase, error) {
+	var tcs []model.PolicyTestCase
+	err := s.db.Where("enabled = ?", true).Order("created_at asc").Find(&tcs).Error
+	return tcs, err
+}
+
+func (s *SQL) RecordAudit(a *model.PolicyAudit) error {
+	if s.sqlite && a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	return s.db.Create(a).Error
+}
+
+func (s *SQL) ListAudits(f AuditFilter) ([]model.PolicyAudit, error) {
+	q := s.auditQuery(f)
+	if f.Limit > 0 {
+		q = q.Limit(f.Limit)
+	}
+	if f.Offset > 0 {
+		q = q.Offset(f.Offset)
+	}
+	var audits []model.PolicyAudit
+	err := q.Order("created_at desc, id").Find(&audits).Error
+	return audits, err
+}
+
+func (s *SQL) CountAudits(f AuditFilter) (int, error) {
+	var n int64
+	err := s.auditQuery(f).Model(&model.PolicyAudit{}).Count(&n).Error
+	return int(n), err
+}
+
+// auditQuery applies the conditions of f.
+func (s *SQL) auditQuery(f AuditFilter) *gorm.DB {
+	q := s.db
+	if f.Subject != "" {
+		if s.sqlite {
+			q = q.Where("json_extract(request, '$.subject.id') = ?", f.Subject)
+		} else {
+			q = q.Where("request->'subject'->>'id' = ?", f.Subject)
+		}
+	}
+	if f.Action != "" {
+		if s.sqlite {
+			q = q.Where("json_extract(request, '$.action') = ?", f.Action)
+		} else {
+			q = q.Where("request->>'action' = ?", f.Action)
+		}
+	}
+	if f.Decision != "" {
+		q = q.Where("decision = ?", f.Decision)
+	}
+	if f.Provider != "" {
+		if s.sqlite {
+			// Providers are stored in their Postgres text form, {"aws","ssh"}
+			q = q.Where(`',' || replace(trim(providers, '{}'), '"', '') || ',' LIKE ?`, "%,"+f.Provider+",%")
+		} else {
+			q = q.Where("? = ANY(providers)", f.Provider)
+		}
+	}
+	if !f.Since.IsZero() {
+		q = q.Where("created_at >= ?", f.Since)
+	}
+	if !f.Until.IsZero() {
+		q = q.Where("created_at < ?", f.Until)
+	}
+	if f.BreakGlass {
+		q = q.Where("break_glass = ?", true)
+	}
+	return q
+}
+
+func (s *SQL) LastAllow(subject, scopeKey, scope string, actions []string) (*model.PolicyAudit, error) {
+	q := s.db.Where("decision = ?", "allow")
+	if s.sqlite {
+		q = q.Where("json_extract(request, '$.subject.id') = ?", subject).
+			Where("CAST(json_extract(request, ?) AS TEXT) = ?", `$.metadata."`+strings.ReplaceAll(scopeKey, `"`, `\"`)+`"`, scope).
+			Where("json_extract(request, '$.action') IN ?", actions)
+	} else {
+		q = q.Where("request->'subject'->>'id' = ?", subject).
+			Where("request->'metadata'->>? = ?", scopeKey, scope).
+			Where("request->>'action' IN ?", actions)
+	}
+	var prior model.PolicyAudit
+	if err := q.Order("created_at desc").Limit(1).Find(&prior).Error; err != nil || prior.ID == uuid.Nil {
+		return nil, err
+	}
+	return &prior, nil
+}
+
+func (s *SQL) GetProvider(name string) (model.Provider, error) {
+	var p model.Provider
+	err := s.db.First(&p, "name = ?", name).Error
+	return p, notFound(err)
+}
+
+func (s *SQL) CreateProvider(p *model.Provider) error {
+	return s.db.Create(p).Error
+}
+
+func (s *SQL) UpdateProvider(p *model.Provider) error {
+	existing, err := s.GetProvider(p.Name)
+	if err != nil {
+		return err
+	}
+	if err := s.db.Model(&existing).Select("aliases", "resource_patterns", "actions", "default_decision", "fail_closed", "request_schema", "description").Updates(p).Error; err != nil {
+		return err
+	}
+	return s.db.First(p, "name = ?", p.Name).Error
+}
+
+func (s *SQL) DeleteProvider(name string) error {
+	var n int64
+	if err := s.db.Model(&model.Policy{}).Where("provider = ?", name).Count(&n).Error; err != nil {
+		return err
+	}
+	if n > 0 {
+		return ErrInUse
+	}
+	return rowDeleted(s.db.Delete(&model.Provider{}, "name = ?", name))
+}
+
+func (s *SQL) SaveActionGroup(g *model.ActionGroup) error {
+	return s.db.Save(g).Error
+}
+
+func (s *SQL) DeleteActionGroup(name string) error {
+	ref := policy.GroupPrefix + name
+	var n int64
+	if s.sqlite {
+		// Actions are a text-encoded array in SQLite.
+		ps, err := s.ListPolicies(PolicyFilter{})
+		if err != nil {
+			return err
+		}
+		for _, p := range ps {
+			if contains(p.Actions, ref) {
+				n++
+			}
+		}
+	} else if err := s.db.Model(&model.Policy{}).Where("? = ANY(actions)", ref).Count(&n).Error; err != nil {
+		return err
+	}
+	if n > 0 {
+		return ErrInUse
+	}
+	return rowDeleted(s.db.Delete(&model.ActionGroup{}, "name = ?", name))
+}
+
+func (s *SQL) SaveActionHierarchy(h *model.ActionHierarchy) error {
+	var existing model.ActionHierarchy
+	err := s.db.Where("provider = ? AND action = ?", h.Provider, h.Action).First(&existing).Error
+	switch {
+	case err == nil:
+		existing.Implies = h.Implies
+		if err := s.db.Model(&existing).Update("implies", existing.Implies).Error; err != nil {
+			return err
+		}
+		*h = existing
+		return nil
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		if s.sqlite && h.ID == uuid.Nil {
+			h.ID = uuid.New()
+		}
+		return s.db.Create(h).Error
+	}
+	return err
+}
+
+func (s *SQL) DeleteActionHierarchy(id uuid.UUID) error {
+	return rowDeleted(s.db.Delete(&model.ActionHierarchy{}, "id = ?", id))
+}
+
+func (s *SQL) ResolutionRules() ([]model.ResolutionRule, error) {
+	var rs []model.ResolutionRule
+	err := s.db.Order("priority asc, created_at asc").Find(&rs).Error
+	return rs, err
+}
+
+func (s *SQL) CreateResolutionRule(r *model.ResolutionRule) error {
+	if s.sqlite && r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return s.db.Create(r).Error
+}
+
+func (s *SQL) UpdateResolutionRule(r *model.ResolutionRule) error {
+	var existing model.ResolutionRule
+	if err := s.db.First(&existing, "id = ?", r.ID).Error; err != nil {
+		return notFound(err)
+	}
+	if err := s.db.Model(&existing).Select("name", "priority", "match", "expr", "providers", "combine", "enabled").Updates(r).Error; err != nil {
+		return err
+	}
+	return s.db.First(r, "id = ?", r.ID).Error
+}
+
+func (s *SQL) DeleteResolutionRule(id uuid.UUID) error {
+	return rowDeleted(s.db.Delete(&model.ResolutionRule{}, "id = ?", id))
+}
+
+func (s *SQL) SoDConstraints(kind string) ([]model.SoDConstraint, error) {
+	q := s.db
+	if kind != "" {
+		q = q.Where("kind = ?", kind)
+	}
+	var cs []model.SoDConstraint
+	err := q.Order("created_at asc").Find(&cs).Error
+	return cs, err
+}
+
+func (s *SQL) CreateSoDConstraint(c *model.SoDConstraint) error {
+	if s.sqlite && c.ID == uuid.Nil {
+		c.ID = uuid.New()
+	}
+	return s.db.Create(c).Error
+}
+
+func (s *SQL) DeleteSoDConstraint(id uuid.UUID) error {
+	return rowDeleted(s.db.Delete(&model.SoDConstraint{}, "id = ?", id))
+}
+
+func (s *SQL) TestCases(policyID *uuid.UUID, provider string) ([]model.PolicyTestCase, error) {
+	q := s.db
+	if policyID != nil {
+		q = q.Where("policy_id = ?", *policyID)
+	}
+	if provider != "" {
+		q = q.Where("provider = ?", provider)
+	}
+	var tcs []model.PolicyTestCase
+	err := q.Order("created_at asc").Find(&tcs).Error
+	return tcs, err
+}
+
+func (s *SQL) CreateTestCase(tc *model.PolicyTestCase) error {
+	if s.sqlite && tc.ID == uuid.Nil {
+		tc.ID = uuid.New()
+	}
+	return s.db.Create(tc).Error
+}
+
+func (s *SQL) UpdateTestCase(tc *model.PolicyTestCase) error {
+	var existing model.PolicyTestCase
+	if err := s.db.First(&existing, "id = ?", tc.ID).Error; err != nil {
+		return notFound(err)
+	}
+	if err := s.db.Model(&existing).Select("name", "policy_id", "provider", "request", "expect_decision", "expect_matched", "expect_reason", "enabled").Updates(tc).Error; err != nil {
+		return err
+	}
+	return s.db.First(tc, "id = ?", tc.ID).Error
+}
+
+func (s *SQL) DeleteTestCase(id uuid.UUID) error {
+	return rowDeleted(s.db.Delete(&model.PolicyTestCase{}, "id = ?", id))
+}
+
+func (s *SQL) Delegations(f DelegationFilter) ([]model.Delegation, error) {
+	q := s.db
+	if f.Delegator != "" {
+		q = q.Where("delegator = ?", f.Delegator)
+	}
+	if f.Delegate != "" {
+		q = q.Where("delegate = ?", f.Delegate)
+	}
+	if !f.ActiveAt.IsZero() {
+		q = q.Where("revoked_at IS NULL AND expires_at > ?", f.ActiveAt)
+	}
+	var ds []model.Delegation
+	err := q.Order("created_at asc").Find(&ds).Error
+	return ds, err
+}
+
+func (s *SQL) CreateDelegation(d *model.Delegation) error {
+	if s.sqlite && d.ID == uuid.Nil {
+		d.ID = uuid.New()
+	}
+	return s.db.Create(d).Error
+}
+
+func (s *SQL) RevokeDelegation(id uuid.UUID, at time.Time) (bool, error) {
+	var d model.Delegation
+	if err := s.db.First(&d, "id = ?", id).Error; err != nil {
+		return false, notFound(err)
+	}
+	res := s.db.Model(&model.Delegation{}).Where("id = ? AND revoked_at IS NULL", id).Update("revoked_at", at)
+	return res.RowsAffected > 0, res.Error
+}
+
+func (s *SQL) Sessions(f SessionFilter) ([]model.Session, error) {
+	q := s.db
+	if f.Status != "" {
+		q = q.Where("status = ?", f.Status)
+	}
+	if f.SubjectID != "" {
+		q = q.Where("subject_id = ?", f.SubjectID)
+	}
+	var ss []model.Session
+	err := q.Order("created_at desc").Find(&ss).Error
+	return ss, err
+}
+
+func (s *SQL) CreateSession(sess *model.Session) error {
+	return s.db.Create(sess).Error
+}
+
+func (s *SQL) CloseSession(id string) (bool, error) {
+	res := s.db.Model(&model.Session{}).Where("id = ? AND status = ?", id, "active").Update("status", "closed")
+	return res.RowsAffected > 0, res.Error
+}
+
+func (s *SQL) RevokeSession(id, reason string, at time.Time) (bool, error) {
+	res := s.db.Model(&model.Session{}).Where("id = ? AND status = ?", id, "active").
+		Updates(map[string]any{"status": "revoked", "reason": reason, "revoked_at": at})
+	return res.RowsAffected > 0, res.Error
+}
+
+// rowDeleted turns a delete that matched nothing into ErrNotFound.
+func rowDeleted(res *gorm.DB) error {
+	if res.Error == nil && res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return res.Error
+}
+
+func notFound(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// resolveProvider finds name among ps by name, then by alias.
+func resolveProvider(ps []model.Provider, name string) (model.Provider, error) {
+	for _, p := range ps {
+		if p.Name == name {
+			return p, nil
+		}
+	}
+	for _, p := range ps {
+		for _, a := range p.Aliases {
+			if a == name {
+				return p, nil
+			}
+		}
+	}
+	return model.Provider{}, ErrNotFound
+}
+
+// delegationsFor keeps the delegations whose actions include action.
+func delegationsFor(ds []model.Delegation, action string) []model.Delegation {
+	out := ds[:0]
+	for _, d := range ds {
+		for _, a := range d.Actions {
+			if a == action {
+				out = append(out, d)
+				break
+			}
+		}
+	}
+	return out
+}
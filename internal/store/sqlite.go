@@ -0,0 +1,180 @@
+package store
+
+import (
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// sqliteSchema mirrors the Postgres tables the stores use. IDs are generated
+// in Go, arrays are stored in their Postgres text form and JSON as text.
+var sqliteSchema = []string{
+	`CREATE TABLE IF NOT EXISTS policies (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		effect TEXT NOT NULL,
+		provider TEXT NOT NULL DEFAULT 'global',
+		resource TEXT NOT NULL,
+		match_kind TEXT NOT NULL DEFAULT 'glob',
+		exclude_resources TEXT,
+		actions TEXT,
+		condition TEXT,
+		expr TEXT,
+		metadata TEXT,
+		enabled BOOLEAN DEFAULT 1,
+		priority INTEGER DEFAULT 100,
+		version INTEGER DEFAULT 1,
+		created_at DATETIME,
+		updated_at DATETIME
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_policies_provider ON policies (provider, enabled)`,
+	`CREATE TABLE IF NOT EXISTS policy_audits (
+		id TEXT PRIMARY KEY,
+		request TEXT,
+		decision TEXT,
+		matched_id TEXT,
+		trace TEXT,
+		severity TEXT NOT NULL DEFAULT 'info',
+		break_glass BOOLEAN NOT NULL DEFAULT 0,
+		providers TEXT,
+		created_at DATETIME
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_policy_audits_created_at ON policy_audits (created_at)`,
+	`CREATE TABLE IF NOT EXISTS sod_constraints (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		kind TEXT NOT NULL,
+		attribute TEXT,
+		"values" TEXT,
+		actions TEXT,
+		scope_key TEXT,
+		resource TEXT,
+		message TEXT,
+		enabled BOOLEAN DEFAULT 1,
+		created_at DATETIME,
+		updated_at DATETIME
+	)`,
+	`CREATE TABLE IF NOT EXISTS action_groups (
+		name TEXT PRIMARY KEY,
+		actions TEXT NOT NULL,
+		description TEXT,
+		created_at DATETIME,
+		updated_at DATETIME
+	)`,
+	`CREATE TABLE IF NOT EXISTS action_hierarchies (
+		id TEXT PRIMARY KEY,
+		provider TEXT NOT NULL,
+		action TEXT NOT NULL,
+		implies TEXT NOT NULL,
+		created_at DATETIME,
+		updated_at DATETIME,
+		UNIQUE (provider, action)
+	)`,
+	`CREATE TABLE IF NOT EXISTS providers (
+		name TEXT PRIMARY KEY,
+		aliases TEXT,
+		resource_patterns TEXT,
+		actions TEXT,
+		default_decision TEXT NOT NULL DEFAULT 'deny',
+		fail_closed BOOLEAN,
+		request_schema TEXT,
+		description TEXT,
+		created_at DATETIME,
+		updated_at DATETIME
+	)`,
+	`CREATE TABLE IF NOT EXISTS resolution_rules (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		priority INTEGER DEFAULT 100,
+		"match" TEXT,
+		expr TEXT,
+		providers TEXT NOT NULL,
+		combine TEXT NOT NULL DEFAULT 'all',
+		enabled BOOLEAN DEFAULT 1,
+		created_at DATETIME,
+		updated_at DATETIME
+	)`,
+	`CREATE TABLE IF NOT EXISTS policy_test_cases (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		policy_id TEXT,
+		provider TEXT,
+		request TEXT NOT NULL,
+		expect_decision TEXT NOT NULL,
+		expect_matched TEXT,
+		expect_reason TEXT,
+		enabled BOOLEAN DEFAULT 1,
+		created_at DATETIME,
+		updated_at DATETIME
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_policy_test_cases_policy_id ON policy_test_cases (policy_id)`,
+	`CREATE TABLE IF NOT EXISTS policy_versions (
+		id TEXT PRIMARY KEY,
+		policy_id TEXT NOT NULL,
+		version INTEGER NOT NULL,
+		operation TEXT NOT NULL,
+		policy TEXT NOT NULL,
+		author TEXT,
+		reason TEXT,
+		restored_from INTEGER,
+		created_at DATETIME,
+		UNIQUE (policy_id, version)
+	)`,
+	`CREATE TRIGGER IF NOT EXISTS policy_versions_immutable_update BEFORE UPDATE ON policy_versions
+		BEGIN SELECT RAISE(ABORT, 'policy versions are immutable'); END`,
+	`CREATE TRIGGER IF NOT EXISTS policy_versions_immutable_delete BEFORE DELETE ON policy_versions
+		BEGIN SELECT RAISE(ABORT, 'policy versions are immutable'); END`,
+	`CREATE TABLE IF NOT EXISTS delegations (
+		id TEXT PRIMARY KEY,
+		delegator TEXT NOT NULL,
+		delegator_subject TEXT,
+		delegate TEXT NOT NULL,
+		resource TEXT NOT NULL,
+		actions TEXT,
+		reason TEXT,
+		expires_at DATETIME NOT NULL,
+		revoked_at DATETIME,
+		created_at DATETIME
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_delegations_delegate ON delegations (delegate)`,
+	`CREATE TABLE IF NOT EXISTS sessions (
+		id TEXT PRIMARY KEY,
+		subject_id TEXT,
+		resource TEXT NOT NULL,
+		action TEXT NOT NULL,
+		request TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'active',
+		reason TEXT,
+		revoked_at DATETIME,
+		created_at DATETIME,
+		updated_at DATETIME
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_sessions_status ON sessions (status)`,
+}
+
+// OpenSQLite opens, creating if needed, a SQLite database at path (":memory:"
+// for a private in-memory one) and registers the default providers. It needs
+// no cgo.
+func OpenSQLite(path string) (*SQL, error) {
+	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		return nil, err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	// One connection keeps ":memory:" databases shared and serialises writers.
+	sqlDB.SetMaxOpenConns(1)
+	for _, stmt := range sqliteSchema {
+		if err := db.Exec(stmt).Error; err != nil {
+			return nil, err
+		}
+	}
+	for _, p := range DefaultProviders() {
+		if err := db.Where("name = ?", p.Name).FirstOrCreate(&p).Error; err != nil {
+			return nil, err
+		}
+	}
+	return &SQL{db: db, sqlite: true}, nil
+}
@@ -0,0 +1,267 @@
+// Package store abstracts where policies and audit records live so the engine
+// can run against Postgres, SQLite or plain memory.
+package store
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"example.com/jit-engine/internal/model"
+)
+
+// ErrNotFound is returned when a requested record does not exist.
+var ErrNotFound = errors.New("not found")
+
+// ErrInUse is returned when deleting a record that policies still refer to.
+var ErrInUse = errors.New("in use")
+
+// ErrVersionConflict is returned when a policy write finds the policy at a
+// version other than the one it was based on.
+var ErrVersionConflict = errors.New("policy was modified concurrently")
+
+// PolicyFilter narrows ListPolicies; zero fields do not filter.
+type PolicyFilter struct {
+	// Name matches case-insensitively anywhere in the policy name.
+	Name     string
+	Effect   string
+	Provider string
+	Enabled  *bool
+}
+
+// PolicyStore holds policies and everything evaluation reads alongside them.
+type PolicyStore interface {
+	ListPolicies(f PolicyFilter) ([]model.Policy, error)
+	GetPolicy(id uuid.UUID) (model.Policy, error)
+	// CreatePolicy validates and stores p, filling in its ID, version and
+	// timestamps, and records the version. A false Enabled and a zero
+	// Priority take the column defaults, true and 100, as fields omitted
+	// from the API do; CreatePolicyAsIs keeps them.
+	CreatePolicy(p *model.Policy) error
+	// UpdatePolicy validates and replaces the editable fields of the policy
+	// with p.ID, keeping CreatedAt, and reloads p from the store. A write
+	// that changes something increments the version and records it. It
+	// fails with ErrVersionConflict when the version changed since it was
+	// read, or differs from the change's IfVersion.
+	UpdatePolicy(p *model.Policy) error
+	// DeletePolicy deletes the policy and the test cases attached to it and
+	// records the deletion. It fails with ErrVersionConflict like
+	// UpdatePolicy.
+	DeletePolicy(id uuid.UUID) error
+	// RestorePolicy recreates the deleted policy p.ID as p in one write,
+	// keeping its CreatedAt, Enabled and Priority as given, and records one
+	// version continuing the policy's history.
+	RestorePolicy(p *model.Policy) error
+	// PolicyVersions returns the recorded versions of a policy, oldest
+	// first. They outlive the policy.
+	PolicyVersions(id uuid.UUID) ([]model.PolicyVersion, error)
+	// PolicyVersion returns version n of a policy.
+	PolicyVersion(id uuid.UUID, n int) (model.PolicyVersion, error)
+	// WithChange returns a view of the store whose policy writes record c.
+	WithChange(c Change) PolicyStore
+
+	// ResolveProvider returns the provider registered as name or carrying
+	// name as an alias.
+	ResolveProvider(name string) (model.Provider, error)
+
+	EnabledPolicies() ([]model.Policy, error)
+	// EnabledSoDConstraints are returned oldest first.
+	EnabledSoDConstraints() ([]model.SoDConstraint, error)
+	ActionGroups() ([]model.ActionGroup, error)
+	ActionHierarchies() ([]model.ActionHierarchy, error)
+	// Providers are returned by name.
+	Providers() ([]model.Provider, error)
+	// EnabledResolutionRules are returned in evaluation order.
+	EnabledResolutionRules() ([]model.ResolutionRule, error)
+	// ActiveDelegations returns unrevoked delegations to delegate covering
+	// action that have not expired at at, oldest first.
+	ActiveDelegations(delegate, action string, at time.Time) ([]model.Delegation, error)
+	// EnabledTestCases are returned oldest first.
+	EnabledTestCases() ([]model.PolicyTestCase, error)
+}
+
+// CreatePolicyAsIs creates p keeping a false Enabled and a zero Priority,
+// which take effect in a second, recorded write.
+func CreatePolicyAsIs(s PolicyStore, p *model.Policy) error {
+	enabled, priority := p.Enabled, p.Priority
+	if err := s.CreatePolicy(p); err != nil {
+		return err
+	}
+	if p.Enabled == enabled && p.Priority == priority {
+		return nil
+	}
+	p.Enabled, p.Priority = enabled, priority
+	return s.UpdatePolicy(p)
+}
+
+// Transactor is implemented by stores that can apply several policy writes
+// atomically. fn receives a store scoped to the transaction; an error from fn
+// discards every write it made.
+type Transactor interface {
+	Transaction(fn func(PolicyStore) error) error
+}
+
+// AuditFilter narrows ListAudits; zero fields do not filter.
+type AuditFilter struct {
+	// Subject matches the request's subject.id.
+	Subject  string
+	Action   string
+	Decision string
+	// Provider matches audits whose request was resolved to it.
+	Provider string
+	Since    time.Time
+	Until    time.Time
+	// BreakGlass keeps only break-glass requests.
+	BreakGlass bool
+	// Limit caps the audits returned; zero returns all. Offset skips that
+	// many matching audits first.
+	Limit  int
+	Offset int
+}
+
+// AuditStore records decisions and answers the history queries evaluation needs.
+type AuditStore interface {
+	RecordAudit(a *model.PolicyAudit) error
+	// ListAudits returns the audits matching f, newest first.
+	ListAudits(f AuditFilter) ([]model.PolicyAudit, error)
+	// CountAudits counts the audits matching f, ignoring Limit and Offset.
+	CountAudits(f AuditFilter) (int, error)
+	// LastAllow returns the most recent allow of one of actions to subject
+	// whose request metadata[scopeKey] equals scope, or nil.
+	LastAllow(subject, scopeKey, scope string, actions []string) (*model.PolicyAudit, error)
+}
+
+// ProviderStore manages the provider registry.
+type ProviderStore interface {
+	// Providers are returned by name.
+	Providers() ([]model.Provider, error)
+	ResolveProvider(name string) (model.Provider, error)
+	GetProvider(name string) (model.Provider, error)
+	CreateProvider(p *model.Provider) error
+	// UpdateProvider replaces the definition of the provider named p.Name and
+	// reloads p from the store.
+	UpdateProvider(p *model.Provider) error
+	// DeleteProvider fails with ErrInUse while policies are filed under it.
+	DeleteProvider(name string) error
+}
+
+// ActionStore manages action groups and action hierarchies.
+type ActionStore interface {
+	// ActionGroups are returned by name.
+	ActionGroups() ([]model.ActionGroup, error)
+	// SaveActionGroup creates or replaces the group named g.Name.
+	SaveActionGroup(g *model.ActionGroup) error
+	// DeleteActionGroup fails with ErrInUse while policies reference it.
+	DeleteActionGroup(name string) error
+	// ActionHierarchies are returned by provider and action.
+	ActionHierarchies() ([]model.ActionHierarchy, error)
+	// SaveActionHierarchy creates the hierarchy for h's provider and action,
+	// or replaces what an existing one implies, and reloads h from the store.
+	SaveActionHierarchy(h *model.ActionHierarchy) error
+	DeleteActionHierarchy(id uuid.UUID) error
+}
+
+// ResolutionRuleStore manages the rules that map requests to providers.
+type ResolutionRuleStore interface {
+	// ResolutionRules are returned in evaluation order, disabled ones included.
+	ResolutionRules() ([]model.ResolutionRule, error)
+	CreateResolutionRule(r *model.ResolutionRule) error
+	// UpdateResolutionRule replaces the rule with r.ID and reloads r.
+	UpdateResolutionRule(r *model.ResolutionRule) error
+	DeleteResolutionRule(id uuid.UUID) error
+}
+
+// SoDStore manages separation-of-duties constraints.
+type SoDStore interface {
+	// SoDConstraints returns the constraints of kind, or all when kind is
+	// empty, oldest first.
+	SoDConstraints(kind string) ([]model.SoDConstraint, error)
+	CreateSoDConstraint(c *model.SoDConstraint) error
+	DeleteSoDConstraint(id uuid.UUID) error
+}
+
+// TestCaseStore manages policy regression test cases.
+type TestCaseStore interface {
+	// TestCases returns the cases of policyID and provider, when given,
+	// oldest first.
+	TestCases(policyID *uuid.UUID, provider string) ([]model.PolicyTestCase, error)
+	CreateTestCase(tc *model.PolicyTestCase) error
+	// UpdateTestCase replaces the case with tc.ID and reloads tc.
+	UpdateTestCase(tc *model.PolicyTestCase) error
+	DeleteTestCase(id uuid.UUID) error
+}
+
+// DelegationFilter narrows Delegations; zero fields do not filter.
+type DelegationFilter struct {
+	Delegator string
+	Delegate  string
+	// ActiveAt keeps delegations unrevoked and unexpired at that time.
+	ActiveAt time.Time
+}
+
+// DelegationStore manages delegations.
+type DelegationStore interface {
+	// Delegations are returned oldest first.
+	Delegations(f DelegationFilter) ([]model.Delegation, error)
+	CreateDelegation(d *model.Delegation) error
+	// RevokeDelegation marks the delegation revoked at at. It reports false
+	// when it already was.
+	RevokeDelegation(id uuid.UUID, at time.Time) (bool, error)
+}
+
+// SessionFilter narrows Sessions; zero fields do not filter.
+type SessionFilter struct {
+	Status    string
+	SubjectID string
+}
+
+// SessionStore holds the sessions the registry re-evaluates.
+type SessionStore interface {
+	// Sessions are returned newest first.
+	Sessions(f SessionFilter) ([]model.Session, error)
+	CreateSession(s *model.Session) error
+	// CloseSession and RevokeSession change active sessions only and report
+	// whether the session was active.
+	CloseSession(id string) (bool, error)
+	RevokeSession(id, reason string, at time.Time) (bool, error)
+}
+
+// Store is everything the server keeps. The SQL and in-memory stores
+// implement it.
+type Store interface {
+	PolicyStore
+	Transactor
+	AuditStore
+	ProviderStore
+	ActionStore
+	ResolutionRuleStore
+	SoDStore
+	TestCaseStore
+	DelegationStore
+	SessionStore
+}
+
+// DefaultProviders are registered in new SQLite and in-memory stores; the
+// Postgres migrations seed the same list.
+func DefaultProviders() []model.Provider {
+	p := func(name, description string, aliases ...string) model.Provider {
+		return model.Provider{Name: name, Aliases: aliases, DefaultDecision: "deny", Description: description}
+	}
+	return []model.Provider{
+		p("global", "Guardrails evaluated before every provider"),
+		p("breakglass", "Who may request emergency access"),
+		p("aws", "Amazon Web Services"),
+		p("gcp", "Google Cloud"),
+		p("azure", "Microsoft Azure"),
+		p("database", "Databases", "db"),
+		p("ssh", "SSH on Unix/Linux"),
+		p("rdp", "RDP on Windows"),
+		p("web", "Web applications", "http", "https"),
+		p("network", "Routers, switches and firewalls"),
+		p("storage", "Storage systems"),
+		p("client", "Thick clients"),
+		p("mail", "Mail systems"),
+		p("hypervisor", "Hypervisor consoles"),
+	}
+}
@@ -0,0 +1,91 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/google/uuid"
+
+	"example.com/jit-engine/internal/model"
+)
+
+// Change describes who makes policy writes, why, and which version they are
+// based on. Stores record the author and reason in the versions the writes
+// create.
+type Change struct {
+	Author string
+	Reason string
+	// RestoredFrom is the version a rollback restores.
+	RestoredFrom int
+	// IfVersion, when set, is the version updates and deletes expect the
+	// policy to be at.
+	IfVersion int
+}
+
+// checkVersion enforces c.IfVersion against the stored version.
+func (c Change) checkVersion(current int) error {
+	if c.IfVersion > 0 && c.IfVersion != current {
+		return ErrVersionConflict
+	}
+	return nil
+}
+
+// newVersion records p as version n of policy id. Writes made by a rollback
+// are restores.
+func newVersion(id uuid.UUID, n int, op string, p model.Policy, c Change) (model.PolicyVersion, error) {
+	if c.RestoredFrom > 0 && op != model.VersionDelete {
+		op = model.VersionRestore
+	}
+	data, err := json.Marshal(p)
+	if err != nil {
+		return model.PolicyVersion{}, err
+	}
+	v := model.PolicyVersion{PolicyID: id, Version: n, Operation: op, Policy: data, Author: c.Author, Reason: c.Reason}
+	if c.RestoredFrom > 0 {
+		from := c.RestoredFrom
+		v.RestoredFrom = &from
+	}
+	return v, nil
+}
+
+// nextVersion numbers the write after current, the stored policy's version,
+// and latest, the highest recorded one. Policies written before history was
+// kept have no recorded versions.
+func nextVersion(current, latest int) int {
+	return max(current, latest) + 1
+}
+
+// sameContent reports whether a and b differ in none of the fields an update
+// writes, other than the version. Updates that change nothing record no
+// version.
+func sameContent(a, b model.Policy) bool {
+	return a.Name == b.Name && a.Effect == b.Effect && a.Provider == b.Provider &&
+		a.Resource == b.Resource && a.MatchKind == b.MatchKind &&
+		sameStrings(a.ExcludeResources, b.ExcludeResources) && sameStrings(a.Actions, b.Actions) &&
+		a.Expr == b.Expr && sameJSON(a.Metadata, b.Metadata) &&
+		a.Enabled == b.Enabled && a.Priority == b.Priority
+}
+
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// sameJSON compares documents ignoring formatting; empty and null are equal.
+func sameJSON(a, b []byte) bool {
+	var va, vb any
+	if len(bytes.TrimSpace(a)) > 0 && json.Unmarshal(a, &va) != nil ||
+		len(bytes.TrimSpace(b)) > 0 && json.Unmarshal(b, &vb) != nil {
+		return bytes.Equal(a, b)
+	}
+	ja, _ := json.Marshal(va)
+	jb, _ := json.Marshal(vb)
+	return bytes.Equal(ja, jb)
+}
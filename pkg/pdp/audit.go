@@ -0,0 +1,141 @@
+package pdp
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+
+	"example.com/jit-engine/internal/model"
+	"example.com/jit-engine/internal/store"
+)
+
+// AuditRecord is one recorded decision.
+type AuditRecord struct {
+	ID         uuid.UUID   `json:"id"`
+	Request    Request     `json:"request"`
+	Decision   string      `json:"decision"`
+	Matched    *uuid.UUID  `json:"matched,omitempty"`
+	Trace      []TraceItem `json:"trace"`
+	Providers  []string    `json:"providers,omitempty"`
+	Severity   string      `json:"severity"`
+	BreakGlass bool        `json:"break_glass,omitempty"`
+	Time       time.Time   `json:"time"`
+}
+
+// AuditSink receives every decision an Engine makes. Record is called
+// synchronously; an error is not returned to the caller of Evaluate.
+type AuditSink interface {
+	Record(r *AuditRecord) error
+}
+
+// AuditHistory is implemented by sinks that can answer the history query
+// dynamic separation-of-duties constraints need: the latest allow of one of
+// actions to subject whose request metadata[scopeKey] equals scope. Without
+// it those constraints never find an earlier allow.
+type AuditHistory interface {
+	LastAllow(subject, scopeKey, scope string, actions []string) (*AuditRecord, error)
+}
+
+// AuditFunc adapts a function to AuditSink.
+type AuditFunc func(r *AuditRecord) error
+
+func (f AuditFunc) Record(r *AuditRecord) error { return f(r) }
+
+// SQLiteAudit records decisions in the SQLite database at path, creating it
+// if needed. Dynamic separation-of-duties constraints query its history.
+func SQLiteAudit(path string) (AuditSink, error) {
+	s, err := store.OpenSQLite(path)
+	if err != nil {
+		return nil, err
+	}
+	return storeSink{s}, nil
+}
+
+// storeSink is a sink backed by an internal audit store, used as is.
+type storeSink struct{ store.AuditStore }
+
+func (s storeSink) Record(r *AuditRecord) error {
+	a := toModel(r)
+	if err := s.RecordAudit(&a); err != nil {
+		return err
+	}
+	r.ID, r.Time = a.ID, a.CreatedAt
+	return nil
+}
+
+func auditStore(s AuditSink) store.AuditStore {
+	if ss, ok := s.(storeSink); ok {
+		return ss.AuditStore
+	}
+	return sinkStore{s}
+}
+
+// sinkStore adapts an AuditSink to the engine's audit store.
+type sinkStore struct{ sink AuditSink }
+
+func (s sinkStore) RecordAudit(a *model.PolicyAudit) error {
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	if a.Severity == "" {
+		a.Severity = "info"
+	}
+	a.CreatedAt = time.Now()
+	r := &AuditRecord{
+		ID:         a.ID,
+		Decision:   a.Decision,
+		Matched:    a.MatchedID,
+		Providers:  a.Providers,
+		Severity:   a.Severity,
+		BreakGlass: a.BreakGlass,
+		Time:       a.CreatedAt,
+	}
+	_ = json.Unmarshal(a.Request, &r.Request)
+	_ = json.Unmarshal(a.Trace, &r.Trace)
+	return s.sink.Record(r)
+}
+
+func (s sinkStore) LastAllow(subject, scopeKey, scope string, actions []string) (*model.PolicyAudit, error) {
+	h, ok := s.sink.(AuditHistory)
+	if !ok {
+		return nil, nil
+	}
+	r, err := h.LastAllow(subject, scopeKey, scope, actions)
+	if err != nil || r == nil {
+		return nil, err
+	}
+	a := toModel(r)
+	return &a, nil
+}
+
+// ListAudits returns nothing: sinks are write-only.
+func (s sinkStore) ListAudits(store.AuditFilter) ([]model.PolicyAudit, error) { return nil, nil }
+
+func (s sinkStore) CountAudits(store.AuditFilter) (int, error) { return 0, nil }
+
+func toModel(r *AuditRecord) model.PolicyAudit {
+	rb, _ := json.Marshal(r.Request)
+	tb, _ := json.Marshal(r.Trace)
+	return model.PolicyAudit{
+		ID:         r.ID,
+		Request:    rb,
+		Decision:   r.Decision,
+		MatchedID:  r.Matched,
+		Trace:      tb,
+		Severity:   r.Severity,
+		BreakGlass: r.BreakGlass,
+		Providers:  r.Providers,
+		CreatedAt:  r.Time,
+	}
+}
+
+// discard drops decisions.
+type discard struct{}
+
+func (discard) RecordAudit(*model.PolicyAudit) error { return nil }
+func (discard) LastAllow(string, string, string, []string) (*model.PolicyAudit, error) {
+	return nil, nil
+}
+func (discard) ListAudits(store.AuditFilter) ([]model.PolicyAudit, error) { return nil, nil }
+func (discard) CountAudits(store.AuditFilter) (int, error)                { return 0, nil }
@@ -0,0 +1,173 @@
+// Package pdp embeds the policy decision point in a Go program. An Engine
+// evaluates requests in-process with the same semantics as the server's
+// /evaluate endpoint, reading policies from a Source instead of Postgres:
+//
+//	eng, err := pdp.New(pdp.BundleFile("policies.bundle", key))
+//	res, err := eng.Evaluate(pdp.Request{Subject: subject, Resource: "ssh:unix:host/a", Action: "login", Protocol: "ssh"})
+//
+// Decisions are recorded through an optional AuditSink.
+package pdp
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"example.com/jit-engine/internal/eval"
+	"example.com/jit-engine/internal/model"
+	"example.com/jit-engine/internal/store"
+)
+
+// The request, result and policy types are shared with the server, so JSON
+// produced by one is read by the other.
+type (
+	Request         = eval.Request
+	Result          = eval.Result
+	TraceItem       = eval.TraceItem
+	Obligations     = eval.Obligations
+	Policy          = model.Policy
+	Provider        = model.Provider
+	ActionGroup     = model.ActionGroup
+	ActionHierarchy = model.ActionHierarchy
+	ResolutionRule  = model.ResolutionRule
+	SoDConstraint   = model.SoDConstraint
+)
+
+// Decisions.
+const (
+	Allow = "allow"
+	Deny  = "deny"
+)
+
+// Option configures an Engine.
+type Option func(*config)
+
+type config struct {
+	failClosed bool
+	audits     store.AuditStore
+	cacheSize  int
+	cacheTTL   time.Duration
+	maxChain   *int
+	logf       func(format string, args ...any)
+}
+
+// WithFailClosed sets what happens when a policy cannot be evaluated or the
+// policies cannot be read. True, the default, denies the request. False
+// allows the whole request and returns the error with the allow; only an
+// expression that yields a non-boolean is allowed without an error. A
+// provider's fail_closed setting takes precedence. Allowed denies on any
+// error either way.
+func WithFailClosed(v bool) Option {
+	return func(c *config) { c.failClosed = v }
+}
+
+// WithAuditSink records every decision in s.
+func WithAuditSink(s AuditSink) Option {
+	return func(c *config) { c.audits = auditStore(s) }
+}
+
+// WithDecisionCache caches up to size decisions for ttl; zero ttl is 30
+// seconds. Refresh drops the cache when it loads changed policies.
+func WithDecisionCache(size int, ttl time.Duration) Option {
+	return func(c *config) { c.cacheSize, c.cacheTTL = size, ttl }
+}
+
+// WithDelegationMaxChain bounds delegation chains; zero disables delegation.
+func WithDelegationMaxChain(n int) Option {
+	return func(c *config) { c.maxChain = &n }
+}
+
+// WithLogger sets where refresh errors are logged; the default is the log
+// package.
+func WithLogger(logf func(format string, args ...any)) Option {
+	return func(c *config) { c.logf = logf }
+}
+
+// Engine is an embedded decision point. It is safe for concurrent use.
+type Engine struct {
+	src Source
+	cfg config
+
+	mu      sync.Mutex // serialises Refresh
+	cur     atomic.Pointer[eval.EvalEngine]
+	version atomic.Value
+}
+
+// New loads src and returns an engine evaluating it.
+func New(src Source, opts ...Option) (*Engine, error) {
+	if src.fetch == nil {
+		return nil, errors.New("pdp: no source")
+	}
+	e := &Engine{src: src, cfg: config{failClosed: true, audits: discard{}, logf: log.Printf}}
+	for _, o := range opts {
+		o(&e.cfg)
+	}
+	changed, err := e.Refresh(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	if !changed {
+		return nil, errors.New("pdp: source returned no policies")
+	}
+	return e, nil
+}
+
+// Evaluate decides req and records the decision in the audit sink.
+func (e *Engine) Evaluate(req Request) (Result, error) {
+	return e.cur.Load().EvaluateAndAudit(req)
+}
+
+// Allowed reports whether req is allowed. Errors deny.
+func (e *Engine) Allowed(req Request) bool {
+	res, err := e.Evaluate(req)
+	return err == nil && res.Decision == Allow
+}
+
+// Version is the version of the policies in use, as reported by the source.
+func (e *Engine) Version() string {
+	v, _ := e.version.Load().(string)
+	return v
+}
+
+// Refresh reloads the source and swaps in the new policies when they
+// changed. On error the current policies stay in use.
+func (e *Engine) Refresh(ctx context.Context) (bool, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	snap, err := e.src.fetch(ctx)
+	if err != nil || snap == nil {
+		return false, err
+	}
+	eng, err := eval.NewEngine(snap.store, e.cfg.audits, e.cfg.failClosed)
+	if err != nil {
+		return false, err
+	}
+	if e.cfg.cacheSize > 0 {
+		eng.EnableDecisionCache(e.cfg.cacheSize, e.cfg.cacheTTL)
+	}
+	if e.cfg.maxChain != nil {
+		eng.ConfigureDelegation(*e.cfg.maxChain)
+	}
+	e.cur.Store(eng)
+	e.version.Store(snap.version)
+	return true, nil
+}
+
+// Run refreshes the engine every interval until ctx is done.
+func (e *Engine) Run(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if _, err := e.Refresh(ctx); err != nil {
+				e.cfg.logf("pdp: refresh failed, keeping version %q: %v", e.Version(), err)
+			}
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package pdp_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"example.com/jit-engine/internal/bundle"
+	"example.com/jit-engine/pkg/pdp"
+)
+
+var login = pdp.Request{Subject: map[string]any{"id": "alice"}, Resource: "ssh:unix:host/a", Action: "login", Protocol: "ssh"}
+
+func sshPolicy(effect string) pdp.Policy {
+	return pdp.Policy{Name: "ssh " + effect, Provider: "ssh", Effect: effect, Expr: "true", Resource: "ssh:unix:*", Actions: []string{"login"}}
+}
+
+func TestPoliciesEvaluateAndAudit(t *testing.T) {
+	var recorded []*pdp.AuditRecord
+	eng, err := pdp.New(pdp.Policies(sshPolicy("allow")),
+		pdp.WithAuditSink(pdp.AuditFunc(func(r *pdp.AuditRecord) error {
+			recorded = append(recorded, r)
+			return nil
+		})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := eng.Evaluate(login)
+	if err != nil || res.Decision != pdp.Allow {
+		t.Fatalf("login: %s %v", res.Decision, err)
+	}
+	sudo := login
+	sudo.Action = "sudo"
+	if eng.Allowed(sudo) {
+		t.Fatal("sudo allowed without a policy")
+	}
+	if len(recorded) != 2 || recorded[0].Decision != pdp.Allow || recorded[1].Decision != pdp.Deny || recorded[1].Request.Action != "sudo" {
+		t.Fatalf("audit sink got %+v", recorded)
+	}
+	if changed, err := eng.Refresh(context.Background()); changed || err != nil {
+		t.Fatalf("static source refreshed: %v %v", changed, err)
+	}
+	if eng.Version() != "static" {
+		t.Fatalf("version %q", eng.Version())
+	}
+}
+
+func TestNewWithoutSource(t *testing.T) {
+	if _, err := pdp.New(pdp.Source{}); err == nil {
+		t.Fatal("engine built without a source")
+	}
+}
+
+// bundleServer serves a signed bundle the way a server's /bundle does,
+// answering 304 to a request carrying the current ETag.
+type bundleServer struct {
+	mu   sync.Mutex
+	data []byte
+	etag string
+	gets int
+}
+
+func (s *bundleServer) publish(t *testing.T, key ed25519.PrivateKey, version string, ps ...pdp.Policy) {
+	t.Helper()
+	var buf bytes.Buffer
+	if _, err := bundle.Write(&buf, &pdp.Contents{Policies: ps}, version, key); err != nil {
+		t.Fatal(err)
+	}
+	s.mu.Lock()
+	s.data, s.etag = buf.Bytes(), `"`+version+`"`
+	s.mu.Unlock()
+}
+
+func (s *bundleServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.gets++
+	if r.Header.Get("If-None-Match") == s.etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("ETag", s.etag)
+	_, _ = w.Write(s.data)
+}
+
+func TestRemoteRefresh(t *testing.T) {
+	pub, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	allow, deny := sshPolicy("allow"), sshPolicy("deny")
+	// Exported policies carry their IDs and Enabled flags
+	allow.ID, deny.ID = uuid.New(), uuid.New()
+	allow.Enabled, deny.Enabled = true, true
+	srv := &bundleServer{}
+	srv.publish(t, key, "v1", allow)
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	eng, err := pdp.New(pdp.Remote(ts.URL, nil, pub), pdp.WithLogger(t.Logf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if eng.Version() != "v1" || !eng.Allowed(login) {
+		t.Fatalf("v1: version %q, allowed %v", eng.Version(), eng.Allowed(login))
+	}
+
+	// Unchanged: the server answers 304 and the engine keeps its policies
+	if changed, err := eng.Refresh(context.Background()); changed || err != nil {
+		t.Fatalf("unchanged bundle: %v %v", changed, err)
+	}
+
+	srv.publish(t, key, "v2", allow, deny)
+	if changed, err := eng.Refresh(context.Background()); !changed || err != nil {
+		t.Fatalf("new bundle: %v %v", changed, err)
+	}
+	if eng.Version() != "v2" || eng.Allowed(login) {
+		t.Fatalf("v2: version %q, allowed %v", eng.Version(), eng.Allowed(login))
+	}
+
+	// A bundle signed by an untrusted key is refused and v2 stays in use
+	_, other, _ := ed25519.GenerateKey(nil)
+	srv.publish(t, other, "v3", allow)
+	if changed, err := eng.Refresh(context.Background()); changed || err == nil {
+		t.Fatalf("untrusted bundle: %v %v", changed, err)
+	}
+	if eng.Version() != "v2" || eng.Allowed(login) {
+		t.Fatalf("after refused refresh: version %q", eng.Version())
+	}
+	if srv.gets != 4 {
+		t.Fatalf("server saw %d requests, want 4", srv.gets)
+	}
+}
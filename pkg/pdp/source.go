@@ -0,0 +1,167 @@
+package pdp
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"example.com/jit-engine/internal/bundle"
+	"example.com/jit-engine/internal/store"
+)
+
+// Source supplies the policies an Engine evaluates. Build one with Static,
+// Policies, BundleFile or Remote.
+type Source struct {
+	// fetch returns the current policies, or nil when they have not changed
+	// since the last call.
+	fetch func(ctx context.Context) (*snapshot, error)
+}
+
+type snapshot struct {
+	store   *store.Memory
+	version string
+}
+
+// Contents is everything an engine evaluates besides the request.
+type Contents = bundle.Contents
+
+// Static evaluates c. Policies without an ID get one; a policy's Enabled flag
+// is taken as given, so build c from exported data or set Enabled.
+func Static(c Contents) Source {
+	return Source{fetch: (&staticSource{c: c}).fetch}
+}
+
+// Policies evaluates ps, all enabled, with the default providers. Empty
+// providers and zero priorities take the API defaults (global and 100).
+func Policies(ps ...Policy) Source {
+	c := Contents{Policies: make([]Policy, len(ps))}
+	for i, p := range ps {
+		p.Enabled = true
+		if p.Provider == "" {
+			p.Provider = "global"
+		}
+		if p.Priority == 0 {
+			p.Priority = 100
+		}
+		c.Policies[i] = p
+	}
+	return Static(c)
+}
+
+type staticSource struct {
+	c    Contents
+	done bool
+}
+
+func (s *staticSource) fetch(context.Context) (*snapshot, error) {
+	if s.done {
+		return nil, nil
+	}
+	c := s.c
+	c.Policies = append([]Policy(nil), s.c.Policies...)
+	for i := range c.Policies {
+		if c.Policies[i].ID == uuid.Nil {
+			c.Policies[i].ID = uuid.New()
+		}
+	}
+	m, err := c.Memory()
+	if err != nil {
+		return nil, err
+	}
+	s.done = true
+	return &snapshot{store: m, version: "static"}, nil
+}
+
+// BundleFile evaluates the signed bundle at path, which must verify against
+// one of trusted. Refresh picks up a replaced file.
+func BundleFile(path string, trusted ...ed25519.PublicKey) Source {
+	s := &bundleSource{
+		name:    path,
+		trusted: trusted,
+		read:    func(context.Context, string) ([]byte, string, error) { b, err := os.ReadFile(path); return b, "", err },
+	}
+	return Source{fetch: s.fetch}
+}
+
+// Remote evaluates a signed bundle downloaded from url with client (nil for
+// http.DefaultClient). Refresh downloads it again, sending the last ETag so an
+// unchanged bundle costs a 304.
+func Remote(url string, client *http.Client, trusted ...ed25519.PublicKey) Source {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	s := &bundleSource{
+		name:    url,
+		trusted: trusted,
+		read: func(ctx context.Context, etag string) ([]byte, string, error) {
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+			if err != nil {
+				return nil, "", err
+			}
+			if etag != "" {
+				req.Header.Set("If-None-Match", etag)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				return nil, "", err
+			}
+			defer resp.Body.Close()
+			switch resp.StatusCode {
+			case http.StatusNotModified:
+				return nil, etag, nil
+			case http.StatusOK:
+				b, err := io.ReadAll(io.LimitReader(resp.Body, 256<<20))
+				return b, resp.Header.Get("ETag"), err
+			}
+			return nil, "", fmt.Errorf("GET %s: %s", url, resp.Status)
+		},
+	}
+	return Source{fetch: s.fetch}
+}
+
+// bundleSource reads a bundle with read and reloads it when its bytes change.
+// read returns nil data when the bundle is known to be unchanged.
+type bundleSource struct {
+	name    string
+	trusted []ed25519.PublicKey
+	read    func(ctx context.Context, etag string) ([]byte, string, error)
+
+	mu     sync.Mutex
+	etag   string
+	digest [sha256.Size]byte
+}
+
+func (s *bundleSource) fetch(ctx context.Context) (*snapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, etag, err := s.read(ctx, s.etag)
+	if err != nil {
+		return nil, fmt.Errorf("pdp: %s: %w", s.name, err)
+	}
+	if data == nil {
+		return nil, nil
+	}
+	digest := sha256.Sum256(data)
+	if digest == s.digest {
+		s.etag = etag
+		return nil, nil
+	}
+	b, err := bundle.Read(bytes.NewReader(data), s.trusted)
+	if err != nil {
+		return nil, fmt.Errorf("pdp: %s: %w", s.name, err)
+	}
+	m, err := b.Contents.Memory()
+	if err != nil {
+		return nil, fmt.Errorf("pdp: %s: %w", s.name, err)
+	}
+	s.etag, s.digest = etag, digest
+	return &snapshot{store: m, version: b.Manifest.Version}, nil
+}